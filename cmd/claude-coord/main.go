@@ -12,6 +12,6 @@ var Version = "dev"
 func main() {
 	cli.SetVersion(Version)
 	if err := cli.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
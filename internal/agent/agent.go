@@ -2,13 +2,15 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"slices"
 	"time"
 
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
 )
 
 type Agent struct {
@@ -18,128 +20,346 @@ type Agent struct {
 	LastHeartbeat time.Time `json:"last_heartbeat"`
 	CurrentTask   string    `json:"current_task,omitempty"`
 	LocksHeld     []string  `json:"locks_held,omitempty"`
+	FilesTouched  []string  `json:"files_touched,omitempty"`
 	PID           int       `json:"pid"`
+
+	// TaskStartedAt is when CurrentTask was set
+	TaskStartedAt time.Time `json:"task_started_at,omitempty"`
+
+	// TaskAuto is set when CurrentTask was derived from the agent's locks
+	// rather than set explicitly
+	TaskAuto bool `json:"task_auto,omitempty"`
+
+	// TaskHistory lists the tasks set explicitly before CurrentTask, oldest
+	// first
+	TaskHistory []Task `json:"task_history,omitempty"`
 }
 
+// Task is a task an agent worked on
+type Task struct {
+	Task      string    `json:"task"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+// maxTaskHistory is how many previous tasks an agent's record keeps
+const maxTaskHistory = 20
+
+// guardTimeout bounds how long an update waits for another process's
+const guardTimeout = 5 * time.Second
+
 type Manager struct {
 	coordDir string
 	cfg      *config.Config
+	store    storage.Store
+	storeErr error
 }
 
 func NewManager(coordDir string, cfg *config.Config) *Manager {
 	if coordDir == "" {
 		coordDir = config.DefaultCoordDir
 	}
+	store, err := storage.Open(coordDir, cfg)
 	return &Manager{
 		coordDir: coordDir,
 		cfg:      cfg,
+		store:    store,
+		storeErr: err,
 	}
 }
 
-// Register creates a new agent entry
-func (m *Manager) Register(id, name string) error {
-	if err := config.EnsureDirs(m.coordDir); err != nil {
-		return fmt.Errorf("failed to create directories: %w", err)
+// NewManagerWithStore returns a Manager that keeps agents in store, e.g.
+// one bound to a transaction, instead of opening the coord dir's
+func NewManagerWithStore(coordDir string, cfg *config.Config, store storage.Store) *Manager {
+	return &Manager{
+		coordDir: coordDir,
+		cfg:      cfg,
+		store:    store,
 	}
+}
 
-	now := time.Now().UTC()
-	agent := Agent{
-		ID:            id,
-		Name:          name,
-		StartedAt:     now,
-		LastHeartbeat: now,
-		PID:           os.Getpid(),
+// backend returns the store, or why it couldn't be opened
+func (m *Manager) backend() (storage.Store, error) {
+	if m.storeErr != nil {
+		return nil, fmt.Errorf("failed to open %s backend: %w", m.cfg.Settings.Backend, m.storeErr)
 	}
+	return m.store, nil
+}
 
-	return m.save(&agent)
+// Register creates a new agent entry. Locks the agent already holds, e.g.
+// from before a restart, stay on its record, as do its tasks.
+func (m *Manager) Register(id, name string) error {
+	return m.update(id, true, func(agent *Agent) bool {
+		now := time.Now().UTC()
+		*agent = Agent{
+			ID:            id,
+			Name:          name,
+			StartedAt:     now,
+			LastHeartbeat: now,
+			PID:           os.Getpid(),
+			LocksHeld:     agent.LocksHeld,
+			CurrentTask:   agent.CurrentTask,
+			TaskStartedAt: agent.TaskStartedAt,
+			TaskAuto:      agent.TaskAuto,
+			TaskHistory:   agent.TaskHistory,
+		}
+		return true
+	})
 }
 
-// Deregister removes an agent entry
+// Deregister removes an agent entry, and any left under its legacy key
 func (m *Manager) Deregister(id string) error {
-	agentPath := m.agentPath(id)
-	if err := os.Remove(agentPath); err != nil && !os.IsNotExist(err) {
+	store, err := m.backend()
+	if err != nil {
 		return err
 	}
+	if err := store.Delete(storage.Agents, storage.AgentKey(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if entry, err := readLegacyEntry(store, id); err == nil {
+		if err := store.CompareAndDelete(storage.Agents, entry.Key, entry.Value); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	return nil
 }
 
-// Heartbeat updates the agent's last heartbeat time
+// Heartbeat updates the agent's last heartbeat time, registering the agent
+// if needed
 func (m *Manager) Heartbeat(id string) error {
-	agent, err := m.Read(id)
-	if err != nil {
-		// Agent not registered, create minimal entry
-		return m.Register(id, "")
-	}
-
-	agent.LastHeartbeat = time.Now().UTC()
-	return m.save(agent)
+	return m.update(id, true, func(agent *Agent) bool {
+		agent.LastHeartbeat = time.Now().UTC()
+		return true
+	})
 }
 
-// UpdateTask updates the agent's current task
+// UpdateTask sets the agent's current task, registering the agent if
+// needed. An empty task clears it. The task it replaces goes into the
+// agent's history, unless it was derived from the agent's locks.
 func (m *Manager) UpdateTask(id, task string) error {
-	agent, err := m.Read(id)
-	if err != nil {
-		return err
-	}
+	return m.update(id, true, func(agent *Agent) bool {
+		now := time.Now().UTC()
+		if agent.CurrentTask != task || agent.TaskAuto {
+			if agent.CurrentTask != "" && !agent.TaskAuto {
+				agent.TaskHistory = append(agent.TaskHistory, Task{
+					Task:      agent.CurrentTask,
+					StartedAt: agent.TaskStartedAt,
+					EndedAt:   now,
+				})
+				if n := len(agent.TaskHistory); n > maxTaskHistory {
+					agent.TaskHistory = agent.TaskHistory[n-maxTaskHistory:]
+				}
+			}
+			agent.CurrentTask = task
+			agent.TaskStartedAt = now
+			agent.TaskAuto = false
+			if task == "" {
+				agent.TaskStartedAt = time.Time{}
+			}
+		}
+		agent.LastHeartbeat = now
+		return true
+	})
+}
 
-	agent.CurrentTask = task
-	agent.LastHeartbeat = time.Now().UTC()
-	return m.save(agent)
+// AutoTask shows task, derived from what the agent is doing, as its current
+// task. A task set with UpdateTask takes precedence until it's cleared. An
+// empty task clears a derived one.
+func (m *Manager) AutoTask(id, task string) error {
+	return m.update(id, false, func(agent *Agent) bool {
+		if (agent.CurrentTask != "" && !agent.TaskAuto) || agent.CurrentTask == task {
+			return false
+		}
+		agent.CurrentTask = task
+		agent.TaskStartedAt = time.Now().UTC()
+		agent.TaskAuto = task != ""
+		if task == "" {
+			agent.TaskStartedAt = time.Time{}
+		}
+		return true
+	})
 }
 
-// UpdateLocks updates the agent's held locks
+// UpdateLocks updates the agent's held locks. Like AutoTask, it doesn't
+// count as a heartbeat, since it's done for whichever agents' locks changed.
 func (m *Manager) UpdateLocks(id string, locks []string) error {
-	agent, err := m.Read(id)
+	return m.update(id, false, func(agent *Agent) bool {
+		agent.LocksHeld = locks
+		return true
+	})
+}
+
+// RecordFiles adds files to the list of files the agent has modified
+func (m *Manager) RecordFiles(id string, files []string) error {
+	return m.update(id, true, func(agent *Agent) bool {
+		for _, f := range files {
+			if !slices.Contains(agent.FilesTouched, f) {
+				agent.FilesTouched = append(agent.FilesTouched, f)
+			}
+		}
+		agent.LastHeartbeat = time.Now().UTC()
+		return true
+	})
+}
+
+// update applies change to the agent's record. Updates are serialized, in a
+// transaction or under the agent's guard, so that concurrent ones, e.g. a
+// heartbeat and a change of the agent's locks, don't overwrite each other.
+// A missing agent is registered first with register, and is an
+// os.IsNotExist error without. change reports whether it changed anything.
+func (m *Manager) update(id string, register bool, change func(agent *Agent) bool) error {
+	if err := config.EnsureDirs(m.coordDir); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+	store, err := m.backend()
 	if err != nil {
 		return err
 	}
 
-	agent.LocksHeld = locks
-	agent.LastHeartbeat = time.Now().UTC()
-	return m.save(agent)
+	apply := func(m *Manager) error {
+		agent, entry, err := m.read(id)
+		if os.IsNotExist(err) && register {
+			now := time.Now().UTC()
+			agent, err = &Agent{ID: id, StartedAt: now, LastHeartbeat: now, PID: os.Getpid()}, nil
+		}
+		if err != nil {
+			return err
+		}
+		if !change(agent) {
+			return nil
+		}
+		if entry == nil || entry.Key != storage.AgentKey(id) {
+			vacate(m.store, id)
+		}
+		if err := m.save(agent); err != nil {
+			return err
+		}
+		if entry != nil && entry.Key != storage.AgentKey(id) {
+			// The record has moved from its legacy key
+			m.store.CompareAndDelete(storage.Agents, entry.Key, entry.Value)
+		}
+		return nil
+	}
+
+	if tx, ok := store.(storage.Transactional); ok {
+		return tx.Atomically(func(s storage.Store) error {
+			bound := *m
+			bound.store = s
+			return apply(&bound)
+		})
+	}
+	guard := filepath.Join(m.coordDir, config.AgentsDir, storage.AgentKey(id)+".guard")
+	err = storage.WithGuard(store, "agents/"+storage.AgentKey(id), guard, guardTimeout, func() error {
+		return apply(m)
+	})
+	if errors.Is(err, storage.ErrGuardTimeout) {
+		return fmt.Errorf("timed out waiting to update agent %s", id)
+	}
+	return err
 }
 
-// Read loads an agent from disk
+// Read loads an agent from the store
 func (m *Manager) Read(id string) (*Agent, error) {
-	agentPath := m.agentPath(id)
-	data, err := os.ReadFile(agentPath)
+	agent, _, err := m.read(id)
+	return agent, err
+}
+
+// read loads an agent along with the entry it was stored in
+func (m *Manager) read(id string) (*Agent, *storage.Entry, error) {
+	store, err := m.backend()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	entry, err := ReadEntry(store, id)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var agent Agent
-	if err := json.Unmarshal(data, &agent); err != nil {
+	if err := json.Unmarshal(entry.Value, &agent); err != nil {
+		return nil, nil, err
+	}
+
+	return &agent, entry, nil
+}
+
+// ReadEntry returns the entry agent id is stored in, falling back to the
+// key earlier releases used until the agent's record is next written
+func ReadEntry(store storage.Store, id string) (*storage.Entry, error) {
+	key := storage.AgentKey(id)
+	entry, err := store.Get(storage.Agents, key)
+	if err == nil && storedID(entry) != id {
+		// Another agent's record from an earlier release, under a legacy
+		// key that happens to be id's
+		entry, err = nil, &os.PathError{Op: "get", Path: string(storage.Agents) + "/" + key, Err: os.ErrNotExist}
+	}
+	if os.IsNotExist(err) {
+		if legacy, legacyErr := readLegacyEntry(store, id); legacyErr == nil {
+			return legacy, nil
+		}
+	}
+	return entry, err
+}
+
+// readLegacyEntry returns the entry agent id was stored in by earlier
+// releases. Several IDs share each legacy key, so the stored ID must match.
+func readLegacyEntry(store storage.Store, id string) (*storage.Entry, error) {
+	key := storage.LegacyAgentKey(id)
+	if key == storage.AgentKey(id) {
+		return nil, &os.PathError{Op: "get", Path: string(storage.Agents) + "/" + key, Err: os.ErrNotExist}
+	}
+
+	entry, err := store.Get(storage.Agents, key)
+	if err != nil {
 		return nil, err
 	}
+	if storedID(entry) != id {
+		return nil, &os.PathError{Op: "get", Path: string(storage.Agents) + "/" + key, Err: os.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// vacate moves another agent's record that an earlier release stored under
+// id's key to that agent's own key, so saving id's doesn't overwrite it
+func vacate(store storage.Store, id string) {
+	entry, err := store.Get(storage.Agents, storage.AgentKey(id))
+	if err != nil {
+		return
+	}
+	owner := storedID(entry)
+	if owner == "" || owner == id {
+		return
+	}
+	// If the owner's key is taken, its record there is newer
+	if err := store.Create(storage.Agents, storage.AgentKey(owner), entry.Value); err == nil || os.IsExist(err) {
+		store.CompareAndDelete(storage.Agents, entry.Key, entry.Value)
+	}
+}
 
-	return &agent, nil
+// storedID returns the ID of the agent whose record entry is
+func storedID(entry *storage.Entry) string {
+	var stored struct {
+		ID string `json:"agent_id"`
+	}
+	json.Unmarshal(entry.Value, &stored)
+	return stored.ID
 }
 
 // List returns all registered agents
 func (m *Manager) List() ([]Agent, error) {
-	agentsDir := filepath.Join(m.coordDir, config.AgentsDir)
-	entries, err := os.ReadDir(agentsDir)
+	store, err := m.backend()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.List(storage.Agents)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
 		return nil, err
 	}
 
 	var agents []Agent
 	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".agent") {
-			continue
-		}
-
-		agentPath := filepath.Join(agentsDir, entry.Name())
-		data, err := os.ReadFile(agentPath)
-		if err != nil {
-			continue
-		}
-
 		var agent Agent
-		if err := json.Unmarshal(data, &agent); err != nil {
+		if err := json.Unmarshal(entry.Value, &agent); err != nil {
 			continue
 		}
 		agents = append(agents, agent)
@@ -154,17 +374,26 @@ func (m *Manager) IsAlive(agent *Agent) bool {
 	return time.Since(agent.LastHeartbeat) < threshold
 }
 
-// CleanStale removes dead agent entries
+// CleanStale removes dead agent entries. An agent that sends a heartbeat
+// while it's being cleaned up is kept.
 func (m *Manager) CleanStale() ([]string, error) {
-	agents, err := m.List()
+	store, err := m.backend()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.List(storage.Agents)
 	if err != nil {
 		return nil, err
 	}
 
 	var cleaned []string
-	for _, agent := range agents {
+	for _, entry := range entries {
+		var agent Agent
+		if err := json.Unmarshal(entry.Value, &agent); err != nil {
+			continue
+		}
 		if !m.IsAlive(&agent) {
-			if err := m.Deregister(agent.ID); err == nil {
+			if err := store.CompareAndDelete(storage.Agents, entry.Key, entry.Value); err == nil {
 				cleaned = append(cleaned, agent.ID)
 			}
 		}
@@ -173,8 +402,28 @@ func (m *Manager) CleanStale() ([]string, error) {
 	return cleaned, nil
 }
 
+// HeartbeatOption configures RunHeartbeat
+type HeartbeatOption func(*heartbeatOptions)
+
+type heartbeatOptions struct {
+	renew func(agentID string) error
+}
+
+// RenewLocks makes every heartbeat also call renew, typically
+// lock.Manager.RenewAll, so a live agent never loses its leases
+func RenewLocks(renew func(agentID string) error) HeartbeatOption {
+	return func(o *heartbeatOptions) {
+		o.renew = renew
+	}
+}
+
 // RunHeartbeat runs a heartbeat loop in the background
-func (m *Manager) RunHeartbeat(id string, interval time.Duration, stop <-chan struct{}) {
+func (m *Manager) RunHeartbeat(id string, interval time.Duration, stop <-chan struct{}, opts ...HeartbeatOption) {
+	var o heartbeatOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -182,6 +431,9 @@ func (m *Manager) RunHeartbeat(id string, interval time.Duration, stop <-chan st
 		select {
 		case <-ticker.C:
 			m.Heartbeat(id)
+			if o.renew != nil {
+				o.renew(id)
+			}
 		case <-stop:
 			return
 		}
@@ -189,20 +441,16 @@ func (m *Manager) RunHeartbeat(id string, interval time.Duration, stop <-chan st
 }
 
 func (m *Manager) save(agent *Agent) error {
+	store, err := m.backend()
+	if err != nil {
+		return err
+	}
 	data, err := json.MarshalIndent(agent, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	agentPath := m.agentPath(agent.ID)
-	return os.WriteFile(agentPath, data, 0644)
-}
-
-func (m *Manager) agentPath(id string) string {
-	// Sanitize ID for filename
-	safe := strings.ReplaceAll(id, "/", "-")
-	safe = strings.ReplaceAll(safe, "\\", "-")
-	return filepath.Join(m.coordDir, config.AgentsDir, safe+".agent")
+	return store.Put(storage.Agents, storage.AgentKey(agent.ID), data)
 }
 
 // GenerateID creates a unique agent ID
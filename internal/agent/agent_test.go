@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
+)
+
+func TestTasks(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	// Setting a task registers the agent
+	if err := mgr.UpdateTask("agent-1", "Adding OAuth"); err != nil {
+		t.Fatal(err)
+	}
+	a, err := mgr.Read("agent-1")
+	if err != nil || a.CurrentTask != "Adding OAuth" || a.TaskStartedAt.IsZero() || a.TaskAuto {
+		t.Fatalf("Expected the task set, got %+v %v", a, err)
+	}
+
+	// Derived tasks don't replace one that was set, and aren't kept
+	mgr.AutoTask("agent-1", "migrate (db/*)")
+	if a, _ := mgr.Read("agent-1"); a.CurrentTask != "Adding OAuth" {
+		t.Errorf("Expected the set task kept, got %q", a.CurrentTask)
+	}
+	mgr.UpdateTask("agent-1", "")
+	mgr.AutoTask("agent-1", "migrate (db/*)")
+	if a, _ := mgr.Read("agent-1"); a.CurrentTask != "migrate (db/*)" || !a.TaskAuto {
+		t.Errorf("Expected the derived task, got %+v", a)
+	}
+	mgr.UpdateTask("agent-1", "Fixing login")
+	mgr.Register("agent-1", "")
+
+	a, _ = mgr.Read("agent-1")
+	if a.CurrentTask != "Fixing login" || len(a.TaskHistory) != 1 || a.TaskHistory[0].Task != "Adding OAuth" || a.TaskHistory[0].EndedAt.IsZero() {
+		t.Fatalf("Expected one previous task kept across registering, got %+v", a)
+	}
+
+	// History is capped, dropping the oldest
+	for i := 0; i < maxTaskHistory+5; i++ {
+		mgr.UpdateTask("agent-1", fmt.Sprintf("task %d", i))
+	}
+	a, _ = mgr.Read("agent-1")
+	if len(a.TaskHistory) != maxTaskHistory || a.TaskHistory[len(a.TaskHistory)-1].Task != fmt.Sprintf("task %d", maxTaskHistory+3) {
+		t.Errorf("Expected the last %d tasks, got %+v", maxTaskHistory, a.TaskHistory)
+	}
+
+	// Only registered agents get derived tasks
+	if err := mgr.AutoTask("nobody", "x"); err == nil {
+		t.Error("Expected AutoTask to fail for an unregistered agent")
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+	mgr.Register("agent-1", "")
+
+	// Updates of different fields don't overwrite each other
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			mgr.RecordFiles("agent-1", []string{fmt.Sprintf("file-%d", i)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			mgr.UpdateLocks("agent-1", []string{"db/*"})
+		}()
+	}
+	wg.Wait()
+
+	a, err := mgr.Read("agent-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.FilesTouched) != 20 || len(a.LocksHeld) != 1 {
+		t.Errorf("Expected 20 files and 1 lock recorded, got %d and %v", len(a.FilesTouched), a.LocksHeld)
+	}
+}
+
+func TestLegacyAgentKeys(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+	store, _ := mgr.backend()
+
+	// Earlier releases stored "team/a" under "team-a"
+	config.EnsureDirs(coordDir)
+	if err := store.Put(storage.Agents, "team-a", []byte(`{"agent_id": "team/a", "current_task": "old"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := mgr.Read("team/a"); err != nil || a.CurrentTask != "old" {
+		t.Fatalf("Expected the legacy record, got %+v %v", a, err)
+	}
+	if _, err := mgr.Read("team-a"); err == nil {
+		t.Error("Read another agent's legacy record")
+	}
+
+	// Registering the agent whose key it is moves the legacy record out of
+	// the way, and writing a legacy record moves it to its new key
+	if err := mgr.Register("team-a", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Heartbeat("team/a"); err != nil {
+		t.Fatal(err)
+	}
+	if agents, _ := mgr.List(); len(agents) != 2 {
+		t.Errorf("Expected team/a and team-a kept apart, got %+v", agents)
+	}
+	if a, _ := mgr.Read("team/a"); a.CurrentTask != "old" {
+		t.Errorf("Expected team/a's task kept, got %+v", a)
+	}
+
+	// Upper-case letters are escaped now too
+	store.Put(storage.Agents, "Backend", []byte(`{"agent_id": "Backend", "current_task": "old"}`))
+	if err := mgr.Heartbeat("Backend"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(storage.Agents, "Backend"); err == nil {
+		t.Error("Expected the legacy record to be moved")
+	}
+	if a, _ := mgr.Read("Backend"); a == nil || a.CurrentTask != "old" {
+		t.Errorf("Expected Backend's task kept, got %+v", a)
+	}
+}
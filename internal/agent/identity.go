@@ -0,0 +1,295 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+)
+
+// IdentitySource says where an agent ID came from
+type IdentitySource string
+
+const (
+	SourceFlag          IdentitySource = "flag"
+	SourceEnv           IdentitySource = "env"
+	SourceClaudeSession IdentitySource = "claude-session"
+	SourceClaudeProcess IdentitySource = "claude-process"
+	SourceTTY           IdentitySource = "tty"
+	SourceParent        IdentitySource = "parent"
+	SourceGenerated     IdentitySource = "generated"
+)
+
+// Identity is a resolved agent ID
+type Identity struct {
+	ID     string
+	Source IdentitySource
+
+	// Detail describes what the ID was derived from, for whoami
+	Detail string
+}
+
+// ResolveIdentity works out the agent ID for this process. In order, it
+// uses: an ID given explicitly (e.g. by --agent), CLAUDE_SESSION_ID, the
+// session ID recorded by hooks for the Claude Code process we run under,
+// that Claude Code process itself, the process that ran us, such as a
+// script or the shell in a terminal, and the terminal together with the git
+// worktree. Except for the first two, the ID is derived from stable facts,
+// so separate commands run from the same session, script or shell resolve
+// to the same agent, and separate scripts, even in one terminal, don't.
+// Only when none of these are available is a one-off ID generated.
+func ResolveIdentity(coordDir, explicit string) *Identity {
+	if explicit != "" {
+		return &Identity{ID: explicit, Source: SourceFlag, Detail: "--agent"}
+	}
+	if id := os.Getenv("CLAUDE_SESSION_ID"); id != "" {
+		return &Identity{ID: id, Source: SourceEnv, Detail: "CLAUDE_SESSION_ID"}
+	}
+
+	procs := &processReader{}
+	if claude := findClaudeProcess(procs); claude != nil {
+		detail := fmt.Sprintf("Claude Code process %d", claude.pid)
+		if id := readSessionID(coordDir, claude); id != "" {
+			return &Identity{ID: id, Source: SourceClaudeSession, Detail: detail}
+		}
+		return &Identity{ID: "claude-" + shortHash(claude.key()), Source: SourceClaudeProcess, Detail: detail}
+	}
+
+	if parent, err := procs.read(os.Getppid()); err == nil {
+		detail := fmt.Sprintf("%s process %d", parent.name, parent.pid)
+		return &Identity{ID: "proc-" + shortHash(parent.key()), Source: SourceParent, Detail: detail}
+	}
+	if tty := terminalName(); tty != "" {
+		worktree := gitWorktree()
+		detail := tty
+		if worktree != "" {
+			detail = fmt.Sprintf("%s in %s", tty, worktree)
+		}
+		return &Identity{ID: "tty-" + shortHash(tty+"\x00"+worktree), Source: SourceTTY, Detail: detail}
+	}
+
+	return &Identity{ID: GenerateID(), Source: SourceGenerated, Detail: "no stable identity available"}
+}
+
+// RememberSession records sessionID as the identity of the Claude Code
+// process we run under, so commands it runs later without a session ID,
+// such as from its Bash tool, resolve to the same agent. It does nothing
+// outside Claude Code.
+func RememberSession(coordDir, sessionID string) error {
+	claude := findClaudeProcess(&processReader{})
+	if claude == nil || sessionID == "" {
+		return nil
+	}
+	if coordDir == "" {
+		coordDir = config.DefaultCoordDir
+	}
+
+	dir := filepath.Join(coordDir, config.IdentitiesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(sessionPath(coordDir, claude), []byte(sessionID+"\n"), 0644)
+}
+
+func readSessionID(coordDir string, claude *process) string {
+	if coordDir == "" {
+		coordDir = config.DefaultCoordDir
+	}
+	data, err := os.ReadFile(sessionPath(coordDir, claude))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func sessionPath(coordDir string, claude *process) string {
+	return filepath.Join(coordDir, config.IdentitiesDir, shortHash(claude.key())+".session")
+}
+
+// process is a running process, as far as identity resolution cares
+type process struct {
+	pid   int
+	ppid  int
+	name  string
+	args  []string
+	start string
+}
+
+// key identifies the process across PID reuse
+func (p *process) key() string {
+	return fmt.Sprintf("%d:%s", p.pid, p.start)
+}
+
+// isClaude reports whether the process is Claude Code, which runs either
+// as a native "claude" binary or as a node script of that name
+func (p *process) isClaude() bool {
+	if p.name == "claude" {
+		return true
+	}
+	for i, arg := range p.args {
+		if i > 1 {
+			break
+		}
+		if filepath.Base(arg) == "claude" {
+			return true
+		}
+	}
+	return false
+}
+
+// findClaudeProcess returns the closest ancestor that is Claude Code
+func findClaudeProcess(procs *processReader) *process {
+	pid := os.Getppid()
+	for i := 0; i < 32 && pid > 1; i++ {
+		p, err := procs.read(pid)
+		if err != nil {
+			return nil
+		}
+		if p.isClaude() {
+			return p
+		}
+		pid = p.ppid
+	}
+	return nil
+}
+
+// processReader looks processes up in /proc where there is one, else in a
+// listing of every process from a single call to ps, so walking up the
+// process tree doesn't run ps once per ancestor
+type processReader struct {
+	table map[int]*process
+	err   error
+}
+
+func (r *processReader) read(pid int) (*process, error) {
+	if p, err := readProcProcess(pid); err == nil {
+		return p, nil
+	}
+
+	if r.table == nil && r.err == nil {
+		r.table, r.err = listProcesses()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	p, ok := r.table[pid]
+	if !ok {
+		return nil, fmt.Errorf("no process %d", pid)
+	}
+	return p, nil
+}
+
+// listProcesses asks ps for every running process
+func listProcesses() (map[int]*process, error) {
+	output, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=", "-o", "lstart=", "-o", "comm=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	table := make(map[int]*process)
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p, err := parsePS(line)
+		if err != nil {
+			return nil, err
+		}
+		table[p.pid] = p
+	}
+	return table, nil
+}
+
+// parsePS parses a line of ps output with the pid, ppid, lstart and comm
+// columns. lstart is five fields, e.g. "Mon Oct 12 09:41:07 2026".
+func parsePS(line string) (*process, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 8 {
+		return nil, fmt.Errorf("unexpected ps output: %q", line)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	comm := strings.Join(fields[7:], " ")
+	return &process{
+		pid:   pid,
+		ppid:  ppid,
+		name:  filepath.Base(comm),
+		args:  []string{comm},
+		start: strings.Join(fields[2:7], " "),
+	}, nil
+}
+
+func readProcProcess(pid int) (*process, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The name is in parentheses and may itself contain spaces or ")"
+	s := string(stat)
+	lparen, rparen := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if lparen < 0 || rparen < lparen {
+		return nil, fmt.Errorf("malformed %s/stat", dir)
+	}
+	// Fields after the name start at field 3 (state); ppid is field 4 and
+	// starttime field 22
+	fields := strings.Fields(s[rparen+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("malformed %s/stat", dir)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	p := &process{pid: pid, ppid: ppid, name: s[lparen+1 : rparen], start: fields[19]}
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		p.args = strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	}
+	return p, nil
+}
+
+// terminalName returns the terminal on stdin, or "" if there is none
+func terminalName() string {
+	if name, err := os.Readlink("/proc/self/fd/0"); err == nil {
+		if strings.HasPrefix(name, "/dev/pts/") || strings.HasPrefix(name, "/dev/tty") {
+			return name
+		}
+		return ""
+	}
+
+	cmd := exec.Command("tty")
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// gitWorktree returns the top of the current git worktree, or ""
+func gitWorktree() string {
+	output, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveIdentityPrecedence(t *testing.T) {
+	coordDir := t.TempDir()
+
+	t.Setenv("CLAUDE_SESSION_ID", "from-env")
+	if id := ResolveIdentity(coordDir, "from-flag"); id.ID != "from-flag" || id.Source != SourceFlag {
+		t.Errorf("Expected flag to win, got %+v", id)
+	}
+	if id := ResolveIdentity(coordDir, ""); id.ID != "from-env" || id.Source != SourceEnv {
+		t.Errorf("Expected env ID, got %+v", id)
+	}
+
+	// Without either, separate resolutions agree
+	t.Setenv("CLAUDE_SESSION_ID", "")
+	first, second := ResolveIdentity(coordDir, ""), ResolveIdentity(coordDir, "")
+	if first.Source != SourceGenerated && first.ID != second.ID {
+		t.Errorf("Derived identity isn't stable: %s vs %s", first.ID, second.ID)
+	}
+}
+
+func TestRememberSession(t *testing.T) {
+	if findClaudeProcess(&processReader{}) == nil {
+		t.Skip("not running under Claude Code")
+	}
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	t.Setenv("CLAUDE_SESSION_ID", "")
+
+	if err := RememberSession(coordDir, "session-42"); err != nil {
+		t.Fatal(err)
+	}
+	if id := ResolveIdentity(coordDir, ""); id.ID != "session-42" || id.Source != SourceClaudeSession {
+		t.Errorf("Expected remembered session, got %+v", id)
+	}
+}
+
+func TestReadProcess(t *testing.T) {
+	p, err := (&processReader{}).read(os.Getpid())
+	if err != nil {
+		t.Skipf("process information unavailable: %v", err)
+	}
+	if p.ppid != os.Getppid() {
+		t.Errorf("Expected parent %d, got %d", os.Getppid(), p.ppid)
+	}
+	if p.start == "" {
+		t.Error("Expected a start time")
+	}
+}
+
+func TestListProcesses(t *testing.T) {
+	table, err := listProcesses()
+	if err != nil {
+		t.Skipf("ps unavailable: %v", err)
+	}
+	p, ok := table[os.Getpid()]
+	if !ok {
+		t.Fatal("Expected this process in the listing")
+	}
+	if p.ppid != os.Getppid() || p.start == "" {
+		t.Errorf("Unexpected listing for this process: %+v", p)
+	}
+}
+
+func TestParsePS(t *testing.T) {
+	p, err := parsePS("  4242     1 Mon Oct 12 09:41:07 2026 /Applications/My App/claude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.pid != 4242 || p.ppid != 1 || p.start != "Mon Oct 12 09:41:07 2026" || !p.isClaude() {
+		t.Errorf("Unexpected process: %+v", p)
+	}
+	if _, err := parsePS("4242 1 Mon Oct"); err == nil {
+		t.Error("Expected error for a truncated line")
+	}
+}
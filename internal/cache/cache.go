@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
@@ -82,11 +83,15 @@ func (c *Cache) Save() error {
 }
 
 func hashConfig(cfg *config.Config) string {
-	// Hash just the protected patterns since that's what affects check results
+	// Hash just the protected patterns and logical file groups since that's
+	// what affects check results
 	var patterns []string
 	for _, p := range cfg.Protected {
 		patterns = append(patterns, p.Pattern)
 	}
+	for _, l := range cfg.Logical {
+		patterns = append(patterns, l.Name+":"+strings.Join(l.Files, ","))
+	}
 
 	data, _ := json.Marshal(patterns)
 	hash := sha256.Sum256(data)
@@ -9,6 +9,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/daemon"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
 )
 
@@ -25,9 +26,10 @@ var registerCmd = &cobra.Command{
 }
 
 var (
-	heartbeatAgentID  string
-	heartbeatDaemon   bool
-	heartbeatInterval int
+	heartbeatAgentID    string
+	heartbeatDaemon     bool
+	heartbeatInterval   int
+	heartbeatRenewLocks bool
 )
 
 var heartbeatCmd = &cobra.Command{
@@ -35,7 +37,9 @@ var heartbeatCmd = &cobra.Command{
 	Short: "Send a heartbeat for this agent",
 	Long: `Update the agent's heartbeat timestamp.
 
-With --daemon, runs continuously in the background until killed.`,
+With --daemon, runs continuously in the background until killed.
+With --renew-locks, every heartbeat also renews the leases on all locks
+held by this agent, so long-running work never goes stale.`,
 	RunE: runHeartbeat,
 }
 
@@ -52,13 +56,14 @@ var deregisterCmd = &cobra.Command{
 }
 
 func init() {
-	registerCmd.Flags().StringVar(&registerAgentID, "agent", "", "Agent ID (default: from env or auto)")
+	registerCmd.Flags().StringVar(&registerAgentID, "agent", "", "Agent ID (default: see whoami)")
 	registerCmd.Flags().StringVar(&registerAgentName, "name", "", "Agent display name")
 	rootCmd.AddCommand(registerCmd)
 
 	heartbeatCmd.Flags().StringVar(&heartbeatAgentID, "agent", "", "Agent ID")
 	heartbeatCmd.Flags().BoolVar(&heartbeatDaemon, "daemon", false, "Run continuously")
 	heartbeatCmd.Flags().IntVar(&heartbeatInterval, "interval", 0, "Heartbeat interval in seconds (0 = use config)")
+	heartbeatCmd.Flags().BoolVar(&heartbeatRenewLocks, "renew-locks", false, "Renew all locks held by this agent on each heartbeat")
 	rootCmd.AddCommand(heartbeatCmd)
 
 	deregisterCmd.Flags().StringVar(&deregisterAgentID, "agent", "", "Agent ID")
@@ -67,17 +72,17 @@ func init() {
 }
 
 func runRegister(cmd *cobra.Command, args []string) error {
-	agentID := registerAgentID
-	if agentID == "" {
-		agentID = os.Getenv("CLAUDE_SESSION_ID")
-		if agentID == "" {
-			agentID = agent.GenerateID()
-		}
-	}
-
-	agentMgr := agent.NewManager(coordDir, cfg)
+	agentID := resolveAgentID(registerAgentID)
 
-	if err := agentMgr.Register(agentID, registerAgentName); err != nil {
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+	register := agent.NewManager(coordDir, cfg).Register
+	if client != nil {
+		register = client.Register
+	}
+	if err := register(agentID, registerAgentName); err != nil {
 		return err
 	}
 
@@ -90,25 +95,35 @@ func runRegister(cmd *cobra.Command, args []string) error {
 }
 
 func runHeartbeat(cmd *cobra.Command, args []string) error {
-	agentID := heartbeatAgentID
-	if agentID == "" {
-		agentID = os.Getenv("CLAUDE_SESSION_ID")
-		if agentID == "" {
-			agentID = agent.GenerateID()
-		}
-	}
+	agentID := resolveAgentID(heartbeatAgentID)
 
 	agentMgr := agent.NewManager(coordDir, cfg)
+	lockMgr := lock.NewManager(coordDir, cfg)
+
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
 
 	if !heartbeatDaemon {
 		// Single heartbeat
-		if err := agentMgr.Heartbeat(agentID); err != nil {
+		if client != nil {
+			err = client.Heartbeat(agentID, nil, heartbeatRenewLocks)
+		} else if err = agentMgr.Heartbeat(agentID); err == nil && heartbeatRenewLocks {
+			err = lockMgr.RenewAll(agentID)
+		}
+		if err != nil {
 			return err
 		}
 		fmt.Printf("✓ Heartbeat sent for: %s\n", agentID)
 		return nil
 	}
 
+	var opts []agent.HeartbeatOption
+	if heartbeatRenewLocks {
+		opts = append(opts, agent.RenewLocks(lockMgr.RenewAll))
+	}
+
 	// Daemon mode
 	interval := heartbeatInterval
 	if interval == 0 {
@@ -127,36 +142,64 @@ func runHeartbeat(cmd *cobra.Command, args []string) error {
 		close(stop)
 	}()
 
-	agentMgr.RunHeartbeat(agentID, time.Duration(interval)*time.Second, stop)
+	if remoteServer() != "" {
+		runRemoteHeartbeat(client, agentID, time.Duration(interval)*time.Second, stop)
+	} else {
+		agentMgr.RunHeartbeat(agentID, time.Duration(interval)*time.Second, stop, opts...)
+	}
 	fmt.Println("Heartbeat daemon stopped")
 
 	return nil
 }
 
 func runDeregister(cmd *cobra.Command, args []string) error {
-	agentID := deregisterAgentID
-	if agentID == "" {
-		agentID = os.Getenv("CLAUDE_SESSION_ID")
-		if agentID == "" {
-			return fmt.Errorf("agent ID required")
-		}
+	identity := agent.ResolveIdentity(coordDir, deregisterAgentID)
+	if identity.Source == agent.SourceGenerated {
+		return fmt.Errorf("agent ID required")
+	}
+	agentID := identity.ID
+
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+	releaseAll := lock.NewManager(coordDir, cfg).ReleaseAll
+	deregister := agent.NewManager(coordDir, cfg).Deregister
+	if client != nil {
+		releaseAll = func(agentID string) error { return client.Unlock(nil, agentID, true) }
+		deregister = client.Deregister
 	}
 
 	// Release locks if requested
 	if deregisterRelease {
-		lockMgr := lock.NewManager(coordDir, cfg)
-		if err := lockMgr.ReleaseAll(agentID); err != nil {
+		if err := releaseAll(agentID); err != nil {
 			fmt.Printf("⚠ Warning: failed to release some locks: %v\n", err)
 		} else {
 			fmt.Printf("✓ Released all locks for: %s\n", agentID)
 		}
 	}
 
-	agentMgr := agent.NewManager(coordDir, cfg)
-	if err := agentMgr.Deregister(agentID); err != nil {
+	if err := deregister(agentID); err != nil {
 		return err
 	}
 
 	fmt.Printf("✓ Deregistered agent: %s\n", agentID)
 	return nil
 }
+
+// runRemoteHeartbeat sends heartbeats to the coordination server until stop
+// is closed. A missed heartbeat is retried at the next interval.
+func runRemoteHeartbeat(client *daemon.Client, agentID string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := client.Heartbeat(agentID, nil, heartbeatRenewLocks); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠ Warning: heartbeat failed: %v\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -2,11 +2,9 @@ package cli
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
-	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/cache"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
 )
@@ -24,7 +22,7 @@ var checkCmd = &cobra.Command{
 	Long: `Check if one or more files match a protected pattern and if they're currently locked.
 
 With --acquire, automatically acquire locks for protected files that aren't locked.
-Exit code is non-zero if any file is locked by another agent.`,
+Exit code is 2 if any file is locked by another agent, and 1 for other errors.`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runCheck,
 }
@@ -39,15 +37,71 @@ func init() {
 
 func runCheck(cmd *cobra.Command, args []string) error {
 	// Get agent ID
-	agentID := checkAgentID
-	if agentID == "" {
-		agentID = os.Getenv("CLAUDE_SESSION_ID")
-		if agentID == "" {
-			agentID = agent.GenerateID()
+	agentID := resolveAgentID(checkAgentID)
+
+	lockMgr := lock.NewManager(coordDir, cfg)
+
+	// Handle space/comma separated file lists (from hooks)
+	var files []string
+	for _, arg := range args {
+		files = append(files, splitFiles(arg)...)
+	}
+
+	results, err := checkFiles(lockMgr, files, checkAcquire, agentID, checkAgentName, checkOperation)
+	if err != nil {
+		return err
+	}
+	acquired, blocked := describeFileChecks(results)
+
+	if structured() {
+		out := &checkOutput{
+			outputHeader: header("check"),
+			AgentID:      agentID,
+			Files:        emptyIfNil(results),
+			Blocked:      len(blocked),
+		}
+		if err := printStructured(out); err != nil {
+			return err
+		}
+		if len(blocked) > 0 {
+			return blockedError(len(blocked))
 		}
+		return nil
 	}
 
-	lockMgr := lock.NewManager(coordDir, cfg)
+	// Only output when something notable happens
+	if len(acquired) > 0 {
+		fmt.Printf("✓ Acquired locks for: %s\n", strings.Join(acquired, ", "))
+	}
+
+	if len(blocked) > 0 {
+		fmt.Println("✗ Blocked:")
+		for _, b := range blocked {
+			fmt.Printf("  • %s\n", b)
+		}
+		return blockedError(len(blocked))
+	}
+
+	return nil
+}
+
+// blockedError is check's error when files are locked by other agents
+func blockedError(n int) error {
+	return &lock.Error{Code: lock.CodeLocked, Message: fmt.Sprintf("blocked by %d lock(s)", n)}
+}
+
+// checkFiles checks each file for locks held by other agents, acquiring
+// locks on unlocked protected files if acquire is set. It returns the
+// results for protected files.
+func checkFiles(lockMgr *lock.Manager, files []string, acquire bool, agentID, agentName, operation string) ([]lock.FileCheck, error) {
+	// The daemon keeps its own cache and only returns protected files
+	client, err := daemonClient()
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		return client.Check(files, acquire, agentID, agentName, operation)
+	}
 
 	// Load cache for fast "not protected" lookups
 	checkCache := cache.Load(coordDir)
@@ -56,46 +110,26 @@ func runCheck(cmd *cobra.Command, args []string) error {
 	}
 	cacheModified := false
 
-	var blocked []string
-	var acquired []string
-
-	for _, file := range args {
-		// Handle space/comma separated file lists (from hooks)
-		files := splitFiles(file)
-		for _, f := range files {
-			// Fast path: check cache first
-			if checkCache.IsNotProtected(f) {
-				continue // Skip - we know this file isn't protected
-			}
-
-			if checkAcquire {
-				existingLock, err := lockMgr.CheckOrAcquire(f, agentID, checkAgentName, checkOperation)
-				if err != nil {
-					// Blocked by another agent
-					blocked = append(blocked, fmt.Sprintf("%s (locked by %s: %s)",
-						f, existingLock.AgentID, existingLock.Operation))
-				} else if existingLock != nil {
-					acquired = append(acquired, f)
-				} else {
-					// Not protected - cache it
-					checkCache.MarkNotProtected(f)
-					cacheModified = true
-				}
-			} else {
-				existingLock, protected, err := lockMgr.Check(f)
-				if err != nil {
-					return err
-				}
-				if !protected {
-					// Not protected - cache it
-					checkCache.MarkNotProtected(f)
-					cacheModified = true
-				} else if existingLock != nil && existingLock.AgentID != agentID {
-					blocked = append(blocked, fmt.Sprintf("%s (locked by %s: %s)",
-						f, existingLock.AgentID, existingLock.Operation))
-				}
-			}
+	var results []lock.FileCheck
+
+	for _, f := range files {
+		// Fast path: check cache first
+		if checkCache.IsNotProtected(f) {
+			continue // Skip - we know this file isn't protected
+		}
+
+		result, err := lockMgr.CheckFile(f, acquire, agentID, agentName, operation)
+		if err != nil {
+			return results, err
 		}
+		if !result.Protected {
+			// Not protected - cache it
+			checkCache.MarkNotProtected(f)
+			cacheModified = true
+			continue
+		}
+
+		results = append(results, *result)
 	}
 
 	// Save cache if modified
@@ -103,20 +137,26 @@ func runCheck(cmd *cobra.Command, args []string) error {
 		checkCache.Save()
 	}
 
-	// Only output when something notable happens
-	if len(acquired) > 0 {
-		fmt.Printf("✓ Acquired locks for: %s\n", strings.Join(acquired, ", "))
-	}
+	return results, nil
+}
 
-	if len(blocked) > 0 {
-		fmt.Println("✗ Blocked:")
-		for _, b := range blocked {
-			fmt.Printf("  • %s\n", b)
+// describeFileChecks formats check results as the lists of files acquired
+// and blocked, with who is blocking them
+func describeFileChecks(results []lock.FileCheck) (acquired, blocked []string) {
+	for _, c := range results {
+		switch {
+		case c.Blocker != nil:
+			blocked = append(blocked, fmt.Sprintf("%s (locked by %s: %s)",
+				c.File, c.Blocker.AgentID, c.Blocker.Operation))
+		case c.Error != "":
+			blocked = append(blocked, fmt.Sprintf("%s (%s)", c.File, c.Error))
+		case c.Acquired && c.Token != 0:
+			acquired = append(acquired, fmt.Sprintf("%s (token %d)", c.File, c.Token))
+		case c.Acquired:
+			acquired = append(acquired, c.File)
 		}
-		return fmt.Errorf("blocked by %d lock(s)", len(blocked))
 	}
-
-	return nil
+	return acquired, blocked
 }
 
 func splitFiles(input string) []string {
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/daemon"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+var (
+	daemonStop bool
+	noDaemon   bool
+	serverURL  string
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the coordination daemon",
+	Long: `Run a long-lived daemon that serves lock, check, status and wait requests
+over a Unix domain socket in the coord dir.
+
+The daemon keeps the config, the files known not to be protected and the
+current locks in memory, so checks from hooks don't have to re-read the
+coord dir on every tool call. While it runs, other commands send their
+requests to it; without it, they work on the files directly, as usual.
+
+Lock files are still written for every change, so agents using the daemon
+and agents that aren't interoperate. Pass --no-daemon to any command to
+bypass a running daemon.`,
+	Args:         cobra.NoArgs,
+	RunE:         runDaemon,
+	SilenceUsage: true,
+}
+
+func init() {
+	daemonCmd.Flags().BoolVar(&daemonStop, "stop", false, "Stop a running daemon")
+	rootCmd.PersistentFlags().BoolVar(&noDaemon, "no-daemon", false, "Don't use a running daemon")
+	rootCmd.PersistentFlags().StringVar(&serverURL, "server", "", "Coordination server URL (default: settings.server)")
+	rootCmd.AddCommand(daemonCmd)
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	if daemonStop {
+		client, err := daemon.Dial(coordDir)
+		if err != nil {
+			return err
+		}
+		if err := client.Shutdown(); err != nil {
+			return fmt.Errorf("failed to stop daemon: %w", err)
+		}
+		fmt.Println("✓ Daemon stopped")
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Listening on %s\n", daemon.SocketPath(coordDir))
+	return daemon.NewServer(coordDir, cfg).ListenAndServe(ctx)
+}
+
+// daemonClient returns a client for the coordination server or the running
+// daemon, or nil to work on the coord dir directly. A server that can't be
+// reached is an error: its lock table is the only one that counts.
+func daemonClient() (*daemon.Client, error) {
+	if url := remoteServer(); url != "" {
+		client, err := daemon.DialHTTP(url, os.Getenv(daemon.TokenEnv))
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach server %s: %w", url, err)
+		}
+		return client, nil
+	}
+
+	if noDaemon {
+		return nil, nil
+	}
+	client, err := daemon.Dial(coordDir)
+	if err != nil {
+		return nil, nil
+	}
+	return client, nil
+}
+
+// readLock reads the lock on resource, through client if there is one
+func readLock(lockMgr *lock.Manager, client *daemon.Client, resource string) (*lock.Lock, error) {
+	if client == nil {
+		return lockMgr.Read(resource)
+	}
+	locks, err := client.Read([]string{resource})
+	if err != nil {
+		return nil, err
+	}
+	if len(locks) == 0 {
+		return nil, fmt.Errorf("no lock on %s", resource)
+	}
+	return &locks[0], nil
+}
+
+// readLeases returns the leases of l's holders as whoever keeps the locks
+// sees them, since staleness depends on the heartbeats it has
+func readLeases(lockMgr *lock.Manager, client *daemon.Client, l *lock.Lock) ([]lock.Lease, error) {
+	if client == nil {
+		return lockMgr.Leases(l), nil
+	}
+	status, err := client.Status()
+	if err != nil {
+		return nil, err
+	}
+	return status.Leases[l.Resource], nil
+}
+
+// remoteServer returns the coordination server to use, if any
+func remoteServer() string {
+	if serverURL != "" {
+		return serverURL
+	}
+	if cfg != nil {
+		return cfg.Settings.Server
+	}
+	return ""
+}
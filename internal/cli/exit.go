@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// Exit codes, so scripts and hooks can tell why a command failed. Claude
+// Code treats exit code 2 from a hook as a block, so it's only used when
+// another agent holds the lock.
+const (
+	ExitOK             = 0
+	ExitError          = 1 // anything not listed below
+	ExitLocked         = 2
+	ExitNotOwner       = 3
+	ExitNotLocked      = 4
+	ExitStale          = 5
+	ExitTimeout        = 6
+	ExitDeadlock       = 7
+	ExitInvalidPattern = 8
+	ExitInterrupted    = 130
+)
+
+// errInterrupted is returned by commands stopped by a signal
+var errInterrupted = errors.New("interrupted")
+
+// interrupted ends cmd after a signal without printing an error, returning
+// rather than exiting so deferred cleanup still runs
+func interrupted(cmd *cobra.Command) error {
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return errInterrupted
+}
+
+// ExitCode returns the exit code for a command that failed with err
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errInterrupted), errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, lock.ErrLocked):
+		return ExitLocked
+	case errors.Is(err, lock.ErrNotOwner):
+		return ExitNotOwner
+	case errors.Is(err, lock.ErrNotLocked):
+		return ExitNotLocked
+	case errors.Is(err, lock.ErrStale):
+		return ExitStale
+	case errors.Is(err, lock.ErrTimeout):
+		return ExitTimeout
+	case errors.Is(err, lock.ErrDeadlock):
+		return ExitDeadlock
+	case errors.Is(err, lock.ErrInvalidPattern):
+		return ExitInvalidPattern
+	}
+	return ExitError
+}
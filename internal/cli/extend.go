@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+var (
+	extendTTL     int
+	extendAgentID string
+)
+
+var extendCmd = &cobra.Command{
+	Use:   "extend <resource>",
+	Short: "Renew the lease on a lock you hold",
+	Long: `Extend a lock's lease so it doesn't go stale during long work.
+
+The lock's TTL restarts from now. Use --ttl to change its length; by default
+the lock keeps the TTL it was acquired with.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExtend,
+}
+
+func init() {
+	extendCmd.Flags().IntVar(&extendTTL, "ttl", 0, "New lock timeout in seconds (0 = keep current)")
+	extendCmd.Flags().StringVar(&extendAgentID, "agent", "", "Agent ID (default: see whoami)")
+	rootCmd.AddCommand(extendCmd)
+}
+
+func runExtend(cmd *cobra.Command, args []string) error {
+	resource := args[0]
+
+	agentID := resolveAgentID(extendAgentID)
+
+	lockMgr := lock.NewManager(coordDir, cfg)
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+
+	renew := lockMgr.Renew
+	if client != nil {
+		renew = func(resource, agentID string, ttl int) error {
+			return client.Renew([]string{resource}, agentID, ttl, false)
+		}
+	}
+	if err := renew(resource, agentID, extendTTL); err != nil {
+		return err
+	}
+
+	l, err := readLock(lockMgr, client, resource)
+	if err != nil {
+		return err
+	}
+	if structured() {
+		leases, err := readLeases(lockMgr, client, l)
+		if err != nil {
+			return fmt.Errorf("failed to read leases on %s: %w", resource, err)
+		}
+		return printStructured(&extendOutput{outputHeader: header("extend"), AgentID: agentID, Lock: newLockOutput(*l, leases)})
+	}
+	if h := l.HolderFor(agentID); h != nil {
+		fmt.Printf("✓ Extended: %s\n", resource)
+		fmt.Printf("  Expires in: %s (TTL: %ds)\n", time.Until(h.ExpiresAt()).Round(time.Second), h.TTLSeconds)
+	}
+
+	return nil
+}
@@ -12,7 +12,11 @@ var gcCmd = &cobra.Command{
 	Use:   "gc",
 	Short: "Clean up stale locks and dead agents",
 	Long: `Remove locks that have exceeded their TTL and agents that 
-haven't sent a heartbeat within the stale threshold.`,
+haven't sent a heartbeat within the stale threshold.
+
+Also breaks deadlocks between waiting agents by cancelling the most recent
+wait in each cycle, and repairs agents' lists of the locks they hold where
+they disagree with the locks themselves.`,
 	RunE: runGC,
 }
 
@@ -24,20 +28,79 @@ func runGC(cmd *cobra.Command, args []string) error {
 	lockMgr := lock.NewManager(coordDir, cfg)
 	agentMgr := agent.NewManager(coordDir, cfg)
 
+	// Rename lock files left over from the old naming scheme
+	migratedLocks, err := lockMgr.MigrateLegacy()
+	if err != nil {
+		return fmt.Errorf("failed to migrate locks: %w", err)
+	}
+
 	// Clean stale locks
 	cleanedLocks, err := lockMgr.CleanStale()
 	if err != nil {
 		return fmt.Errorf("failed to clean locks: %w", err)
 	}
 
+	// Cancel one wait in every deadlock
+	brokenWaits, err := lockMgr.BreakDeadlocks()
+	if err != nil {
+		return fmt.Errorf("failed to break deadlocks: %w", err)
+	}
+
+	// Drop queue tickets of waiters that went away
+	cleanedTickets, err := lockMgr.CleanQueues()
+	if err != nil {
+		return fmt.Errorf("failed to clean queues: %w", err)
+	}
+
 	// Clean dead agents
 	cleanedAgents, err := agentMgr.CleanStale()
 	if err != nil {
 		return fmt.Errorf("failed to clean agents: %w", err)
 	}
 
-	if len(cleanedLocks) == 0 && len(cleanedAgents) == 0 {
-		fmt.Println("✓ Nothing to clean")
+	// Repair the locks listed on the agents that are left
+	syncedAgents, err := lockMgr.SyncAgents()
+	if err != nil {
+		return fmt.Errorf("failed to sync agents: %w", err)
+	}
+
+	if structured() {
+		return printStructured(&gcOutput{
+			outputHeader:   header("gc"),
+			MigratedLocks:  emptyIfNil(migratedLocks),
+			CleanedLocks:   emptyIfNil(cleanedLocks),
+			BrokenWaits:    emptyIfNil(brokenWaits),
+			CleanedTickets: emptyIfNil(cleanedTickets),
+			CleanedAgents:  emptyIfNil(cleanedAgents),
+			SyncedAgents:   emptyIfNil(syncedAgents),
+		})
+	}
+
+	if len(brokenWaits) > 0 {
+		fmt.Printf("✓ Broke %d deadlock(s) by cancelling waits:\n", len(brokenWaits))
+		for _, w := range brokenWaits {
+			fmt.Printf("  • %s\n", w)
+		}
+	}
+
+	if len(migratedLocks) > 0 {
+		fmt.Printf("✓ Migrated %d legacy lock file(s):\n", len(migratedLocks))
+		for _, l := range migratedLocks {
+			fmt.Printf("  • %s\n", l)
+		}
+	}
+
+	if len(syncedAgents) > 0 {
+		fmt.Printf("✓ Repaired the held locks of %d agent(s):\n", len(syncedAgents))
+		for _, a := range syncedAgents {
+			fmt.Printf("  • %s\n", a)
+		}
+	}
+
+	if len(cleanedLocks) == 0 && len(cleanedTickets) == 0 && len(cleanedAgents) == 0 {
+		if len(migratedLocks) == 0 && len(brokenWaits) == 0 && len(syncedAgents) == 0 {
+			fmt.Println("✓ Nothing to clean")
+		}
 		return nil
 	}
 
@@ -48,6 +111,13 @@ func runGC(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if len(cleanedTickets) > 0 {
+		fmt.Printf("✓ Cleaned %d abandoned queue ticket(s):\n", len(cleanedTickets))
+		for _, t := range cleanedTickets {
+			fmt.Printf("  • %s\n", t)
+		}
+	}
+
 	if len(cleanedAgents) > 0 {
 		fmt.Printf("✓ Cleaned %d dead agent(s):\n", len(cleanedAgents))
 		for _, a := range cleanedAgents {
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/hook"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hookAgentName string
+	hookOperation string
+)
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Handle Claude Code hook events",
+	Long: `Handle Claude Code hook events. Each subcommand reads the hook's JSON
+payload from stdin and uses its session_id as the agent ID, so no shell
+quoting of file paths or environment variables is needed. The session ID is
+also remembered for the Claude Code process, so claude-coord commands Claude
+runs itself act as the same agent.
+
+Wire them up in .claude/settings.json:
+
+  SessionStart  →  claude-coord hook session-start
+  PreToolUse    →  claude-coord hook pre-tool-use
+  PostToolUse   →  claude-coord hook post-tool-use
+  Stop          →  claude-coord hook stop`,
+}
+
+var hookPreToolUseCmd = &cobra.Command{
+	Use:   "pre-tool-use",
+	Short: "Lock the files a tool is about to edit",
+	Long: `Acquire locks on the protected files an Edit, Write, MultiEdit or
+NotebookEdit call is about to touch.
+
+If another agent holds one of them, reply with a decision that blocks the
+tool call and tells Claude who holds the lock. Otherwise print nothing, which
+leaves the call to Claude Code's usual permission rules.`,
+	Args:         cobra.NoArgs,
+	RunE:         runHookPreToolUse,
+	SilenceUsage: true,
+}
+
+var hookPostToolUseCmd = &cobra.Command{
+	Use:          "post-tool-use",
+	Short:        "Record edited files and renew the agent's locks",
+	Args:         cobra.NoArgs,
+	RunE:         runHookPostToolUse,
+	SilenceUsage: true,
+}
+
+var hookSessionStartCmd = &cobra.Command{
+	Use:          "session-start",
+	Short:        "Register the session as an agent",
+	Args:         cobra.NoArgs,
+	RunE:         runHookSessionStart,
+	SilenceUsage: true,
+}
+
+var hookStopCmd = &cobra.Command{
+	Use:          "stop",
+	Short:        "Release the session's locks",
+	Args:         cobra.NoArgs,
+	RunE:         runHookStop,
+	SilenceUsage: true,
+}
+
+func init() {
+	hookCmd.PersistentFlags().StringVar(&hookAgentName, "name", "", "Agent display name")
+	hookPreToolUseCmd.Flags().StringVar(&hookOperation, "op", "", "Operation description for acquired locks (default: tool name)")
+
+	hookCmd.AddCommand(hookPreToolUseCmd)
+	hookCmd.AddCommand(hookPostToolUseCmd)
+	hookCmd.AddCommand(hookSessionStartCmd)
+	hookCmd.AddCommand(hookStopCmd)
+	rootCmd.AddCommand(hookCmd)
+}
+
+// readHookInput parses the payload on stdin and works out the agent ID,
+// which is the session ID if the payload has one
+func readHookInput() (*hook.Input, string, error) {
+	in, err := hook.Read(os.Stdin)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if in.SessionID == "" {
+		return in, resolveAgentID(""), nil
+	}
+
+	// Let commands Claude runs itself, e.g. through its Bash tool, find out
+	// which session they belong to
+	agent.RememberSession(coordDir, in.SessionID)
+	return in, in.SessionID, nil
+}
+
+func runHookPreToolUse(cmd *cobra.Command, args []string) error {
+	in, agentID, err := readHookInput()
+	if err != nil {
+		return err
+	}
+
+	files := in.RelativePaths()
+	if len(files) == 0 {
+		return nil
+	}
+
+	operation := hookOperation
+	if operation == "" {
+		operation = in.ToolName
+	}
+
+	lockMgr := lock.NewManager(coordDir, cfg)
+	results, err := checkFiles(lockMgr, files, true, agentID, hookAgentName, operation)
+	if err != nil {
+		return err
+	}
+	_, blocked := describeFileChecks(results)
+	if len(blocked) == 0 {
+		return nil
+	}
+
+	reason := fmt.Sprintf("claude-coord: another agent is working on these files: %s. "+
+		"Work on something else for now, or run `claude-coord wait <resource> --acquire` to queue for the lock.",
+		strings.Join(blocked, "; "))
+	return hook.Block(reason).Write(os.Stdout)
+}
+
+func runHookPostToolUse(cmd *cobra.Command, args []string) error {
+	in, agentID, err := readHookInput()
+	if err != nil {
+		return err
+	}
+
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		return client.Heartbeat(agentID, in.RelativePaths(), true)
+	}
+
+	agentMgr := agent.NewManager(coordDir, cfg)
+	lockMgr := lock.NewManager(coordDir, cfg)
+
+	if files := in.RelativePaths(); len(files) > 0 {
+		if err := agentMgr.RecordFiles(agentID, files); err != nil {
+			return fmt.Errorf("failed to record files: %w", err)
+		}
+	} else if err := agentMgr.Heartbeat(agentID); err != nil {
+		return err
+	}
+
+	// Work is still going on; keep our locks from going stale
+	return lockMgr.RenewAll(agentID)
+}
+
+func runHookSessionStart(cmd *cobra.Command, args []string) error {
+	_, agentID, err := readHookInput()
+	if err != nil {
+		return err
+	}
+
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		return client.Resume(agentID, hookAgentName)
+	}
+
+	agentMgr := agent.NewManager(coordDir, cfg)
+
+	// A resumed session keeps its record
+	if _, err := agentMgr.Read(agentID); err == nil {
+		return agentMgr.Heartbeat(agentID)
+	}
+	return agentMgr.Register(agentID, hookAgentName)
+}
+
+func runHookStop(cmd *cobra.Command, args []string) error {
+	_, agentID, err := readHookInput()
+	if err != nil {
+		return err
+	}
+
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+	releaseAll := lock.NewManager(coordDir, cfg).ReleaseAll
+	if client != nil {
+		releaseAll = func(agentID string) error { return client.Unlock(nil, agentID, true) }
+	}
+	if err := releaseAll(agentID); err != nil {
+		return fmt.Errorf("failed to release locks: %w", err)
+	}
+	return nil
+}
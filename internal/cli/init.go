@@ -80,6 +80,10 @@ func runInit(cmd *cobra.Command, args []string) error {
 		gitignoreContent := `# Runtime files - don't commit these
 locks/
 agents/
+queue/
+identities/
+daemon.sock
+state.db*
 `
 		if err := os.WriteFile(gitignorePath, []byte(gitignoreContent), 0644); err != nil {
 			return fmt.Errorf("failed to create .gitignore: %w", err)
@@ -157,6 +161,9 @@ claude-coord unlock "db/schema/*"
 
 # Check if a specific file is protected/locked
 claude-coord check path/to/file.sql
+
+# Tell other agents and the user what you're working on
+claude-coord task set "Adding email verification"
 ` + "```" + `
 
 ### Before Modifying Protected Files
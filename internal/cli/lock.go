@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
 )
 
@@ -14,47 +18,126 @@ var (
 	lockTTL       int
 	lockAgentID   string
 	lockAgentName string
+	lockShared    bool
+	lockWait      bool
+	lockTimeout   time.Duration
 )
 
 var lockCmd = &cobra.Command{
-	Use:   "lock <resource>",
-	Short: "Acquire a lock on a resource",
+	Use:   "lock <resource> [resource...]",
+	Short: "Acquire a lock on one or more resources",
 	Long: `Acquire an exclusive lock on a resource pattern.
 
-The resource should match a pattern from config.yaml, e.g., "db/schema/*".
-The lock prevents other agents from modifying files matching this pattern.`,
-	Args: cobra.ExactArgs(1),
+When several resources are given, they are locked all-or-nothing: if any of
+them is unavailable, none are held when the command returns.
+
+With --shared, take a shared (read) lock instead. Any number of agents can
+hold a shared lock at once, but an exclusive lock is refused while shared
+holders remain, and vice versa.
+
+With --wait, block until the locks are granted instead of failing while
+another agent holds them, giving up after --timeout. The locks are retried
+as soon as any lock is released. Unlike "wait --acquire", this doesn't
+queue: agents already waiting in line go first.
+
+The resource should match a pattern from config.yaml, e.g., "db/schema/*",
+or name a logical resource such as "auth-system", which locks every file
+group listed under it. The lock prevents other agents from modifying files
+matching this pattern.`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: runLock,
 }
 
 func init() {
 	lockCmd.Flags().StringVar(&lockOperation, "op", "", "Description of what you're doing")
 	lockCmd.Flags().IntVar(&lockTTL, "ttl", 0, "Lock timeout in seconds (0 = use default)")
-	lockCmd.Flags().StringVar(&lockAgentID, "agent", "", "Agent ID (default: auto-generated)")
+	lockCmd.Flags().StringVar(&lockAgentID, "agent", "", "Agent ID (default: see whoami)")
 	lockCmd.Flags().StringVar(&lockAgentName, "name", "", "Agent display name")
+	lockCmd.Flags().BoolVar(&lockShared, "shared", false, "Take a shared (read) lock")
+	lockCmd.Flags().BoolVar(&lockWait, "wait", false, "Wait until the locks are granted")
+	lockCmd.Flags().DurationVar(&lockTimeout, "timeout", 5*time.Minute, "With --wait, how long to wait, e.g. 30s or 5m (0 = forever)")
 	rootCmd.AddCommand(lockCmd)
 }
 
 func runLock(cmd *cobra.Command, args []string) error {
-	resource := args[0]
-
 	// Get or generate agent ID
-	agentID := lockAgentID
-	if agentID == "" {
-		agentID = os.Getenv("CLAUDE_SESSION_ID")
-		if agentID == "" {
-			agentID = agent.GenerateID()
-		}
-	}
+	agentID := resolveAgentID(lockAgentID)
 
 	lockMgr := lock.NewManager(coordDir, cfg)
 
-	if err := lockMgr.Acquire(resource, agentID, lockAgentName, lockOperation, lockTTL); err != nil {
+	mode := lock.Exclusive
+	if lockShared {
+		mode = lock.Shared
+	}
+
+	client, err := daemonClient()
+	if err != nil {
 		return err
 	}
+	acquire := lockMgr.AcquireAll
+	if client != nil {
+		acquire = client.Lock
+	}
+	if lockWait {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		if lockTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, lockTimeout)
+			defer cancel()
+		}
 
-	fmt.Printf("✓ Locked: %s\n", resource)
+		acquireContext := lockMgr.AcquireContext
+		if client != nil {
+			acquireContext = client.LockContext
+		}
+		acquire = func(resources []string, mode lock.Mode, agentID, agentName, operation string, ttl int) error {
+			return acquireContext(ctx, resources, mode, agentID, agentName, operation, ttl)
+		}
+	}
+	if err := acquire(args, mode, agentID, lockAgentName, lockOperation, lockTTL); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return interrupted(cmd)
+		}
+		return err
+	}
+
+	if structured() {
+		out := &lockResultOutput{outputHeader: header("lock"), AgentID: agentID, Locks: []lockOutput{}}
+		for _, resource := range args {
+			l, err := readLock(lockMgr, client, resource)
+			if err != nil {
+				return fmt.Errorf("failed to read lock on %s: %w", resource, err)
+			}
+			leases, err := readLeases(lockMgr, client, l)
+			if err != nil {
+				return fmt.Errorf("failed to read leases on %s: %w", resource, err)
+			}
+			out.Locks = append(out.Locks, newLockOutput(*l, leases))
+		}
+		return printStructured(out)
+	}
+
+	fmt.Printf("✓ Locked: %s\n", strings.Join(args, ", "))
 	fmt.Printf("  Agent:  %s\n", agentID)
+	if mode == lock.Shared {
+		fmt.Printf("  Mode:   %s\n", mode)
+	}
+	for _, resource := range args {
+		if logical := cfg.FindLogical(resource); logical != nil {
+			fmt.Printf("  Files:  %s (%s)\n", strings.Join(logical.Files, ", "), resource)
+		}
+	}
+	for _, resource := range args {
+		if l, err := readLock(lockMgr, client, resource); err == nil {
+			if h := l.HolderFor(agentID); h != nil {
+				fmt.Printf("  Token:  %d (%s)\n", h.Token, resource)
+				if h.HoldCount() > 1 {
+					fmt.Printf("  Holds:  %d (%s)\n", h.HoldCount(), resource)
+				}
+			}
+		}
+	}
 	if lockOperation != "" {
 		fmt.Printf("  Task:   %s\n", lockOperation)
 	}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/mcp"
+)
+
+var (
+	mcpAgentID   string
+	mcpAgentName string
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Run an MCP server exposing coordination tools",
+	Long: `Run a Model Context Protocol server over stdio, so agents can coordinate
+through structured tool calls: coord_status, coord_lock, coord_unlock,
+coord_check, coord_wait and coord_extend.
+
+Each MCP connection is one agent. Its ID is resolved like every other
+command's (see 'claude-coord whoami'), so it matches the Claude Code session
+that started the server, and stays the same for every tool call. The agent
+is named after the MCP client unless --name is given. Its locks are renewed
+while the server runs, and released when the client disconnects.
+
+The MCP server works on the local coord dir only, so it refuses to start
+when a coordination server is configured with --server or settings.server.
+
+Add it to Claude Code with:
+
+  claude mcp add claude-coord -- claude-coord mcp`,
+	Args:         cobra.NoArgs,
+	RunE:         runMCP,
+	SilenceUsage: true,
+}
+
+func init() {
+	mcpCmd.Flags().StringVar(&mcpAgentID, "agent", "", "Agent ID (default: see whoami)")
+	mcpCmd.Flags().StringVar(&mcpAgentName, "name", "", "Agent display name (default: MCP client name)")
+	rootCmd.AddCommand(mcpCmd)
+}
+
+func runMCP(cmd *cobra.Command, args []string) error {
+	// Locks taken here would be invisible to agents using the server
+	if server := remoteServer(); server != "" {
+		return fmt.Errorf("mcp can't be used with a coordination server (%s); run it where the coord dir is", server)
+	}
+
+	// The session's identity is resolved once, when the server starts. A
+	// one-off ID is left for the coordinator to generate, so it knows the
+	// agent is its own to deregister.
+	agentID := ""
+	if identity := agent.ResolveIdentity(coordDir, mcpAgentID); identity.Source != agent.SourceGenerated {
+		agentID = identity.ID
+	}
+
+	coordinator := mcp.NewCoordinator(coordDir, cfg, agentID, mcpAgentName)
+	defer coordinator.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// stdout carries the protocol; anything else must go to stderr
+	err := coordinator.Server(version).Serve(ctx, os.Stdin, os.Stdout)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/daemon"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
+)
+
+var migrateBackendCmd = &cobra.Command{
+	Use:   "migrate-backend <file|sqlite|redis>",
+	Short: "Move lock and agent state to another storage backend",
+	Long: `Copy the live locks, fencing tokens and agents from the current storage
+backend to another one, then switch config.yaml over to it.
+
+The file backend keeps one file per lock and agent in the coord dir. The
+sqlite backend keeps them in state.db, where multi-resource locks are taken
+in a single transaction and every lock change is recorded. Released binaries
+include it; builds from source need cgo.
+
+The redis backend keeps them on the server named by settings.redis_url, so
+agents on different hosts share locks. Migrating replaces whatever the
+server holds under settings.redis_prefix, so migrate from one host only.
+
+Changes agents make while the state is being copied can be lost, so run it
+while agents are idle. The daemon must be stopped first.`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runMigrateBackend,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateBackendCmd)
+}
+
+func runMigrateBackend(cmd *cobra.Command, args []string) error {
+	target := args[0]
+	current := cfg.Settings.Backend
+	if current == "" {
+		current = storage.BackendFile
+	}
+	if target == current {
+		return fmt.Errorf("already using the %s backend", current)
+	}
+
+	// The daemon would keep serving from the old backend
+	if client, err := daemon.Dial(coordDir); err == nil {
+		client.Close()
+		return fmt.Errorf("the daemon is running; stop it with 'claude-coord daemon --stop' first")
+	}
+
+	src, err := storage.OpenBackend(coordDir, cfg, current)
+	if err != nil {
+		return fmt.Errorf("failed to open %s backend: %w", current, err)
+	}
+	defer src.Close()
+
+	dst, err := storage.OpenBackend(coordDir, cfg, target)
+	if err != nil {
+		return fmt.Errorf("failed to open %s backend: %w", target, err)
+	}
+	defer dst.Close()
+
+	copied, err := storage.Copy(dst, src)
+	if err != nil {
+		return fmt.Errorf("failed to copy state: %w", err)
+	}
+
+	cfg.Settings.Backend = target
+	if err := cfg.Save(coordDir); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	// Only now that nobody reads the old backend, clear it out. Entries
+	// that changed since they were copied are left for inspection.
+	var changed []string
+	for _, ns := range storage.Namespaces {
+		for _, e := range copied[ns] {
+			if err := src.CompareAndDelete(ns, e.Key, e.Value); err != nil {
+				changed = append(changed, fmt.Sprintf("%s/%s", ns, e.Key))
+			}
+		}
+	}
+
+	fmt.Printf("✓ Switched from %s to %s backend\n", current, target)
+	fmt.Printf("  Locks:  %d\n", len(copied[storage.Locks]))
+	fmt.Printf("  Agents: %d\n", len(copied[storage.Agents]))
+	if len(changed) > 0 {
+		fmt.Printf("⚠ Changed during migration, left in the %s backend:\n", current)
+		for _, c := range changed {
+			fmt.Printf("  • %s\n", c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// OutputVersion is the version of the structures --output json and yaml
+// emit. Fields may be added within a version; it's bumped when one is
+// removed or changes meaning.
+const OutputVersion = 1
+
+var outputFormat string
+
+// printed is set once a command has written its structured output, so a
+// failure after it, such as check's for blocked files, isn't reported twice
+var printed bool
+
+// structuredCommands are the commands that can print JSON or YAML
+var structuredCommands = map[*cobra.Command]bool{
+	statusCmd:   true,
+	checkCmd:    true,
+	lockCmd:     true,
+	unlockCmd:   true,
+	gcCmd:       true,
+	waitCmd:     true,
+	whoamiCmd:   true,
+	taskShowCmd: true,
+	verifyCmd:   true,
+	extendCmd:   true,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text, json or yaml")
+}
+
+// checkOutputFormat fails for an unknown format, or one cmd can't print
+func checkOutputFormat(cmd *cobra.Command) error {
+	switch outputFormat {
+	case outputText:
+		return nil
+	case outputJSON, outputYAML:
+		if !structuredCommands[cmd] {
+			return fmt.Errorf("%s doesn't support --output %s", cmd.Name(), outputFormat)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (want text, json or yaml)", outputFormat)
+}
+
+// structured reports whether output is for programs rather than people
+func structured() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// outputHeader starts every structured output, saying what follows
+type outputHeader struct {
+	Version int    `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+func header(kind string) outputHeader {
+	return outputHeader{Version: OutputVersion, Kind: kind}
+}
+
+// lockOutput is a lock with the state of its leases
+type lockOutput struct {
+	lock.Lock
+	Stale  bool         `json:"stale"`
+	Leases []lock.Lease `json:"leases"`
+}
+
+func newLockOutput(l lock.Lock, leases []lock.Lease) lockOutput {
+	return lockOutput{Lock: l, Stale: allStale(leases), Leases: emptyIfNil(leases)}
+}
+
+// agentOutput is an agent with whether it's still sending heartbeats
+type agentOutput struct {
+	agent.Agent
+	Alive           bool `json:"alive"`
+	LastSeenSeconds int  `json:"last_seen_seconds"`
+
+	// TaskStartedAt replaces the agent's, leaving it out when there's no task
+	TaskStartedAt *time.Time `json:"task_started_at,omitempty"`
+}
+
+// statusOutput is what status prints
+type statusOutput struct {
+	outputHeader
+	Locks     []lockOutput             `json:"locks"`
+	Queues    map[string][]lock.Ticket `json:"queues"`
+	Deadlocks []lock.Deadlock          `json:"deadlocks"`
+	Agents    []agentOutput            `json:"agents"`
+}
+
+// checkOutput is what check prints: the protected files among those
+// checked, with who holds any that are blocked
+type checkOutput struct {
+	outputHeader
+	AgentID string           `json:"agent_id"`
+	Files   []lock.FileCheck `json:"files"`
+	Blocked int              `json:"blocked"`
+}
+
+// lockResultOutput is what lock prints
+type lockResultOutput struct {
+	outputHeader
+	AgentID string       `json:"agent_id"`
+	Locks   []lockOutput `json:"locks"`
+}
+
+// unlockOutput is what unlock prints
+type unlockOutput struct {
+	outputHeader
+	AgentID  string   `json:"agent_id"`
+	Released []string `json:"released"`
+
+	// Holds is how many holds the agent still has on a resource it
+	// released one hold on
+	Holds int `json:"holds,omitempty"`
+}
+
+// waitOutput is what wait prints once the resource is available
+type waitOutput struct {
+	outputHeader
+	Resource      string      `json:"resource"`
+	AgentID       string      `json:"agent_id"`
+	Acquired      bool        `json:"acquired"`
+	WaitedSeconds float64     `json:"waited_seconds"`
+	Lock          *lockOutput `json:"lock,omitempty"`
+}
+
+// gcOutput is what gc prints
+type gcOutput struct {
+	outputHeader
+	MigratedLocks  []string `json:"migrated_locks"`
+	CleanedLocks   []string `json:"cleaned_locks"`
+	BrokenWaits    []string `json:"broken_waits"`
+	CleanedTickets []string `json:"cleaned_tickets"`
+	CleanedAgents  []string `json:"cleaned_agents"`
+	SyncedAgents   []string `json:"synced_agents"`
+}
+
+// whoamiOutput is what whoami prints
+type whoamiOutput struct {
+	outputHeader
+	AgentID string               `json:"agent_id"`
+	Source  agent.IdentitySource `json:"source"`
+	Detail  string               `json:"detail"`
+}
+
+// taskOutput is what task show prints. The task fields are empty for an
+// agent that isn't registered.
+type taskOutput struct {
+	outputHeader
+	AgentID       string       `json:"agent_id"`
+	Task          string       `json:"task"`
+	TaskStartedAt *time.Time   `json:"task_started_at,omitempty"`
+	TaskAuto      bool         `json:"task_auto"`
+	History       []agent.Task `json:"history"`
+}
+
+// verifyOutput is what verify prints when the token is current
+type verifyOutput struct {
+	outputHeader
+	Resource string `json:"resource"`
+	Token    uint64 `json:"token"`
+	Current  bool   `json:"current"`
+}
+
+// extendOutput is what extend prints
+type extendOutput struct {
+	outputHeader
+	AgentID string     `json:"agent_id"`
+	Lock    lockOutput `json:"lock"`
+}
+
+// errorOutput is what a command that fails prints instead of its output.
+// Code is the kind of error, as in lock's error codes, and Locked says who
+// holds the lock for a locked error.
+type errorOutput struct {
+	outputHeader
+	Code     string            `json:"code,omitempty"`
+	ExitCode int               `json:"exit_code"`
+	Message  string            `json:"message"`
+	Locked   *lock.LockedError `json:"locked,omitempty"`
+}
+
+func newErrorOutput(err error) errorOutput {
+	out := errorOutput{
+		outputHeader: header("error"),
+		Code:         lock.ErrorCode(err),
+		ExitCode:     ExitCode(err),
+		Message:      err.Error(),
+	}
+	var locked *lock.LockedError
+	if errors.As(err, &locked) {
+		out.Locked = locked
+	}
+	return out
+}
+
+// printStructured writes v to stdout in the selected format. YAML uses the
+// same field names as JSON.
+func printStructured(v any) error {
+	printed = true
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	if outputFormat == outputJSON {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+
+	// JSON is YAML; decoding it into a node keeps the field order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	blockStyle(&node)
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return enc.Close()
+}
+
+// blockStyle drops the JSON styling of a decoded node, so it's written as
+// plain block YAML
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// emptyIfNil keeps lists in structured output from coming out as null
+func emptyIfNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+// timeOrNil leaves the zero time out of structured output
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// seconds converts d for structured output
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -27,7 +28,14 @@ Claude Code agents working in the same codebase.
 
 It prevents conflicts by allowing agents to lock resources before 
 modifying them, and checking for existing locks before proceeding.`,
+	// Failures such as a lock being held are normal results with their own
+	// exit codes, so they're reported without the usage text
+	SilenceUsage: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(cmd); err != nil {
+			return err
+		}
+
 		// Skip config loading for init command
 		if cmd.Name() == "init" {
 			return nil
@@ -66,6 +74,13 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+// Execute runs the command line. With --output json or yaml, a failure is
+// also printed as an error document, unless the command printed its output
+// before failing.
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	if err != nil && structured() && !printed && !errors.Is(err, errInterrupted) {
+		printStructured(newErrorOutput(err))
+	}
+	return err
 }
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/daemon"
+)
+
+var (
+	serveListen string
+	serveToken  string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the lock table over HTTP",
+	Long: `Run a coordination server that exposes lock and agent operations as a JSON
+HTTP API, so agents in separate VMs or containers that don't share a
+filesystem can share one authoritative lock table.
+
+Point agents at it with --server or settings.server in config.yaml, e.g.
+http://host.docker.internal:7878. Every request must carry the server's
+access token, which clients read from $CLAUDE_COORD_TOKEN. Pass --token or
+set $CLAUDE_COORD_TOKEN here too; without either a token is generated and
+printed.
+
+Waits are long polls that stream progress until the lock is free. The
+server drops locks and agents that went stale on its own, since remote
+agents can vanish without releasing anything.`,
+	Args:         cobra.NoArgs,
+	RunE:         runServe,
+	SilenceUsage: true,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:7878", "Address to listen on (e.g. :7878 for all interfaces)")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "Access token clients must send (default: $CLAUDE_COORD_TOKEN)")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	token := serveToken
+	if token == "" {
+		token = os.Getenv(daemon.TokenEnv)
+	}
+	if token == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("failed to generate token: %w", err)
+		}
+		token = hex.EncodeToString(buf)
+		fmt.Printf("Token: %s\n", token)
+		fmt.Printf("  export %s=%s on each client\n", daemon.TokenEnv, token)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Listening on %s\n", serveListen)
+	return daemon.NewServer(coordDir, cfg).ListenAndServeHTTP(ctx, serveListen, token)
+}
@@ -2,10 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/daemon"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
 )
 
@@ -24,17 +27,14 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	lockMgr := lock.NewManager(coordDir, cfg)
 	agentMgr := agent.NewManager(coordDir, cfg)
 
-	// Get locks
-	locks, err := lockMgr.List()
+	status, err := loadStatus(lockMgr, agentMgr)
 	if err != nil {
-		return fmt.Errorf("failed to list locks: %w", err)
+		return err
 	}
-
-	// Get agents
-	agents, err := agentMgr.List()
-	if err != nil {
-		return fmt.Errorf("failed to list agents: %w", err)
+	if structured() {
+		return printStructured(newStatusOutput(status, agentMgr))
 	}
+	locks, queues, deadlocks, agents := status.Locks, status.Queues, status.Deadlocks, status.Agents
 
 	// Display locks
 	fmt.Println("LOCKS")
@@ -44,23 +44,67 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	} else {
 		for _, l := range locks {
 			stale := ""
-			if lockMgr.IsStale(&l) {
+			if allStale(status.Leases[l.Resource]) {
 				stale = " [STALE]"
 			}
-			age := time.Since(l.AcquiredAt).Round(time.Second)
-			fmt.Printf("  • %s%s\n", l.Resource, stale)
-			fmt.Printf("    Agent: %s", l.AgentID)
-			if l.AgentName != "" {
-				fmt.Printf(" (%s)", l.AgentName)
+			if l.IsShared() {
+				fmt.Printf("  • %s [SHARED]%s\n", l.Resource, stale)
+			} else {
+				fmt.Printf("  • %s%s\n", l.Resource, stale)
+			}
+			if len(l.Files) > 0 {
+				fmt.Printf("    Files: %s\n", strings.Join(l.Files, ", "))
 			}
-			fmt.Println()
-			if l.Operation != "" {
-				fmt.Printf("    Task:  %s\n", l.Operation)
+			for _, h := range l.AllHolders() {
+				printHolder(h)
 			}
-			fmt.Printf("    Age:   %s (TTL: %ds)\n", age, l.TTLSeconds)
 		}
 	}
 
+	if len(queues) > 0 {
+		fmt.Println()
+		fmt.Println("WAITING")
+		fmt.Println("───────")
+		resources := make([]string, 0, len(queues))
+		for r := range queues {
+			resources = append(resources, r)
+		}
+		sort.Strings(resources)
+		for _, r := range resources {
+			fmt.Printf("  • %s\n", r)
+			pos := 0
+			for _, t := range queues[r] {
+				if t.Watch {
+					fmt.Printf("    -  %s", t.AgentID)
+				} else {
+					pos++
+					fmt.Printf("    %d. %s", pos, t.AgentID)
+				}
+				if t.AgentName != "" {
+					fmt.Printf(" (%s)", t.AgentName)
+				}
+				if t.Mode == lock.Shared {
+					fmt.Print(" [SHARED]")
+				}
+				fmt.Printf(" - waiting %s", time.Since(t.EnqueuedAt).Round(time.Second))
+				if t.Operation != "" {
+					fmt.Printf(": %s", t.Operation)
+				}
+				fmt.Println()
+			}
+		}
+	}
+
+	if len(deadlocks) > 0 {
+		fmt.Println()
+		fmt.Println("DEADLOCKS")
+		fmt.Println("─────────")
+		for _, d := range deadlocks {
+			fmt.Printf("  • %s\n", d)
+		}
+		fmt.Println("    Run 'claude-coord gc' to cancel one of the waits")
+	}
+
 	fmt.Println()
 
 	// Display agents
@@ -82,13 +126,114 @@ func runStatus(cmd *cobra.Command, args []string) error {
 			fmt.Printf(" [%s]\n", status)
 			fmt.Printf("    Last seen: %s ago\n", lastSeen)
 			if a.CurrentTask != "" {
-				fmt.Printf("    Task: %s\n", a.CurrentTask)
+				fmt.Printf("    Task: %s\n", describeTask(&a))
 			}
 			if len(a.LocksHeld) > 0 {
-				fmt.Printf("    Locks: %v\n", a.LocksHeld)
+				fmt.Printf("    Locks: %s\n", strings.Join(a.LocksHeld, ", "))
 			}
 		}
 	}
 
 	return nil
 }
+
+// loadStatus gathers what status shows, from the daemon if one is running
+func loadStatus(lockMgr *lock.Manager, agentMgr *agent.Manager) (*daemon.Status, error) {
+	client, err := daemonClient()
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		return client.Status()
+	}
+
+	// Get locks
+	locks, err := lockMgr.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locks: %w", err)
+	}
+
+	// Get wait queues
+	queues, err := lockMgr.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	// Get agents waiting on each other in a loop
+	deadlocks, err := lockMgr.Deadlocks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for deadlocks: %w", err)
+	}
+
+	// Get agents
+	agents, err := agentMgr.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	return &daemon.Status{
+		Locks:     locks,
+		Leases:    daemon.LeasesFor(lockMgr, locks),
+		Agents:    agents,
+		Queues:    queues,
+		Deadlocks: deadlocks,
+	}, nil
+}
+
+// newStatusOutput converts status for --output
+func newStatusOutput(status *daemon.Status, agentMgr *agent.Manager) *statusOutput {
+	out := &statusOutput{
+		outputHeader: header("status"),
+		Locks:        []lockOutput{},
+		Queues:       status.Queues,
+		Deadlocks:    emptyIfNil(status.Deadlocks),
+		Agents:       []agentOutput{},
+	}
+	if out.Queues == nil {
+		out.Queues = map[string][]lock.Ticket{}
+	}
+	for _, l := range status.Locks {
+		out.Locks = append(out.Locks, newLockOutput(l, status.Leases[l.Resource]))
+	}
+	for _, a := range status.Agents {
+		out.Agents = append(out.Agents, agentOutput{
+			Agent:           a,
+			Alive:           agentMgr.IsAlive(&a),
+			LastSeenSeconds: int(time.Since(a.LastHeartbeat).Round(time.Second) / time.Second),
+			TaskStartedAt:   timeOrNil(a.TaskStartedAt),
+		})
+	}
+	return out
+}
+
+// allStale reports whether every lease on a lock has gone stale
+func allStale(leases []lock.Lease) bool {
+	for _, l := range leases {
+		if !l.Stale {
+			return false
+		}
+	}
+	return len(leases) > 0
+}
+
+func printHolder(h lock.Holder) {
+	age := time.Since(h.AcquiredAt).Round(time.Second)
+	fmt.Printf("    Agent: %s", h.AgentID)
+	if h.AgentName != "" {
+		fmt.Printf(" (%s)", h.AgentName)
+	}
+	fmt.Println()
+	if h.Operation != "" {
+		fmt.Printf("    Task:  %s\n", h.Operation)
+	}
+	fmt.Printf("    Age:   %s (TTL: %ds)\n", age, h.TTLSeconds)
+	if h.Token != 0 {
+		fmt.Printf("    Token: %d\n", h.Token)
+	}
+	if h.HoldCount() > 1 {
+		fmt.Printf("    Holds: %d\n", h.HoldCount())
+	}
+	if h.RenewedAt != nil {
+		fmt.Printf("    Renewed: %s ago\n", time.Since(*h.RenewedAt).Round(time.Second))
+	}
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+)
+
+var taskAgentID string
+
+var taskCmd = &cobra.Command{
+	Use:   "task",
+	Short: "Report what this agent is working on",
+	Long: `Set, clear or show the task status lists for this agent, so anyone
+glancing at status can see what every agent is doing.
+
+Agents that haven't set a task show one made from the locks they hold and
+what they took them for, e.g. from the hooks. A task set here takes its
+place until it's cleared. Previous tasks are kept with when they started
+and ended; see task show.`,
+}
+
+var taskSetCmd = &cobra.Command{
+	Use:   "set <task>",
+	Short: "Set this agent's current task",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runTaskSet,
+}
+
+var taskClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear this agent's current task",
+	Args:  cobra.NoArgs,
+	RunE:  runTaskClear,
+}
+
+var taskShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show this agent's current and previous tasks",
+	Args:  cobra.NoArgs,
+	RunE:  runTaskShow,
+}
+
+func init() {
+	taskCmd.PersistentFlags().StringVar(&taskAgentID, "agent", "", "Agent ID (default: see whoami)")
+
+	taskCmd.AddCommand(taskSetCmd)
+	taskCmd.AddCommand(taskClearCmd)
+	taskCmd.AddCommand(taskShowCmd)
+	rootCmd.AddCommand(taskCmd)
+}
+
+func runTaskSet(cmd *cobra.Command, args []string) error {
+	task := strings.Join(args, " ")
+	if strings.TrimSpace(task) == "" {
+		return fmt.Errorf("task can't be empty; use task clear")
+	}
+	agentID := resolveAgentID(taskAgentID)
+	if err := setTask(agentID, task); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Task: %s\n", task)
+	fmt.Printf("  Agent: %s\n", agentID)
+	return nil
+}
+
+func runTaskClear(cmd *cobra.Command, args []string) error {
+	agentID := resolveAgentID(taskAgentID)
+	if err := setTask(agentID, ""); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Cleared task for: %s\n", agentID)
+	return nil
+}
+
+// setTask sets agentID's task, through the daemon if one is running
+func setTask(agentID, task string) error {
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+	if client != nil {
+		return client.SetTask(agentID, task)
+	}
+	return agent.NewManager(coordDir, cfg).UpdateTask(agentID, task)
+}
+
+func runTaskShow(cmd *cobra.Command, args []string) error {
+	agentID := resolveAgentID(taskAgentID)
+
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+
+	var a *agent.Agent
+	if client != nil {
+		status, err := client.Status()
+		if err != nil {
+			return err
+		}
+		for i := range status.Agents {
+			if status.Agents[i].ID == agentID {
+				a = &status.Agents[i]
+			}
+		}
+	} else if a, err = agent.NewManager(coordDir, cfg).Read(agentID); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if structured() {
+		out := &taskOutput{outputHeader: header("task"), AgentID: agentID, History: []agent.Task{}}
+		if a != nil {
+			out.Task = a.CurrentTask
+			out.TaskStartedAt = timeOrNil(a.TaskStartedAt)
+			out.TaskAuto = a.TaskAuto
+			out.History = emptyIfNil(a.TaskHistory)
+		}
+		return printStructured(out)
+	}
+
+	fmt.Println(agentID)
+	if a == nil || a.CurrentTask == "" {
+		fmt.Println("  Task: (none)")
+	} else {
+		fmt.Printf("  Task: %s\n", describeTask(a))
+	}
+	if a == nil || len(a.TaskHistory) == 0 {
+		return nil
+	}
+
+	fmt.Println("  Previous:")
+	for i := len(a.TaskHistory) - 1; i >= 0; i-- {
+		t := a.TaskHistory[i]
+		fmt.Printf("    • %s (%s, for %s)\n", t.Task,
+			t.StartedAt.Local().Format("Jan 2 15:04"), t.EndedAt.Sub(t.StartedAt).Round(time.Second))
+	}
+	return nil
+}
+
+// describeTask returns a's current task with how long it's been going on,
+// and whether it was made from a's locks
+func describeTask(a *agent.Agent) string {
+	var notes []string
+	if !a.TaskStartedAt.IsZero() {
+		notes = append(notes, "for "+time.Since(a.TaskStartedAt).Round(time.Second).String())
+	}
+	if a.TaskAuto {
+		notes = append(notes, "from locks")
+	}
+	if len(notes) == 0 {
+		return a.CurrentTask
+	}
+	return fmt.Sprintf("%s [%s]", a.CurrentTask, strings.Join(notes, ", "))
+}
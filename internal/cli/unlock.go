@@ -2,15 +2,15 @@ package cli
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
-	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/daemon"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
 )
 
 var (
 	unlockAll     bool
+	unlockForce   bool
 	unlockAgentID string
 )
 
@@ -19,33 +19,54 @@ var unlockCmd = &cobra.Command{
 	Short: "Release a lock on a resource",
 	Long: `Release a lock that you previously acquired.
 
-Use --all to release all locks held by your agent.`,
+Locks are reentrant: an agent that locks a resource it already holds takes
+another hold on it, and keeps the lock until it has unlocked it as many
+times. Use --force-release to drop every hold at once, e.g. to clean up
+after a nested script that exited without unlocking.
+
+Use --all to release all locks held by your agent, however many holds.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runUnlock,
 }
 
 func init() {
 	unlockCmd.Flags().BoolVar(&unlockAll, "all", false, "Release all locks held by this agent")
-	unlockCmd.Flags().StringVar(&unlockAgentID, "agent", "", "Agent ID (default: from env or auto)")
+	unlockCmd.Flags().BoolVar(&unlockForce, "force-release", false, "Drop every hold on the lock, not just one")
+	unlockCmd.Flags().StringVar(&unlockAgentID, "agent", "", "Agent ID (default: see whoami)")
 	rootCmd.AddCommand(unlockCmd)
 }
 
 func runUnlock(cmd *cobra.Command, args []string) error {
 	// Get agent ID
-	agentID := unlockAgentID
-	if agentID == "" {
-		agentID = os.Getenv("CLAUDE_SESSION_ID")
-		if agentID == "" {
-			agentID = agent.GenerateID()
-		}
-	}
+	agentID := resolveAgentID(unlockAgentID)
 
 	lockMgr := lock.NewManager(coordDir, cfg)
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
 
 	if unlockAll {
-		if err := lockMgr.ReleaseAll(agentID); err != nil {
+		// Say what's being released before it's gone
+		var released []string
+		if structured() {
+			if released, err = heldBy(lockMgr, client, agentID); err != nil {
+				return err
+			}
+		}
+
+		releaseAll := lockMgr.ReleaseAll
+		if client != nil {
+			releaseAll = func(agentID string) error {
+				return client.Unlock(nil, agentID, true)
+			}
+		}
+		if err := releaseAll(agentID); err != nil {
 			return err
 		}
+		if structured() {
+			return printStructured(&unlockOutput{outputHeader: header("unlock"), AgentID: agentID, Released: emptyIfNil(released)})
+		}
 		fmt.Printf("✓ Released all locks for agent: %s\n", agentID)
 		return nil
 	}
@@ -55,10 +76,63 @@ func runUnlock(cmd *cobra.Command, args []string) error {
 	}
 
 	resource := args[0]
-	if err := lockMgr.Release(resource, agentID); err != nil {
+	release := lockMgr.Release
+	switch {
+	case client != nil && unlockForce:
+		release = func(resource, agentID string) error {
+			return client.ForceUnlock([]string{resource}, agentID)
+		}
+	case client != nil:
+		release = func(resource, agentID string) error {
+			return client.Unlock([]string{resource}, agentID, false)
+		}
+	case unlockForce:
+		release = lockMgr.ForceRelease
+	}
+	if err := release(resource, agentID); err != nil {
 		return err
 	}
 
+	// Nested holds keep the lock until they're released too
+	holds := 0
+	if l, err := readLock(lockMgr, client, resource); err == nil {
+		if h := l.HolderFor(agentID); h != nil {
+			holds = h.HoldCount()
+		}
+	}
+	if structured() {
+		return printStructured(&unlockOutput{outputHeader: header("unlock"), AgentID: agentID, Released: []string{resource}, Holds: holds})
+	}
+
+	if holds > 0 {
+		fmt.Printf("✓ Released one hold on %s (%d left; unlock again, or use --force-release)\n", resource, holds)
+		return nil
+	}
 	fmt.Printf("✓ Released: %s\n", resource)
 	return nil
 }
+
+// heldBy lists the resources agentID holds locks on
+func heldBy(lockMgr *lock.Manager, client *daemon.Client, agentID string) ([]string, error) {
+	var locks []lock.Lock
+	if client != nil {
+		status, err := client.Status()
+		if err != nil {
+			return nil, err
+		}
+		locks = status.Locks
+	} else {
+		var err error
+		if locks, err = lockMgr.List(); err != nil {
+			return nil, err
+		}
+	}
+
+	var held []string
+	for _, l := range locks {
+		if l.HeldBy(agentID) {
+			held = append(held, l.Resource)
+		}
+	}
+	return held, nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+var verifyToken uint64
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <resource> --token N",
+	Short: "Check that a fencing token is still current",
+	Long: `Check that the fencing token you got from 'lock' or 'check --acquire' is
+still current for the resource.
+
+Every acquisition is issued a new, higher token. If your lease expired and
+another agent took the lock over, your token is no longer current and this
+command exits non-zero. Scripts should run it right before acting on the
+resource and refuse to proceed if it fails.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVerify,
+}
+
+func init() {
+	verifyCmd.Flags().Uint64Var(&verifyToken, "token", 0, "Fencing token to verify")
+	verifyCmd.MarkFlagRequired("token")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	resource := args[0]
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+
+	verify := lock.NewManager(coordDir, cfg).Verify
+	if client != nil {
+		verify = client.Verify
+	}
+	if err := verify(resource, verifyToken); err != nil {
+		return err
+	}
+
+	if structured() {
+		return printStructured(&verifyOutput{outputHeader: header("verify"), Resource: resource, Token: verifyToken, Current: true})
+	}
+	fmt.Printf("✓ Token %d is current for: %s\n", verifyToken, resource)
+	return nil
+}
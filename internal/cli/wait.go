@@ -1,7 +1,11 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -9,8 +13,15 @@ import (
 )
 
 var (
-	waitTimeout  int
-	waitInterval int
+	waitTimeout   int
+	waitInterval  int
+	waitAcquire   bool
+	waitAgentID   string
+	waitAgentName string
+	waitOperation string
+	waitShared    bool
+	waitTTL       int
+	waitQuiet     bool
 )
 
 var waitCmd = &cobra.Command{
@@ -18,6 +29,20 @@ var waitCmd = &cobra.Command{
 	Short: "Wait for a resource to become available",
 	Long: `Block until the specified resource is no longer locked.
 
+Waiters are woken by file system notifications as soon as a lock is released,
+and fall back to checking every --interval seconds where notifications are
+unavailable or a lock has to expire first.
+
+With --acquire, join the resource's wait queue and take the lock once every
+agent queued ahead has had its turn. Waiters are served first come, first
+served; a lock can't be grabbed by an agent that didn't queue while others
+are waiting for it. Queues aren't shared between hosts, so --acquire is
+refused with the redis backend; use lock --wait there.
+
+A wait that would deadlock, because the holder is itself waiting for us
+through some chain of agents, fails straight away with the cycle in the
+error.
+
 Useful for coordinating sequential tasks between agents.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runWait,
@@ -25,7 +50,14 @@ Useful for coordinating sequential tasks between agents.`,
 
 func init() {
 	waitCmd.Flags().IntVar(&waitTimeout, "timeout", 300, "Maximum time to wait in seconds (0 = infinite)")
-	waitCmd.Flags().IntVar(&waitInterval, "interval", 5, "Check interval in seconds")
+	waitCmd.Flags().IntVar(&waitInterval, "interval", 5, "Fallback check interval in seconds")
+	waitCmd.Flags().BoolVar(&waitAcquire, "acquire", false, "Queue for the resource and lock it when our turn comes")
+	waitCmd.Flags().StringVar(&waitAgentID, "agent", "", "Agent ID (default: see whoami)")
+	waitCmd.Flags().StringVar(&waitAgentName, "name", "", "Agent display name")
+	waitCmd.Flags().StringVar(&waitOperation, "op", "", "Description of what you're doing")
+	waitCmd.Flags().BoolVar(&waitShared, "shared", false, "Wait for a shared (read) lock")
+	waitCmd.Flags().IntVar(&waitTTL, "ttl", 0, "Lock timeout in seconds when acquiring (0 = use default)")
+	waitCmd.Flags().BoolVarP(&waitQuiet, "quiet", "q", false, "Only print the outcome")
 	rootCmd.AddCommand(waitCmd)
 }
 
@@ -33,35 +65,108 @@ func runWait(cmd *cobra.Command, args []string) error {
 	resource := args[0]
 	lockMgr := lock.NewManager(coordDir, cfg)
 
+	agentID := resolveAgentID(waitAgentID)
+
+	mode := lock.Exclusive
+	if waitShared {
+		mode = lock.Shared
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if waitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(waitTimeout)*time.Second)
+		defer cancel()
+	}
+
+	opts := lock.WaitOptions{
+		Mode:      mode,
+		Acquire:   waitAcquire,
+		AgentName: waitAgentName,
+		Operation: waitOperation,
+		TTL:       waitTTL,
+		Interval:  time.Duration(waitInterval) * time.Second,
+	}
+
 	start := time.Now()
-	checkInterval := time.Duration(waitInterval) * time.Second
+	if !waitQuiet && !structured() {
+		fmt.Printf("Waiting for %s to become available...\n", resource)
 
-	fmt.Printf("Waiting for %s to become available...\n", resource)
+		// Progress is only reported when what we're waiting for changes
+		opts.Progress = func(status lock.WaitStatus) {
+			position := ""
+			if status.Position >= 0 {
+				position = fmt.Sprintf(", position %d in queue", status.Position+1)
+			}
+			elapsed := time.Since(start).Round(time.Second)
 
-	for {
-		// Check if lock exists
-		existingLock, err := lockMgr.Read(resource)
-		if err != nil {
-			// No lock - resource is free
-			fmt.Printf("✓ Resource available: %s\n", resource)
-			return nil
+			switch {
+			case status.Err != nil:
+				fmt.Printf("  %v\n", status.Err)
+			case status.Holder != nil:
+				fmt.Printf("  Still locked by %s (%s), waiting... (%s elapsed%s)\n",
+					status.Holder.AgentID, status.Holder.Operation, elapsed, position)
+			case status.Ahead != nil:
+				fmt.Printf("  Queued behind %s (%s), waiting... (%s elapsed%s)\n",
+					status.Ahead.AgentID, status.Ahead.Operation, elapsed, position)
+			}
 		}
+	}
 
-		// Check if stale
-		if lockMgr.IsStale(existingLock) {
-			fmt.Printf("✓ Lock was stale, resource available: %s\n", resource)
-			return nil
+	client, err := daemonClient()
+	if err != nil {
+		return err
+	}
+	wait := lockMgr.Wait
+	if client != nil {
+		wait = client.Wait
+	}
+	if err := wait(ctx, resource, agentID, opts); err != nil {
+		if errors.Is(err, context.Canceled) {
+			// Interrupted; our place in the queue has been given up
+			return interrupted(cmd)
 		}
+		return err
+	}
 
-		// Check timeout
-		if waitTimeout > 0 && time.Since(start) > time.Duration(waitTimeout)*time.Second {
-			return fmt.Errorf("timeout waiting for %s (locked by %s)", resource, existingLock.AgentID)
+	if structured() {
+		out := &waitOutput{
+			outputHeader:  header("wait"),
+			Resource:      resource,
+			AgentID:       agentID,
+			Acquired:      waitAcquire,
+			WaitedSeconds: seconds(time.Since(start)),
+		}
+		if waitAcquire {
+			l, err := readLock(lockMgr, client, resource)
+			if err != nil {
+				return fmt.Errorf("failed to read lock on %s: %w", resource, err)
+			}
+			leases, err := readLeases(lockMgr, client, l)
+			if err != nil {
+				return fmt.Errorf("failed to read leases on %s: %w", resource, err)
+			}
+			lo := newLockOutput(*l, leases)
+			out.Lock = &lo
 		}
+		return printStructured(out)
+	}
 
-		elapsed := time.Since(start).Round(time.Second)
-		fmt.Printf("  Still locked by %s (%s), waiting... (%s elapsed)\n",
-			existingLock.AgentID, existingLock.Operation, elapsed)
+	if !waitAcquire {
+		fmt.Printf("✓ Resource available: %s\n", resource)
+		return nil
+	}
 
-		time.Sleep(checkInterval)
+	fmt.Printf("✓ Locked: %s\n", resource)
+	fmt.Printf("  Agent:  %s\n", agentID)
+	if mode == lock.Shared {
+		fmt.Printf("  Mode:   %s\n", mode)
+	}
+	if l, err := readLock(lockMgr, client, resource); err == nil {
+		if h := l.HolderFor(agentID); h != nil && h.Token != 0 {
+			fmt.Printf("  Token:  %d\n", h.Token)
+		}
 	}
+	return nil
 }
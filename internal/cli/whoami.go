@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+)
+
+var whoamiAgentID string
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show this agent's identity",
+	Long: `Show the agent ID commands run from here will use, and where it came from.
+
+Without --agent or CLAUDE_SESSION_ID, the ID is derived from the Claude Code
+session or process this runs under, else from the process that ran it, such
+as a script or your shell, else from the terminal and git worktree, so that a
+lock and a later unlock from the same place agree on who you are. Separate
+scripts get separate IDs, even when run from one terminal.`,
+	Args: cobra.NoArgs,
+	RunE: runWhoami,
+}
+
+func init() {
+	whoamiCmd.Flags().StringVar(&whoamiAgentID, "agent", "", "Agent ID")
+	rootCmd.AddCommand(whoamiCmd)
+}
+
+func runWhoami(cmd *cobra.Command, args []string) error {
+	identity := agent.ResolveIdentity(coordDir, whoamiAgentID)
+	if structured() {
+		return printStructured(&whoamiOutput{
+			outputHeader: header("whoami"),
+			AgentID:      identity.ID,
+			Source:       identity.Source,
+			Detail:       identity.Detail,
+		})
+	}
+
+	fmt.Println(identity.ID)
+	fmt.Printf("  Source: %s (%s)\n", identity.Source, identity.Detail)
+	if identity.Source == agent.SourceGenerated {
+		fmt.Println("  ⚠ This ID changes on every command; pass --agent or set CLAUDE_SESSION_ID")
+	}
+	return nil
+}
+
+// resolveAgentID returns the agent ID commands act as: the --agent flag if
+// given, else the identity resolved for this process
+func resolveAgentID(flag string) string {
+	return agent.ResolveIdentity(coordDir, flag).ID
+}
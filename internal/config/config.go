@@ -14,6 +14,8 @@ const (
 	ConfigFileName     = "config.yaml"
 	LocksDir           = "locks"
 	AgentsDir          = "agents"
+	QueueDir           = "queue"
+	IdentitiesDir      = "identities"
 	DefaultTTL         = 300
 	DefaultStale       = 120
 	DefaultHeartbeat   = 30
@@ -42,6 +44,23 @@ type Settings struct {
 	DefaultTTL        int `yaml:"default_ttl"`
 	StaleThreshold    int `yaml:"stale_threshold"`
 	HeartbeatInterval int `yaml:"heartbeat_interval"`
+
+	// Backend is where lock and agent state is kept: "file" (the default),
+	// "sqlite" or "redis"
+	Backend string `yaml:"backend,omitempty"`
+
+	// RedisURL is the server the redis backend uses, e.g.
+	// redis://:${REDIS_PASSWORD}@redis:6379/0. Environment variables are
+	// expanded.
+	RedisURL string `yaml:"redis_url,omitempty"`
+
+	// RedisPrefix namespaces keys so several repos can share a server.
+	// Defaults to "claude-coord".
+	RedisPrefix string `yaml:"redis_prefix,omitempty"`
+
+	// Server is the URL of a coordination server (claude-coord serve) to
+	// use instead of the coord dir, e.g. http://host.internal:7878
+	Server string `yaml:"server,omitempty"`
 }
 
 // Load reads the config from the given directory
@@ -124,7 +143,17 @@ func DefaultConfig() *Config {
 	}
 }
 
-// EnsureDirs creates the locks and agents directories
+// FindLogical returns the logical resource with the given name, or nil
+func (c *Config) FindLogical(name string) *LogicalResource {
+	for i := range c.Logical {
+		if c.Logical[i].Name == name {
+			return &c.Logical[i]
+		}
+	}
+	return nil
+}
+
+// EnsureDirs creates the locks, agents and queue directories
 func EnsureDirs(coordDir string) error {
 	if coordDir == "" {
 		coordDir = DefaultCoordDir
@@ -133,6 +162,7 @@ func EnsureDirs(coordDir string) error {
 	dirs := []string{
 		filepath.Join(coordDir, LocksDir),
 		filepath.Join(coordDir, AgentsDir),
+		filepath.Join(coordDir, QueueDir),
 	}
 
 	for _, dir := range dirs {
@@ -0,0 +1,295 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// dialTimeout bounds how long the CLI spends finding out the daemon is gone
+const dialTimeout = 200 * time.Millisecond
+
+// Client talks to a running daemon or coordination server. Each call uses
+// its own connection.
+type Client struct {
+	// open sends req and returns the stream of responses to it. Closing
+	// the stream hangs up.
+	open func(req *Request) (io.ReadCloser, error)
+}
+
+// Dial returns a client for the daemon serving coordDir, or an error if
+// none is running
+func Dial(coordDir string) (*Client, error) {
+	path := SocketPath(coordDir)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("daemon not running: %w", err)
+	}
+	c := &Client{open: func(req *Request) (io.ReadCloser, error) {
+		return openUnix(path, req)
+	}}
+	if _, err := c.call(&Request{Op: OpPing}, nil); err != nil {
+		return nil, fmt.Errorf("daemon not running: %w", err)
+	}
+	return c, nil
+}
+
+// openUnix sends req to the daemon listening on path
+func openUnix(path string, req *Request) (io.ReadCloser, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return conn, nil
+}
+
+// Close releases the client. It's a no-op, since connections don't
+// outlive a call.
+func (c *Client) Close() error {
+	return nil
+}
+
+// Lock locks resources all-or-nothing, like lock.Manager.AcquireAll
+func (c *Client) Lock(resources []string, mode lock.Mode, agentID, agentName, operation string, ttl int) error {
+	_, err := c.call(&Request{
+		Op:        OpLock,
+		Resources: resources,
+		Mode:      mode,
+		AgentID:   agentID,
+		AgentName: agentName,
+		Operation: operation,
+		TTL:       ttl,
+	}, nil)
+	return err
+}
+
+// LockContext locks resources like lock.Manager.AcquireContext, blocking
+// in the daemon until they're granted. It gives up when ctx is done.
+func (c *Client) LockContext(ctx context.Context, resources []string, mode lock.Mode, agentID, agentName, operation string, ttl int) error {
+	req := &Request{
+		Op:        OpLock,
+		Resources: resources,
+		Mode:      mode,
+		AgentID:   agentID,
+		AgentName: agentName,
+		Operation: operation,
+		TTL:       ttl,
+		Wait:      true,
+	}
+	return c.stream(ctx, req, "timeout waiting to lock "+strings.Join(resources, ", "), nil)
+}
+
+// Unlock releases one of agentID's holds on each of resources, or all of
+// its locks with all
+func (c *Client) Unlock(resources []string, agentID string, all bool) error {
+	_, err := c.call(&Request{Op: OpUnlock, Resources: resources, AgentID: agentID, All: all}, nil)
+	return err
+}
+
+// ForceUnlock drops every hold agentID has on resources, like
+// lock.Manager.ForceRelease
+func (c *Client) ForceUnlock(resources []string, agentID string) error {
+	_, err := c.call(&Request{Op: OpUnlock, Resources: resources, AgentID: agentID, Force: true}, nil)
+	return err
+}
+
+// Renew renews agentID's leases on resources like lock.Manager.Renew, or
+// on all of its locks with all
+func (c *Client) Renew(resources []string, agentID string, ttl int, all bool) error {
+	_, err := c.call(&Request{Op: OpRenew, Resources: resources, AgentID: agentID, TTL: ttl, All: all}, nil)
+	return err
+}
+
+// Read returns the locks on resources. Resources that aren't locked are
+// left out.
+func (c *Client) Read(resources []string) ([]lock.Lock, error) {
+	var locks []lock.Lock
+	_, err := c.call(&Request{Op: OpRead, Resources: resources}, &locks)
+	return locks, err
+}
+
+// Verify checks that token is a current fencing token for resource, like
+// lock.Manager.Verify
+func (c *Client) Verify(resource string, token uint64) error {
+	_, err := c.call(&Request{Op: OpVerify, Resources: []string{resource}, Token: token}, nil)
+	return err
+}
+
+// Check checks files like lock.Manager.CheckFile, returning results for
+// protected files only
+func (c *Client) Check(files []string, acquire bool, agentID, agentName, operation string) ([]lock.FileCheck, error) {
+	var results []lock.FileCheck
+	_, err := c.call(&Request{
+		Op:        OpCheck,
+		Files:     files,
+		Acquire:   acquire,
+		AgentID:   agentID,
+		AgentName: agentName,
+		Operation: operation,
+	}, &results)
+	return results, err
+}
+
+// Status returns the locks, agents, queues and deadlocks the daemon sees
+func (c *Client) Status() (*Status, error) {
+	var status Status
+	if _, err := c.call(&Request{Op: OpStatus}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Register registers an agent, like agent.Manager.Register
+func (c *Client) Register(agentID, agentName string) error {
+	_, err := c.call(&Request{Op: OpRegister, AgentID: agentID, AgentName: agentName}, nil)
+	return err
+}
+
+// Resume registers an agent unless it's registered already, in which case
+// it only sends a heartbeat, keeping the agent's record
+func (c *Client) Resume(agentID, agentName string) error {
+	_, err := c.call(&Request{Op: OpRegister, AgentID: agentID, AgentName: agentName, Resume: true}, nil)
+	return err
+}
+
+// Heartbeat records that agentID is alive, along with the files it just
+// touched if any. With renewLocks it also renews all of the agent's locks.
+func (c *Client) Heartbeat(agentID string, files []string, renewLocks bool) error {
+	_, err := c.call(&Request{Op: OpHeartbeat, AgentID: agentID, Files: files, RenewLocks: renewLocks}, nil)
+	return err
+}
+
+// Deregister removes an agent, like agent.Manager.Deregister
+func (c *Client) Deregister(agentID string) error {
+	_, err := c.call(&Request{Op: OpDeregister, AgentID: agentID}, nil)
+	return err
+}
+
+// SetTask sets agentID's current task, like agent.Manager.UpdateTask
+func (c *Client) SetTask(agentID, task string) error {
+	_, err := c.call(&Request{Op: OpTask, AgentID: agentID, Task: task}, nil)
+	return err
+}
+
+// Shutdown asks the daemon to stop
+func (c *Client) Shutdown() error {
+	_, err := c.call(&Request{Op: OpShutdown}, nil)
+	return err
+}
+
+// Wait runs lock.Manager.Wait in the daemon. Progress is relayed to
+// opts.Progress, and the wait is given up when ctx is done.
+func (c *Client) Wait(ctx context.Context, resource, agentID string, opts lock.WaitOptions) error {
+	req := &Request{
+		Op:        OpWait,
+		Resources: []string{resource},
+		Mode:      opts.Mode,
+		AgentID:   agentID,
+		AgentName: opts.AgentName,
+		Operation: opts.Operation,
+		TTL:       opts.TTL,
+		Acquire:   opts.Acquire,
+		Interval:  int(opts.Interval / time.Second),
+	}
+
+	var progress func(*Progress)
+	if opts.Progress != nil {
+		progress = func(p *Progress) {
+			status := lock.WaitStatus{Holder: p.Holder, Ahead: p.Ahead, Position: p.Position}
+			if p.Error != "" {
+				status.Err = errors.New(p.Error)
+			}
+			opts.Progress(status)
+		}
+	}
+	return c.stream(ctx, req, "timeout waiting for "+resource, progress)
+}
+
+// stream sends a request that blocks in the daemon until ctx is done,
+// relaying progress until the final response. timeout is the error message
+// if ctx's deadline passes before the daemon says why.
+func (c *Client) stream(ctx context.Context, req *Request, timeout string, progress func(*Progress)) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		// Rounded up, so the daemon times out first and says why
+		req.Timeout = int((time.Until(deadline) + time.Second - 1) / time.Second)
+	}
+
+	stream, err := c.open(req)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// Give the daemon's own timeout a moment to report
+				select {
+				case <-time.After(time.Second):
+				case <-done:
+					return
+				}
+			}
+			// Hanging up makes the daemon give up our place in line
+			stream.Close()
+		case <-done:
+		}
+	}()
+
+	dec := json.NewDecoder(stream)
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return &lock.Error{Code: lock.CodeTimeout, Message: timeout}
+				}
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		if resp.Progress == nil {
+			return resp.err()
+		}
+		if progress != nil {
+			progress(resp.Progress)
+		}
+	}
+}
+
+// call sends req and decodes the result into out, if given
+func (c *Client) call(req *Request, out any) (*Response, error) {
+	stream, err := c.open(req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var resp Response
+	if err := json.NewDecoder(stream).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := resp.err(); err != nil {
+		return &resp, err
+	}
+	if out != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, out); err != nil {
+			return &resp, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return &resp, nil
+}
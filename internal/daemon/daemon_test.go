@@ -0,0 +1,167 @@
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// startDaemon runs a daemon for a fresh coord dir until the test ends
+func startDaemon(t *testing.T) (string, *config.Config, *Client) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(coordDir, cfg).ListenAndServe(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Daemon failed: %v", err)
+		}
+	})
+
+	for i := 0; i < 100; i++ {
+		if client, err := Dial(coordDir); err == nil {
+			return coordDir, cfg, client
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Daemon didn't start")
+	return "", nil, nil
+}
+
+func TestDaemonLocks(t *testing.T) {
+	coordDir, cfg, client := startDaemon(t)
+	lockMgr := lock.NewManager(coordDir, cfg)
+
+	if err := client.Lock([]string{"db/**/*"}, lock.Exclusive, "agent-1", "", "migration", 0); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	// The lock is on disk for agents not using the daemon
+	if l, err := lockMgr.Read("db/**/*"); err != nil || l.AgentID != "agent-1" {
+		t.Fatalf("Lock file not written: %v %v", l, err)
+	}
+	if err := lockMgr.Acquire("db/**/*", "agent-2", "", "", 0); err == nil {
+		t.Error("Acquired a lock held through the daemon")
+	}
+
+	// And the daemon sees locks taken without it
+	if err := lockMgr.Acquire("go.mod", "agent-2", "", "tidy", 0); err != nil {
+		t.Fatal(err)
+	}
+	results, err := client.Check([]string{"db/schema.sql", "go.mod", "main.go"}, false, "agent-1", "", "")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected results for the 2 protected files, got %+v", results)
+	}
+	if results[0].IsBlocked() {
+		t.Errorf("Blocked by our own lock: %+v", results[0])
+	}
+	if results[1].Blocker == nil || results[1].Blocker.AgentID != "agent-2" {
+		t.Errorf("Expected go.mod blocked by agent-2, got %+v", results[1])
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status.Locks) != 2 {
+		t.Errorf("Expected 2 locks in status, got %d", len(status.Locks))
+	}
+	if leases := status.Leases["go.mod"]; len(leases) != 1 || leases[0].AgentID != "agent-2" || leases[0].Stale {
+		t.Errorf("Expected agent-2's live lease on go.mod, got %+v", leases)
+	}
+
+	// Releasing without the daemon must be noticed by its checks
+	if err := lockMgr.Release("go.mod", "agent-2"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = client.Check([]string{"go.mod"}, true, "agent-1", "", "tidy")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(results) != 1 || !results[0].Acquired || results[0].Token == 0 {
+		t.Errorf("Expected go.mod acquired, got %+v", results)
+	}
+
+	if err := client.Unlock(nil, "agent-1", true); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if locks, _ := lockMgr.List(); len(locks) != 0 {
+		t.Errorf("Expected no locks after unlock, got %d", len(locks))
+	}
+}
+
+func TestDaemonWait(t *testing.T) {
+	coordDir, cfg, client := startDaemon(t)
+	lockMgr := lock.NewManager(coordDir, cfg)
+
+	if err := lockMgr.Acquire("go.mod", "agent-1", "", "tidy", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := make(chan lock.WaitStatus, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Wait(context.Background(), "go.mod", "agent-2", lock.WaitOptions{
+			Acquire:  true,
+			Progress: func(s lock.WaitStatus) { progress <- s },
+		})
+	}()
+
+	select {
+	case s := <-progress:
+		if s.Holder == nil || s.Holder.AgentID != "agent-1" {
+			t.Errorf("Expected progress naming agent-1, got %+v", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No progress reported")
+	}
+
+	if err := lockMgr.Release("go.mod", "agent-1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait didn't return after release")
+	}
+	if l, err := lockMgr.Read("go.mod"); err != nil || l.AgentID != "agent-2" {
+		t.Errorf("Expected agent-2 to hold go.mod, got %v %v", l, err)
+	}
+
+	// A timed out wait reports why, and gives up its place in line
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := client.Wait(ctx, "go.mod", "agent-3", lock.WaitOptions{Acquire: true})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+	if queue, _ := lockMgr.Queue("go.mod"); len(queue) != 0 {
+		t.Errorf("Expected empty queue after timeout, got %+v", queue)
+	}
+}
+
+func TestDaemonAlreadyRunning(t *testing.T) {
+	coordDir, cfg, _ := startDaemon(t)
+
+	err := NewServer(coordDir, cfg).ListenAndServe(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Expected already running error, got %v", err)
+	}
+}
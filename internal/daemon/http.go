@@ -0,0 +1,215 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+)
+
+// TokenEnv is the environment variable holding the coordination server's
+// access token, on the server and on its clients
+const TokenEnv = "CLAUDE_COORD_TOKEN"
+
+// APIPrefix is where the HTTP API is served: each operation is a POST of a
+// Request to APIPrefix + op
+const APIPrefix = "/v1/"
+
+// expireInterval is how often the coordination server drops locks and
+// agents that went stale, since remote agents can vanish without a trace
+const expireInterval = 10 * time.Second
+
+// maxRequestSize bounds a request body
+const maxRequestSize = 1 << 20
+
+// httpOps are the operations served over HTTP. The server is stopped by
+// whoever started it, not by its clients.
+var httpOps = map[string]bool{
+	OpPing:       true,
+	OpLock:       true,
+	OpUnlock:     true,
+	OpRenew:      true,
+	OpRead:       true,
+	OpVerify:     true,
+	OpCheck:      true,
+	OpStatus:     true,
+	OpWait:       true,
+	OpRegister:   true,
+	OpHeartbeat:  true,
+	OpDeregister: true,
+	OpTask:       true,
+}
+
+// Handler returns the HTTP API. Requests must carry token as a bearer
+// token. Responses are newline-delimited: a wait is a long poll, streaming
+// progress until the final response, and is abandoned if the client hangs
+// up.
+func (s *Server) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		op := strings.TrimPrefix(r.URL.Path, APIPrefix)
+		if !strings.HasPrefix(r.URL.Path, APIPrefix) || !httpOps[op] {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		req.Op = op
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		if flusher != nil {
+			// Let the client know we're on it before a long wait
+			flusher.Flush()
+		}
+		enc := json.NewEncoder(w)
+		s.handle(r.Context(), &req, func(resp *Response) {
+			enc.Encode(resp)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}, nil)
+	})
+}
+
+// authorized reports whether r carries the bearer token
+func authorized(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// ListenAndServeHTTP serves the HTTP API on addr until ctx is done. Unlike
+// the daemon, the coordination server is the only one using its coord dir,
+// so it also expires stale locks and agents itself.
+func (s *Server) ListenAndServeHTTP(ctx context.Context, addr, token string) error {
+	if token == "" {
+		return errors.New("the coordination server needs an access token")
+	}
+	if err := config.EnsureDirs(s.coordDir); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := s.watch(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ Warning: not caching locks: %v\n", err)
+	}
+	go s.expire(ctx)
+
+	server := &http.Server{
+		Handler:           s.Handler(token),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// expire drops stale agents and locks every expireInterval until ctx is
+// done, and repairs the locks listed on the agents left
+func (s *Server) expire(ctx context.Context) {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lockMgr, agentMgr := s.managers()
+			if _, err := agentMgr.CleanStale(); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠ Warning: failed to clean stale agents: %v\n", err)
+			}
+			if _, err := lockMgr.CleanStale(); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠ Warning: failed to clean stale locks: %v\n", err)
+			}
+			if _, err := lockMgr.SyncAgents(); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠ Warning: failed to sync agents: %v\n", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// DialHTTP returns a client for the coordination server at serverURL,
+// authenticating with token, or an error if it can't be reached
+func DialHTTP(serverURL, token string) (*Client, error) {
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "http://" + serverURL
+	}
+	base := strings.TrimSuffix(serverURL, "/") + APIPrefix
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}}
+	c := &Client{open: func(req *Request) (io.ReadCloser, error) {
+		return openHTTP(httpClient, base+req.Op, token, req)
+	}}
+	if _, err := c.call(&Request{Op: OpPing}, nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// openHTTP posts req to url
+func openHTTP(httpClient *http.Client, url, token string, req *Request) (io.ReadCloser, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusUnauthorized:
+		resp.Body.Close()
+		return nil, fmt.Errorf("server refused the token; set %s", TokenEnv)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	resp.Body.Close()
+	return nil, fmt.Errorf("server error: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
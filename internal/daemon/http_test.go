@@ -0,0 +1,197 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// startHTTP serves the HTTP API for a fresh coord dir until the test ends
+func startHTTP(t *testing.T) (string, *config.Config, *httptest.Server) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	server := httptest.NewServer(NewServer(coordDir, cfg).Handler("s3cret"))
+	t.Cleanup(server.Close)
+	return coordDir, cfg, server
+}
+
+func TestHTTPAuth(t *testing.T) {
+	_, _, server := startHTTP(t)
+
+	if _, err := DialHTTP(server.URL, "wrong"); err == nil || !strings.Contains(err.Error(), TokenEnv) {
+		t.Errorf("Expected the wrong token to be refused, got %v", err)
+	}
+	if _, err := DialHTTP(server.URL, "s3cret"); err != nil {
+		t.Fatalf("DialHTTP failed: %v", err)
+	}
+
+	// Clients can't stop the server
+	req, _ := http.NewRequest(http.MethodPost, server.URL+APIPrefix+OpShutdown, strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected shutdown to be refused, got %s", resp.Status)
+	}
+}
+
+func TestHTTPLocksAndAgents(t *testing.T) {
+	coordDir, cfg, server := startHTTP(t)
+	client, err := DialHTTP(strings.TrimPrefix(server.URL, "http://"), "s3cret")
+	if err != nil {
+		t.Fatalf("DialHTTP failed: %v", err)
+	}
+
+	if err := client.Register("agent-1", "Backend"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if a, err := agent.NewManager(coordDir, cfg).Read("agent-1"); err != nil || a.Name != "Backend" {
+		t.Fatalf("Expected agent-1 registered on the server, got %v %v", a, err)
+	}
+	if err := client.Resume("agent-1", "Other"); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if a, _ := agent.NewManager(coordDir, cfg).Read("agent-1"); a == nil || a.Name != "Backend" {
+		t.Errorf("Expected a resumed agent to keep its record, got %+v", a)
+	}
+
+	if err := client.Lock([]string{"db/**/*"}, lock.Exclusive, "agent-1", "", "migration", 60); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	// Errors keep their kind, and the holder, across the wire
+	err = client.Lock([]string{"db/schema/*"}, lock.Exclusive, "agent-2", "", "", 60)
+	var locked *lock.LockedError
+	if !errors.As(err, &locked) || locked.Lock == nil || locked.Lock.AgentID != "agent-1" {
+		t.Errorf("Expected the overlapping lock refused as held by agent-1, got %v", err)
+	}
+	if err := client.Renew([]string{"db/**/*"}, "agent-2", 0, false); !errors.Is(err, lock.ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner renewing agent-1's lock, got %v", err)
+	}
+
+	locks, err := client.Read([]string{"db/**/*", "go.mod"})
+	if err != nil || len(locks) != 1 || locks[0].AgentID != "agent-1" || locks[0].Token == 0 {
+		t.Fatalf("Expected agent-1's lock only, got %+v %v", locks, err)
+	}
+
+	if err := client.Verify("db/**/*", locks[0].Token); err != nil {
+		t.Errorf("Expected agent-1's token current, got %v", err)
+	}
+	if err := client.Verify("db/**/*", locks[0].Token-1); !errors.Is(err, lock.ErrStale) {
+		t.Errorf("Expected ErrStale for an older token, got %v", err)
+	}
+
+	if err := client.Heartbeat("agent-1", []string{"db/schema.sql"}, true); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if err := client.Renew([]string{"db/**/*"}, "agent-1", 600, false); err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+	if locks, _ := client.Read([]string{"db/**/*"}); len(locks) != 1 || locks[0].TTLSeconds != 600 {
+		t.Errorf("Expected the lease renewed for 600s, got %+v", locks)
+	}
+
+	if err := client.Unlock(nil, "agent-1", true); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := client.Deregister("agent-1"); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	status, err := client.Status()
+	if err != nil || len(status.Locks) != 0 || len(status.Agents) != 0 {
+		t.Errorf("Expected nothing left, got %+v %v", status, err)
+	}
+}
+
+func TestHTTPWait(t *testing.T) {
+	_, _, server := startHTTP(t)
+	client, err := DialHTTP(server.URL, "s3cret")
+	if err != nil {
+		t.Fatalf("DialHTTP failed: %v", err)
+	}
+
+	if err := client.Lock([]string{"go.mod"}, lock.Exclusive, "agent-1", "", "tidy", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := make(chan lock.WaitStatus, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Wait(context.Background(), "go.mod", "agent-2", lock.WaitOptions{
+			Acquire:  true,
+			Progress: func(s lock.WaitStatus) { progress <- s },
+		})
+	}()
+
+	select {
+	case s := <-progress:
+		if s.Holder == nil || s.Holder.AgentID != "agent-1" {
+			t.Errorf("Expected progress naming agent-1, got %+v", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No progress reported")
+	}
+
+	if err := client.Unlock([]string{"go.mod"}, "agent-1", false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait didn't return after release")
+	}
+	if locks, _ := client.Read([]string{"go.mod"}); len(locks) != 1 || locks[0].AgentID != "agent-2" {
+		t.Errorf("Expected agent-2 to hold go.mod, got %+v", locks)
+	}
+
+	// Blocking locks time out, or are granted once the holder lets go
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := client.LockContext(ctx, []string{"go.mod"}, lock.Exclusive, "agent-3", "", "", 0); !errors.Is(err, lock.ErrTimeout) {
+		t.Errorf("Expected the blocking lock to time out, got %v", err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		client.Unlock([]string{"go.mod"}, "agent-2", false)
+	}()
+	if err := client.LockContext(context.Background(), []string{"go.mod"}, lock.Exclusive, "agent-3", "", "", 0); err != nil {
+		t.Fatalf("LockContext failed: %v", err)
+	}
+	if locks, _ := client.Read([]string{"go.mod"}); len(locks) != 1 || locks[0].AgentID != "agent-3" {
+		t.Errorf("Expected agent-3 to hold go.mod, got %+v", locks)
+	}
+
+	// Hanging up gives up our place in line
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+	if err := client.Wait(ctx, "go.mod", "agent-4", lock.WaitOptions{Acquire: true}); err != context.Canceled {
+		t.Errorf("Expected the wait to be canceled, got %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		status, err := client.Status()
+		if err == nil && len(status.Queues["go.mod"]) == 0 {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Error("Expected the canceled waiter to leave the queue")
+}
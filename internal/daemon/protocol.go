@@ -0,0 +1,172 @@
+// Package daemon implements the optional coordination daemon, which serves
+// lock and agent operations over a Unix domain socket in the coord dir or
+// over HTTP, and the client the CLI uses to talk to it.
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// SocketName is the daemon's socket file in the coord dir
+const SocketName = "daemon.sock"
+
+// maxSocketPath is a safe limit on Unix socket path lengths, which most
+// platforms cap at 104 or 108 bytes
+const maxSocketPath = 100
+
+// Operations a client can request
+const (
+	OpPing       = "ping"
+	OpLock       = "lock"
+	OpUnlock     = "unlock"
+	OpRenew      = "renew"
+	OpRead       = "read"
+	OpVerify     = "verify"
+	OpCheck      = "check"
+	OpStatus     = "status"
+	OpWait       = "wait"
+	OpRegister   = "register"
+	OpHeartbeat  = "heartbeat"
+	OpDeregister = "deregister"
+	OpTask       = "task"
+	OpShutdown   = "shutdown"
+)
+
+// Request is one call to the daemon. Which fields are used depends on Op.
+type Request struct {
+	Op        string    `json:"op"`
+	AgentID   string    `json:"agent_id,omitempty"`
+	AgentName string    `json:"agent_name,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	Resources []string  `json:"resources,omitempty"`
+	Files     []string  `json:"files,omitempty"`
+	Mode      lock.Mode `json:"mode,omitempty"`
+	TTL       int       `json:"ttl,omitempty"`
+	Acquire   bool      `json:"acquire,omitempty"`
+	Wait      bool      `json:"wait,omitempty"`
+	All       bool      `json:"all,omitempty"`
+
+	// Force makes an unlock drop every hold the agent has on the resources
+	Force bool `json:"force,omitempty"`
+
+	// RenewLocks makes a heartbeat renew all of the agent's locks too
+	RenewLocks bool `json:"renew_locks,omitempty"`
+
+	// Resume makes a register keep the record of an agent that's already
+	// registered, only sending a heartbeat
+	Resume bool `json:"resume,omitempty"`
+
+	// Task is the task to set for the agent; empty clears it
+	Task string `json:"task,omitempty"`
+
+	// Token is the fencing token to verify
+	Token uint64 `json:"token,omitempty"`
+
+	// Timeout and Interval bound a wait, or a lock with Wait set, in
+	// seconds
+	Timeout  int `json:"timeout,omitempty"`
+	Interval int `json:"interval,omitempty"`
+}
+
+// Response is the daemon's reply. A wait sends any number of progress
+// responses before the final one.
+type Response struct {
+	Error string `json:"error,omitempty"`
+
+	// Code names the kind of Error, one of the lock.Code constants, and
+	// Locked says who holds the lock when it's lock.CodeLocked
+	Code   string            `json:"code,omitempty"`
+	Locked *lock.LockedError `json:"locked,omitempty"`
+
+	Progress *Progress       `json:"progress,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+}
+
+// errorResponse describes err for the client
+func errorResponse(err error) Response {
+	resp := Response{Error: err.Error(), Code: lock.ErrorCode(err)}
+	var locked *lock.LockedError
+	if errors.As(err, &locked) {
+		resp.Locked = locked
+	}
+	return resp
+}
+
+// err rebuilds the error the server sent, so clients can tell its kind
+// from other errors
+func (r *Response) err() error {
+	switch {
+	case r.Error == "":
+		return nil
+	case r.Locked != nil && r.Locked.Error() == r.Error:
+		return r.Locked
+	case r.Locked != nil:
+		return &wrappedError{msg: r.Error, err: r.Locked}
+	case r.Code != "":
+		return &lock.Error{Code: r.Code, Message: r.Error}
+	}
+	return errors.New(r.Error)
+}
+
+// wrappedError is an error received with the message the server gave it
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string { return e.msg }
+func (e *wrappedError) Unwrap() error { return e.err }
+
+// Progress is a lock.WaitStatus on the wire
+type Progress struct {
+	Holder   *lock.Lock   `json:"holder,omitempty"`
+	Ahead    *lock.Ticket `json:"ahead,omitempty"`
+	Position int          `json:"position"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// Status is the daemon's answer to a status request. Leases are keyed by
+// resource, since only the daemon can tell whose heartbeats have stopped.
+type Status struct {
+	Locks     []lock.Lock              `json:"locks"`
+	Leases    map[string][]lock.Lease  `json:"leases"`
+	Agents    []agent.Agent            `json:"agents"`
+	Queues    map[string][]lock.Ticket `json:"queues"`
+	Deadlocks []lock.Deadlock          `json:"deadlocks"`
+}
+
+// LeasesFor reports on the leases of each of locks, by resource
+func LeasesFor(lockMgr *lock.Manager, locks []lock.Lock) map[string][]lock.Lease {
+	leases := make(map[string][]lock.Lease, len(locks))
+	for i := range locks {
+		leases[locks[i].Resource] = lockMgr.Leases(&locks[i])
+	}
+	return leases
+}
+
+// SocketPath returns where the daemon for coordDir listens. Coord dirs too
+// deep for a socket path get one in the temp dir, named after the coord dir.
+func SocketPath(coordDir string) string {
+	if coordDir == "" {
+		coordDir = config.DefaultCoordDir
+	}
+	if abs, err := filepath.Abs(coordDir); err == nil {
+		coordDir = abs
+	}
+
+	path := filepath.Join(coordDir, SocketName)
+	if len(path) <= maxSocketPath {
+		return path
+	}
+	sum := sha256.Sum256([]byte(coordDir))
+	return filepath.Join(os.TempDir(), "claude-coord-"+hex.EncodeToString(sum[:8])+".sock")
+}
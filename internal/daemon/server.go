@@ -0,0 +1,448 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// Server is the coordination daemon. It keeps the config, the set of files
+// known not to be protected and a snapshot of the locks in memory, and
+// answers checks and status requests from them. Every change still goes
+// through lock.Manager, so the files on disk stay authoritative and CLIs
+// that don't use the daemon interoperate with it.
+type Server struct {
+	coordDir string
+
+	mu           sync.RWMutex
+	cfg          *config.Config
+	lockMgr      *lock.Manager
+	agentMgr     *agent.Manager
+	notProtected map[string]bool
+
+	snapMu  sync.Mutex
+	locks   []lock.Lock
+	fresh   bool
+	watched bool
+}
+
+// NewServer creates a daemon for coordDir
+func NewServer(coordDir string, cfg *config.Config) *Server {
+	if coordDir == "" {
+		coordDir = config.DefaultCoordDir
+	}
+	s := &Server{coordDir: coordDir}
+	s.setConfig(cfg)
+	return s
+}
+
+func (s *Server) setConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	s.lockMgr = lock.NewManager(s.coordDir, cfg)
+	s.agentMgr = agent.NewManager(s.coordDir, cfg)
+	s.notProtected = make(map[string]bool)
+}
+
+func (s *Server) managers() (*lock.Manager, *agent.Manager) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lockMgr, s.agentMgr
+}
+
+// ListenAndServe serves requests on the coord dir's socket until ctx is
+// done or a client asks the daemon to shut down
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if err := config.EnsureDirs(s.coordDir); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	path := SocketPath(s.coordDir)
+	if _, err := os.Stat(path); err == nil {
+		if c, err := Dial(s.coordDir); err == nil {
+			c.Close()
+			return fmt.Errorf("daemon already running on %s", path)
+		}
+		// Left behind by a daemon that didn't shut down cleanly
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer os.Remove(path)
+	os.Chmod(path, 0600)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := s.watch(ctx); err != nil {
+		// Without notifications every request reads the locks from disk
+		fmt.Fprintf(os.Stderr, "⚠ Warning: not caching locks: %v\n", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, cancel, conn)
+		}()
+	}
+}
+
+// watch keeps the in-memory state in step with the store and the coord
+// dir: the lock snapshot is dropped when a lock changes, and the config
+// reloaded when config.yaml does
+func (s *Server) watch(ctx context.Context) error {
+	lockMgr, _ := s.managers()
+	locks, err := lockMgr.WatchLocks()
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		locks.Close()
+		return err
+	}
+	if err := watcher.Add(s.coordDir); err != nil {
+		watcher.Close()
+		locks.Close()
+		return err
+	}
+
+	s.snapMu.Lock()
+	s.watched = true
+	s.snapMu.Unlock()
+
+	invalidate := func() {
+		s.snapMu.Lock()
+		s.fresh = false
+		s.snapMu.Unlock()
+	}
+
+	go func() {
+		defer locks.Close()
+		defer watcher.Close()
+		for {
+			select {
+			case <-locks.C:
+				invalidate()
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Base(event.Name) == config.ConfigFileName {
+					if cfg, err := config.Load(s.coordDir); err == nil {
+						s.setConfig(cfg)
+					}
+					invalidate()
+				}
+			case <-watcher.Errors:
+				// Events may have been lost
+				invalidate()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// snapshot returns the current locks, reading them from disk only if
+// something changed since the last read
+func (s *Server) snapshot() ([]lock.Lock, error) {
+	lockMgr, _ := s.managers()
+
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+	if s.fresh {
+		return s.locks, nil
+	}
+
+	locks, err := lockMgr.List()
+	if err != nil {
+		return nil, err
+	}
+	s.locks = locks
+	s.fresh = s.watched
+	return locks, nil
+}
+
+func (s *Server) serveConn(ctx context.Context, shutdown context.CancelFunc, conn net.Conn) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	// The client sends nothing more, so a read returns when it hangs up
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		buf := make([]byte, 1)
+		conn.Read(buf)
+		cancel()
+	}()
+
+	enc := json.NewEncoder(conn)
+	s.handle(ctx, &req, func(resp *Response) { enc.Encode(resp) }, shutdown)
+}
+
+// handle answers req, sending the final response, and any progress before
+// it, with send. ctx is done when the client goes away. shutdown is nil
+// where clients may not stop the server.
+func (s *Server) handle(ctx context.Context, req *Request, send func(*Response), shutdown context.CancelFunc) {
+	reply := func(result any, err error) {
+		var resp Response
+		if err != nil {
+			resp = errorResponse(err)
+		} else if result != nil {
+			data, err := json.Marshal(result)
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			resp.Result = data
+		}
+		send(&resp)
+	}
+
+	lockMgr, agentMgr := s.managers()
+	mode := req.Mode
+	if mode == "" {
+		mode = lock.Exclusive
+	}
+
+	switch req.Op {
+	case OpPing:
+		reply(map[string]int{"pid": os.Getpid()}, nil)
+
+	case OpLock:
+		if !req.Wait {
+			reply(nil, lockMgr.AcquireAll(req.Resources, mode, req.AgentID, req.AgentName, req.Operation, req.TTL))
+			return
+		}
+		if req.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(req.Timeout)*time.Second)
+			defer cancel()
+		}
+		reply(nil, lockMgr.AcquireContext(ctx, req.Resources, mode, req.AgentID, req.AgentName, req.Operation, req.TTL))
+
+	case OpUnlock:
+		if req.All {
+			reply(nil, lockMgr.ReleaseAll(req.AgentID))
+			return
+		}
+		release := lockMgr.Release
+		if req.Force {
+			release = lockMgr.ForceRelease
+		}
+		for _, r := range req.Resources {
+			if err := release(r, req.AgentID); err != nil {
+				reply(nil, err)
+				return
+			}
+		}
+		reply(nil, nil)
+
+	case OpRenew:
+		if req.All {
+			reply(nil, lockMgr.RenewAll(req.AgentID))
+			return
+		}
+		for _, r := range req.Resources {
+			if err := lockMgr.Renew(r, req.AgentID, req.TTL); err != nil {
+				reply(nil, err)
+				return
+			}
+		}
+		reply(nil, nil)
+
+	case OpRead:
+		// Resources that aren't locked are left out
+		locks := []lock.Lock{}
+		for _, r := range req.Resources {
+			if l, err := lockMgr.Read(r); err == nil {
+				locks = append(locks, *l)
+			}
+		}
+		reply(locks, nil)
+
+	case OpVerify:
+		if len(req.Resources) != 1 {
+			reply(nil, fmt.Errorf("verify takes exactly one resource"))
+			return
+		}
+		reply(nil, lockMgr.Verify(req.Resources[0], req.Token))
+
+	case OpCheck:
+		reply(s.check(req))
+
+	case OpStatus:
+		reply(s.status(lockMgr, agentMgr))
+
+	case OpWait:
+		reply(nil, s.wait(ctx, send, req, mode))
+
+	case OpRegister:
+		if _, err := agentMgr.Read(req.AgentID); err == nil && req.Resume {
+			reply(nil, agentMgr.Heartbeat(req.AgentID))
+			return
+		}
+		reply(nil, agentMgr.Register(req.AgentID, req.AgentName))
+
+	case OpHeartbeat:
+		var err error
+		if len(req.Files) > 0 {
+			err = agentMgr.RecordFiles(req.AgentID, req.Files)
+		} else {
+			err = agentMgr.Heartbeat(req.AgentID)
+		}
+		if err == nil && req.RenewLocks {
+			err = lockMgr.RenewAll(req.AgentID)
+		}
+		reply(nil, err)
+
+	case OpDeregister:
+		reply(nil, agentMgr.Deregister(req.AgentID))
+
+	case OpTask:
+		reply(nil, agentMgr.UpdateTask(req.AgentID, req.Task))
+
+	case OpShutdown:
+		if shutdown == nil {
+			reply(nil, fmt.Errorf("shutdown is not allowed here"))
+			return
+		}
+		reply(nil, nil)
+		shutdown()
+
+	default:
+		reply(nil, fmt.Errorf("unknown operation: %s", req.Op))
+	}
+}
+
+// check answers from memory where it can: files known not to be protected
+// aren't looked at again, and plain checks use the lock snapshot. Only
+// protected files are returned.
+func (s *Server) check(req *Request) ([]*lock.FileCheck, error) {
+	lockMgr, _ := s.managers()
+
+	var results []*lock.FileCheck
+	for _, f := range req.Files {
+		s.mu.RLock()
+		skip := s.notProtected[f]
+		s.mu.RUnlock()
+		if skip {
+			continue
+		}
+
+		var result *lock.FileCheck
+		if req.Acquire {
+			var err error
+			result, err = lockMgr.CheckFile(f, true, req.AgentID, req.AgentName, req.Operation)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			locks, err := s.snapshot()
+			if err != nil {
+				return nil, err
+			}
+			result = lockMgr.CheckFileIn(locks, f, req.AgentID)
+		}
+
+		if !result.Protected {
+			s.mu.Lock()
+			s.notProtected[f] = true
+			s.mu.Unlock()
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *Server) status(lockMgr *lock.Manager, agentMgr *agent.Manager) (*Status, error) {
+	locks, err := s.snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locks: %w", err)
+	}
+	agents, err := agentMgr.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	queues, err := lockMgr.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+	deadlocks, err := lockMgr.Deadlocks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for deadlocks: %w", err)
+	}
+	return &Status{
+		Locks:     locks,
+		Leases:    LeasesFor(lockMgr, locks),
+		Agents:    agents,
+		Queues:    queues,
+		Deadlocks: deadlocks,
+	}, nil
+}
+
+// wait runs a wait for the client, streaming progress to it. The wait is
+// abandoned if ctx is done, e.g. because the client went away.
+func (s *Server) wait(ctx context.Context, send func(*Response), req *Request, mode lock.Mode) error {
+	lockMgr, _ := s.managers()
+
+	if len(req.Resources) != 1 {
+		return fmt.Errorf("wait takes exactly one resource")
+	}
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	return lockMgr.Wait(ctx, req.Resources[0], req.AgentID, lock.WaitOptions{
+		Mode:      mode,
+		Acquire:   req.Acquire,
+		AgentName: req.AgentName,
+		Operation: req.Operation,
+		TTL:       req.TTL,
+		Interval:  time.Duration(req.Interval) * time.Second,
+		Progress: func(status lock.WaitStatus) {
+			p := &Progress{Holder: status.Holder, Ahead: status.Ahead, Position: status.Position}
+			if status.Err != nil {
+				p.Error = status.Err.Error()
+			}
+			send(&Response{Progress: p})
+		},
+	})
+}
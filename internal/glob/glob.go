@@ -0,0 +1,372 @@
+// Package glob decides whether two doublestar patterns can match the same path.
+//
+// Patterns are compiled into small NFAs that follow the matching rules of
+// github.com/bmatcuk/doublestar/v4: '*' and '?' never match '/', "**" as a
+// whole path segment matches any number of segments, braces are alternations
+// and character classes support ranges and negation.
+package glob
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrBadPattern is returned for malformed patterns
+var ErrBadPattern = errors.New("syntax error in pattern")
+
+// maxAlternatives bounds brace expansion so a hostile pattern can't explode
+const maxAlternatives = 1024
+
+// Overlaps reports whether at least one path is matched by both patterns
+func Overlaps(a, b string) (bool, error) {
+	if a == b {
+		return true, nil
+	}
+
+	na, err := compile(a)
+	if err != nil {
+		return false, fmt.Errorf("%q: %w", a, err)
+	}
+	nb, err := compile(b)
+	if err != nil {
+		return false, fmt.Errorf("%q: %w", b, err)
+	}
+
+	return na.intersects(nb), nil
+}
+
+// runeRange is an inclusive range of runes
+type runeRange struct {
+	lo, hi rune
+}
+
+// charSet is a set of runes: those inside ranges, or outside them if negated
+type charSet struct {
+	ranges  []runeRange
+	negated bool
+}
+
+var (
+	anyChar     = charSet{negated: true}
+	anyButSlash = charSet{ranges: []runeRange{{'/', '/'}}, negated: true}
+	slash       = literal('/')
+)
+
+func literal(r rune) charSet {
+	return charSet{ranges: []runeRange{{r, r}}}
+}
+
+func (s charSet) contains(r rune) bool {
+	for _, rr := range s.ranges {
+		if r >= rr.lo && r <= rr.hi {
+			return !s.negated
+		}
+	}
+	return s.negated
+}
+
+// intersects reports whether some rune belongs to both sets. Membership only
+// changes at range boundaries, so it is enough to probe one rune from each of
+// the intervals the boundaries of both sets cut the rune space into.
+func (s charSet) intersects(o charSet) bool {
+	probes := []rune{0, '/', '/' + 1}
+	for _, set := range []charSet{s, o} {
+		for _, rr := range set.ranges {
+			probes = append(probes, rr.lo, rr.hi+1)
+		}
+	}
+
+	for _, r := range probes {
+		if r < 0 || r > utf8.MaxRune {
+			continue
+		}
+		if s.contains(r) && o.contains(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type edge struct {
+	set charSet
+	to  int
+}
+
+type nfa struct {
+	eps    [][]int
+	edges  [][]edge
+	start  int
+	accept []bool
+}
+
+func (n *nfa) newState() int {
+	n.eps = append(n.eps, nil)
+	n.edges = append(n.edges, nil)
+	n.accept = append(n.accept, false)
+	return len(n.eps) - 1
+}
+
+func (n *nfa) addEps(from, to int) {
+	n.eps[from] = append(n.eps[from], to)
+}
+
+func (n *nfa) addEdge(from int, set charSet, to int) {
+	n.edges[from] = append(n.edges[from], edge{set: set, to: to})
+}
+
+// intersects searches the product automaton for a reachable pair of
+// accepting states
+func (n *nfa) intersects(o *nfa) bool {
+	type pair struct{ a, b int }
+
+	start := pair{n.start, o.start}
+	seen := map[pair]bool{start: true}
+	queue := []pair{start}
+
+	visit := func(p pair) {
+		if !seen[p] {
+			seen[p] = true
+			queue = append(queue, p)
+		}
+	}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if n.accept[p.a] && o.accept[p.b] {
+			return true
+		}
+
+		for _, to := range n.eps[p.a] {
+			visit(pair{to, p.b})
+		}
+		for _, to := range o.eps[p.b] {
+			visit(pair{p.a, to})
+		}
+		for _, ea := range n.edges[p.a] {
+			for _, eb := range o.edges[p.b] {
+				if ea.set.intersects(eb.set) {
+					visit(pair{ea.to, eb.to})
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// compile builds one NFA accepting every path matched by pattern
+func compile(pattern string) (*nfa, error) {
+	alternatives, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &nfa{}
+	n.start = n.newState()
+	for _, alt := range alternatives {
+		end, err := n.build([]rune(alt), n.start)
+		if err != nil {
+			return nil, err
+		}
+		n.accept[end] = true
+	}
+	return n, nil
+}
+
+// build adds states for a brace-free pattern starting at state cur and
+// returns the final state
+func (n *nfa) build(p []rune, cur int) (int, error) {
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '\\':
+			if i+1 >= len(p) {
+				return 0, ErrBadPattern
+			}
+			i++
+			next := n.newState()
+			n.addEdge(cur, literal(p[i]), next)
+			cur = next
+
+		case '?':
+			next := n.newState()
+			n.addEdge(cur, anyButSlash, next)
+			cur = next
+
+		case '[':
+			set, end, err := parseClass(p, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+			next := n.newState()
+			n.addEdge(cur, set, next)
+			cur = next
+
+		case '/':
+			// "/**" at the end also matches the directory itself
+			if i+3 == len(p) && p[i+1] == '*' && p[i+2] == '*' {
+				end, rest := n.newState(), n.newState()
+				n.addEps(cur, end)
+				n.addEdge(cur, slash, rest)
+				n.addEdge(rest, anyChar, rest)
+				n.addEps(rest, end)
+				return end, nil
+			}
+			next := n.newState()
+			n.addEdge(cur, slash, next)
+			cur = next
+
+		case '*':
+			segStart := i == 0 || p[i-1] == '/'
+			if segStart && i+1 < len(p) && p[i+1] == '*' {
+				switch {
+				case i+2 == len(p):
+					// A pattern of just "**" matches everything
+					rest := n.newState()
+					n.addEps(cur, rest)
+					n.addEdge(rest, anyChar, rest)
+					return rest, nil
+				case p[i+2] == '/':
+					// "**/" matches zero or more whole segments
+					loop, seg, end := n.newState(), n.newState(), n.newState()
+					n.addEps(cur, loop)
+					n.addEps(loop, end)
+					n.addEdge(loop, slash, loop)
+					n.addEdge(loop, anyButSlash, seg)
+					n.addEdge(seg, anyButSlash, seg)
+					n.addEdge(seg, slash, loop)
+					cur = end
+					i += 2
+					continue
+				}
+			}
+			next := n.newState()
+			n.addEps(cur, next)
+			n.addEdge(next, anyButSlash, next)
+			cur = next
+
+		default:
+			next := n.newState()
+			n.addEdge(cur, literal(c), next)
+			cur = next
+		}
+	}
+	return cur, nil
+}
+
+// parseClass parses the character class starting at p[start] == '[' and
+// returns it along with the index of the closing ']'
+func parseClass(p []rune, start int) (charSet, int, error) {
+	var set charSet
+	i := start + 1
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		set.negated = true
+		i++
+	}
+
+	for ; i < len(p); i++ {
+		if p[i] == ']' {
+			if len(set.ranges) == 0 {
+				return charSet{}, 0, ErrBadPattern
+			}
+			return set, i, nil
+		}
+
+		lo, next, err := classChar(p, i)
+		if err != nil {
+			return charSet{}, 0, err
+		}
+		i = next
+		hi := lo
+		if i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']' {
+			hi, i, err = classChar(p, i+2)
+			if err != nil {
+				return charSet{}, 0, err
+			}
+			if hi < lo {
+				return charSet{}, 0, ErrBadPattern
+			}
+		}
+		set.ranges = append(set.ranges, runeRange{lo, hi})
+	}
+
+	return charSet{}, 0, ErrBadPattern
+}
+
+// classChar reads a possibly escaped rune inside a class
+func classChar(p []rune, i int) (rune, int, error) {
+	if p[i] == '\\' {
+		if i+1 >= len(p) {
+			return 0, 0, ErrBadPattern
+		}
+		return p[i+1], i + 1, nil
+	}
+	return p[i], i, nil
+}
+
+// expandBraces rewrites a pattern with {a,b} alternations into the list of
+// brace-free patterns it stands for
+func expandBraces(pattern string) ([]string, error) {
+	p := []rune(pattern)
+
+	open := -1
+	inClass := false
+	for i := 0; i < len(p) && open < 0; i++ {
+		switch {
+		case p[i] == '\\':
+			i++
+		case inClass:
+			inClass = p[i] != ']'
+		case p[i] == '[':
+			inClass = true
+		case p[i] == '{':
+			open = i
+		}
+	}
+	if open < 0 {
+		return []string{pattern}, nil
+	}
+
+	var alts []string
+	depth := 0
+	altStart := open + 1
+	inClass = false
+	for i := open + 1; i < len(p); i++ {
+		switch {
+		case p[i] == '\\':
+			i++
+		case inClass:
+			inClass = p[i] != ']'
+		case p[i] == '[':
+			inClass = true
+		case p[i] == '{':
+			depth++
+		case p[i] == ',' && depth == 0:
+			alts = append(alts, string(p[altStart:i]))
+			altStart = i + 1
+		case p[i] == '}' && depth > 0:
+			depth--
+		case p[i] == '}':
+			alts = append(alts, string(p[altStart:i]))
+			prefix, suffix := string(p[:open]), string(p[i+1:])
+
+			var out []string
+			for _, alt := range alts {
+				expanded, err := expandBraces(prefix + alt + suffix)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, expanded...)
+				if len(out) > maxAlternatives {
+					return nil, fmt.Errorf("too many brace alternatives: %w", ErrBadPattern)
+				}
+			}
+			return out, nil
+		}
+	}
+
+	return nil, ErrBadPattern
+}
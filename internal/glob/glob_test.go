@@ -0,0 +1,138 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		// Identical and literal
+		{"db/schema/*", "db/schema/*", true},
+		{"package.json", "package.json", true},
+		{"package.json", "package-lock.json", false},
+
+		// Globstar
+		{"db/**/*", "db/schema/*", true},
+		{"db/**/*", "db/schema/users.sql", true},
+		{"db/**/*", "migrations/**/*", false},
+		{"db/**", "db", true},
+		{"db/**/*", "db", false},
+		{"**/*.sql", "db/schema/*", true},
+		{"**/*.sql", "db/schema/*.prisma", false},
+		{"**", "anything/at/all", true},
+		{"src/**/test/*.go", "src/*/test/x.go", true},
+		{"a/**/b", "a/b", true},
+
+		// Star and question mark never cross '/'
+		{"db/*", "db/schema/users.sql", false},
+		{"db/*", "db/s?", true},
+		{"db/?", "db/ab", false},
+		{"a**", "a/b", false},
+		{"*.env", ".env*", true},
+		{"db/schema/*", "db-schema-?", false},
+
+		// Braces
+		{"**/*.{sql,prisma}", "prisma/schema.prisma", true},
+		{"**/*.{sql,prisma}", "package.json", false},
+		{"{a,b/**}", "b", true},
+		{"a/{**,x}/c", "a/c", true},
+		{"{a,{b,c}}", "c", true},
+		{"{a,{b,c}}", "d", false},
+
+		// Character classes
+		{"file[0-9].txt", "file5.txt", true},
+		{"file[0-9].txt", "file[a-z].txt", false},
+		{"file[!0-9].txt", "file[0-9].txt", false},
+		{"file[!0-9].txt", "file?.txt", true},
+		{"file[^a].txt", "file[a].txt", false},
+		{"[a-c]", "[c-e]", true},
+		{"[a-c]", "[d-f]", false},
+
+		// Escapes
+		{"a\\*", "a*", true},
+		{"a\\*", "ab", false},
+	}
+
+	for _, tt := range tests {
+		got, err := Overlaps(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("Overlaps(%q, %q) error: %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Overlaps(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+
+		// Overlap is symmetric
+		if rev, _ := Overlaps(tt.b, tt.a); rev != got {
+			t.Errorf("Overlaps(%q, %q) = %v, not symmetric", tt.b, tt.a, rev)
+		}
+	}
+}
+
+func TestOverlapsBadPattern(t *testing.T) {
+	for _, p := range []string{"[abc", "a{b,c", "a\\", "[]", "[z-a]"} {
+		if _, err := Overlaps(p, "x"); err == nil {
+			t.Errorf("Expected error for pattern %q", p)
+		}
+	}
+}
+
+// TestOverlapsAgreesWithDoublestar checks that whenever doublestar says a path
+// matches two patterns, Overlaps reports them as overlapping, and that every
+// overlap it reports is witnessed by some path in the corpus.
+func TestOverlapsAgreesWithDoublestar(t *testing.T) {
+	patterns := []string{
+		"*", "**", "a", "a/*", "a/**", "a/**/*", "**/b", "a/**/b", "*/b",
+		"a?", "a/?", "?/b", "[ab]", "[!a]", "a/[bc]/*", "{a,b}/c", "a/{b,c/**}",
+		"**/*.sql", "a/*.sql", "*.{sql,go}", "a*b", "a/b*", "**/c/*",
+	}
+
+	segments := []string{"a", "b", "c", "ab", "b.sql", "y.go"}
+	var paths []string
+	var build func(prefix string, depth int)
+	build = func(prefix string, depth int) {
+		if depth == 0 {
+			return
+		}
+		for _, s := range segments {
+			p := s
+			if prefix != "" {
+				p = prefix + "/" + s
+			}
+			paths = append(paths, p)
+			build(p, depth-1)
+		}
+	}
+	build("", 4)
+
+	for _, a := range patterns {
+		for _, b := range patterns {
+			witnessed := false
+			for _, path := range paths {
+				ma, _ := doublestar.Match(a, path)
+				mb, _ := doublestar.Match(b, path)
+				if ma && mb {
+					witnessed = true
+					break
+				}
+			}
+
+			got, err := Overlaps(a, b)
+			if err != nil {
+				t.Fatalf("Overlaps(%q, %q) error: %v", a, b, err)
+			}
+			if witnessed && !got {
+				t.Errorf("Overlaps(%q, %q) = false, but both match a common path", a, b)
+			}
+			if got && !witnessed {
+				t.Errorf("Overlaps(%q, %q) = true, but no common path in corpus", a, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package hook parses the JSON payloads Claude Code sends to hook commands
+// and formats the replies it understands.
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Input is the payload Claude Code writes to a hook command's stdin. Fields
+// that don't apply to an event are left empty.
+type Input struct {
+	SessionID      string    `json:"session_id"`
+	TranscriptPath string    `json:"transcript_path,omitempty"`
+	Cwd            string    `json:"cwd,omitempty"`
+	HookEventName  string    `json:"hook_event_name,omitempty"`
+	Source         string    `json:"source,omitempty"`
+	ToolName       string    `json:"tool_name,omitempty"`
+	ToolInput      ToolInput `json:"tool_input,omitempty"`
+}
+
+// ToolInput holds the arguments of the file-editing tools
+type ToolInput struct {
+	FilePath     string `json:"file_path,omitempty"`
+	NotebookPath string `json:"notebook_path,omitempty"`
+	Edits        []Edit `json:"edits,omitempty"`
+}
+
+// Edit is one edit of a MultiEdit call. Edits normally apply to the call's
+// file_path, but may name their own file.
+type Edit struct {
+	FilePath string `json:"file_path,omitempty"`
+}
+
+// Read parses a hook payload
+func Read(r io.Reader) (*Input, error) {
+	var in Input
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("failed to parse hook input: %w", err)
+	}
+	return &in, nil
+}
+
+// FilePaths returns the files the tool call touches, without duplicates and
+// in the order they appear
+func (in *Input) FilePaths() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	add(in.ToolInput.FilePath)
+	add(in.ToolInput.NotebookPath)
+	for _, e := range in.ToolInput.Edits {
+		add(e.FilePath)
+	}
+	return paths
+}
+
+// RelativePaths returns FilePaths relative to the root of the project the
+// session is working in, with forward slashes, as protected patterns are
+// written. Files outside the project are returned unchanged.
+func (in *Input) RelativePaths() []string {
+	root := ProjectRoot(in.Cwd)
+
+	var rel []string
+	for _, p := range in.FilePaths() {
+		if filepath.IsAbs(p) && root != "" {
+			if r, err := filepath.Rel(root, p); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+				p = r
+			}
+		}
+		rel = append(rel, filepath.ToSlash(p))
+	}
+	return rel
+}
+
+// ProjectRoot returns the top of the git worktree containing dir, or dir
+// itself outside git
+func ProjectRoot(dir string) string {
+	args := []string{"rev-parse", "--show-toplevel"}
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return dir
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Decision is a PreToolUse hook's reply
+type Decision struct {
+	HookSpecificOutput DecisionOutput `json:"hookSpecificOutput"`
+}
+
+// DecisionOutput carries the permission decision and the reason shown to
+// Claude
+type DecisionOutput struct {
+	HookEventName            string `json:"hookEventName"`
+	PermissionDecision       string `json:"permissionDecision"`
+	PermissionDecisionReason string `json:"permissionDecisionReason,omitempty"`
+}
+
+// Block returns a reply that stops the tool call, telling Claude why
+func Block(reason string) *Decision {
+	return &Decision{HookSpecificOutput: DecisionOutput{
+		HookEventName:            "PreToolUse",
+		PermissionDecision:       "deny",
+		PermissionDecisionReason: reason,
+	}}
+}
+
+// Write sends the reply to Claude Code
+func (d *Decision) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(d)
+}
@@ -0,0 +1,85 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadPreToolUse(t *testing.T) {
+	payload := `{
+		"session_id": "abc123",
+		"hook_event_name": "PreToolUse",
+		"tool_name": "MultiEdit",
+		"tool_input": {
+			"file_path": "/repo/db/schema/my users.sql",
+			"edits": [
+				{"old_string": "a", "new_string": "b"},
+				{"file_path": "/repo/db/schema/orders.sql", "old_string": "c", "new_string": "d"}
+			]
+		}
+	}`
+
+	in, err := Read(strings.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.SessionID != "abc123" || in.ToolName != "MultiEdit" {
+		t.Errorf("Unexpected input: %+v", in)
+	}
+
+	want := []string{"/repo/db/schema/my users.sql", "/repo/db/schema/orders.sql"}
+	if got := in.FilePaths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	if _, err := Read(strings.NewReader("not json")); err == nil {
+		t.Error("Expected error for invalid payload")
+	}
+}
+
+func TestRelativePaths(t *testing.T) {
+	root := t.TempDir()
+	in := &Input{
+		Cwd: root,
+		ToolInput: ToolInput{
+			FilePath: filepath.Join(root, "db", "schema", "users.sql"),
+			Edits: []Edit{
+				{FilePath: "src/api/handler.go"},
+				{FilePath: filepath.Join(filepath.Dir(root), "elsewhere.go")},
+			},
+		},
+	}
+
+	got := in.RelativePaths()
+	want := []string{
+		"db/schema/users.sql",
+		"src/api/handler.go",
+		filepath.ToSlash(filepath.Join(filepath.Dir(root), "elsewhere.go")),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestBlockDecision(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Block("locked by agent-2").Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	hso := out["hookSpecificOutput"]
+	if hso["hookEventName"] != "PreToolUse" || hso["permissionDecision"] != "deny" ||
+		hso["permissionDecisionReason"] != "locked by agent-2" {
+		t.Errorf("Unexpected decision: %s", buf.String())
+	}
+}
@@ -0,0 +1,100 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Retry delays for AcquireContext. Waiters are normally woken by change
+// notifications; the backoff covers locks that expire without changing and
+// stores that can't notify.
+const (
+	minAcquireBackoff = 50 * time.Millisecond
+	maxAcquireBackoff = 2 * time.Second
+)
+
+// AcquireContext is AcquireAll that blocks while other agents hold the
+// resources, until the locks are granted or ctx is done. It retries when a
+// lock changes and otherwise backs off exponentially, with jitter so
+// waiters don't all retry at once. Errors other than ErrLocked are returned
+// straight away, as is ErrDeadlock if the holder is waiting for us.
+//
+// Unlike Wait with Acquire, it doesn't take a place in line: queued agents
+// are served first. The wait is registered, so it shows up in status and
+// deadlock detection. When ctx's deadline passes the error matches
+// ErrTimeout; when ctx is cancelled it's ctx.Err().
+func (m *Manager) AcquireContext(ctx context.Context, resources []string, mode Mode, agentID, agentName, operation string, ttl int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := m.AcquireAll(resources, mode, agentID, agentName, operation, ttl)
+	if err == nil || !errors.Is(err, ErrLocked) {
+		return err
+	}
+
+	ordered := canonicalOrder(resources)
+	tickets := make([]*Ticket, len(ordered))
+	for i, resource := range ordered {
+		ticket, watchErr := m.Watch(resource, mode, agentID, agentName, operation)
+		if watchErr != nil {
+			return fmt.Errorf("failed to join queue: %w", watchErr)
+		}
+		tickets[i] = ticket
+		defer m.Dequeue(resource, agentID)
+	}
+	if err := m.CheckDeadlock(agentID); err != nil {
+		return fmt.Errorf("cannot wait for %s: %w", strings.Join(ordered, ", "), err)
+	}
+
+	var changes <-chan struct{}
+	if watcher, err := m.WatchLocks(); err == nil {
+		defer watcher.Close()
+		changes = watcher.C
+	}
+
+	backoff := minAcquireBackoff
+	for {
+		timer := time.NewTimer(jitter(backoff))
+		select {
+		case <-changes:
+		case <-timer.C:
+			if backoff *= 2; backoff > maxAcquireBackoff {
+				backoff = maxAcquireBackoff
+			}
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return errorf(CodeTimeout, "timeout waiting to lock %s (%v)", strings.Join(ordered, ", "), err)
+			}
+			return ctx.Err()
+		}
+		timer.Stop()
+
+		for i, ticket := range tickets {
+			if current, _ := m.Ticket(ticket.Resource, agentID); current != nil && current.Aborted != "" {
+				// gc cancelled our wait to break a deadlock
+				return errorf(CodeDeadlock, "gave up waiting for %s: %s", ticket.Resource, current.Aborted)
+			}
+			if m.Touch(ticket) != nil {
+				// Our ticket was cleaned up; register again
+				if t, err := m.Watch(ticket.Resource, mode, agentID, agentName, operation); err == nil {
+					tickets[i] = t
+				}
+			}
+		}
+
+		err = m.AcquireAll(resources, mode, agentID, agentName, operation, ttl)
+		if err == nil || !errors.Is(err, ErrLocked) {
+			return err
+		}
+	}
+}
+
+// jitter returns a random duration between half of d and d
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,289 @@
+package lock
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// WaitEdge records that one agent is waiting for another: the waiter wants
+// Resource, and Holder either holds a conflicting lock or is queued for it
+// ahead of the waiter
+type WaitEdge struct {
+	Waiter   string `json:"waiter"`
+	Holder   string `json:"holder"`
+	Resource string `json:"resource"`
+}
+
+// Deadlock is a cycle in the wait-for graph. Each edge's holder is the next
+// edge's waiter, and the last edge's holder is the first edge's waiter.
+type Deadlock []WaitEdge
+
+// String describes the cycle, e.g.
+// "a waits for b on 'x' → b waits for a on 'y'"
+func (d Deadlock) String() string {
+	parts := make([]string, len(d))
+	for i, e := range d {
+		parts[i] = fmt.Sprintf("%s waits for %s on '%s'", e.Waiter, e.Holder, e.Resource)
+	}
+	return strings.Join(parts, " → ")
+}
+
+// Involves reports whether agentID is waiting in the cycle
+func (d Deadlock) Involves(agentID string) bool {
+	for _, e := range d {
+		if e.Waiter == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitGraph builds the wait-for graph from the registered waiters and the
+// current lock holders
+func (m *Manager) WaitGraph() ([]WaitEdge, error) {
+	locks, err := m.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locks: %w", err)
+	}
+	queues, err := m.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	var edges []WaitEdge
+	seen := make(map[WaitEdge]bool)
+	add := func(e WaitEdge) {
+		if e.Waiter != e.Holder && !seen[e] {
+			seen[e] = true
+			edges = append(edges, e)
+		}
+	}
+
+	for _, tickets := range queues {
+		for i, t := range tickets {
+			want := Lock{Resource: t.Resource, Mode: t.Mode}
+			if logical := m.cfg.FindLogical(t.Resource); logical != nil {
+				want.Files = logical.Files
+			}
+
+			// Holders of the resource, or of any lock overlapping it
+			for _, l := range locks {
+				if t.Mode == Shared && l.IsShared() {
+					continue
+				}
+				if l.Resource != t.Resource && !patternsOverlap(want.Patterns(), l.Patterns()) {
+					continue
+				}
+				for _, h := range m.liveHolders(&l) {
+					add(WaitEdge{Waiter: t.AgentID, Holder: h.AgentID, Resource: t.Resource})
+				}
+			}
+
+			// Queued agents that will be served first
+			if t.Watch {
+				continue
+			}
+			for _, ahead := range tickets[:i] {
+				if ahead.Watch || (t.Mode == Shared && ahead.Mode == Shared) {
+					continue
+				}
+				add(WaitEdge{Waiter: t.AgentID, Holder: ahead.AgentID, Resource: t.Resource})
+			}
+		}
+	}
+
+	return edges, nil
+}
+
+// Deadlocks returns a cycle in the wait-for graph for every group of agents
+// deadlocked on each other. Use CheckDeadlock to find one agent's.
+func (m *Manager) Deadlocks() ([]Deadlock, error) {
+	edges, err := m.WaitGraph()
+	if err != nil {
+		return nil, err
+	}
+	return findCycles(edges), nil
+}
+
+// CheckDeadlock returns an error naming the cycle if agentID is waiting in
+// a deadlock
+func (m *Manager) CheckDeadlock(agentID string) error {
+	edges, err := m.WaitGraph()
+	if err != nil {
+		return err
+	}
+	if d := shortestCycle(waitsFor(edges), agentID, nil); d != nil {
+		return deadlockError(d)
+	}
+	return nil
+}
+
+// BreakDeadlocks cancels one wait in every deadlock, the one that started
+// most recently. Cancelled waits are reported as "resource (agent)".
+func (m *Manager) BreakDeadlocks() ([]string, error) {
+	var broken []string
+
+	// Each round cancels one wait per group of deadlocked agents. A group
+	// can have more than one cycle, so look again until none are left.
+	for round := 0; round < 100; round++ {
+		deadlocks, err := m.Deadlocks()
+		if err != nil {
+			return broken, err
+		}
+		if len(deadlocks) == 0 {
+			return broken, nil
+		}
+
+		for _, d := range deadlocks {
+			var victim *Ticket
+			for _, e := range d {
+				t, err := m.Ticket(e.Resource, e.Waiter)
+				if err != nil || t == nil || t.Aborted != "" {
+					continue
+				}
+				if victim == nil || t.EnqueuedAt.After(victim.EnqueuedAt) {
+					victim = t
+				}
+			}
+			if victim == nil {
+				// Already broken by an earlier cancellation this round
+				continue
+			}
+
+			if err := m.Abort(victim.Resource, victim.AgentID, deadlockError(d).Error()); err != nil {
+				return broken, fmt.Errorf("failed to cancel wait for '%s': %w", victim.Resource, err)
+			}
+			broken = append(broken, fmt.Sprintf("%s (%s)", victim.Resource, victim.AgentID))
+		}
+	}
+
+	return broken, nil
+}
+
+func deadlockError(d Deadlock) error {
+	return errorf(CodeDeadlock, "deadlock detected: %s", d)
+}
+
+// findCycles returns one cycle for every group of agents deadlocked on each
+// other, i.e. every strongly connected component of the wait-for graph. An
+// agent can be in several cycles, but they're all within its group, so
+// breaking them one at a time, as BreakDeadlocks does, ends them all.
+func findCycles(edges []WaitEdge) []Deadlock {
+	adj := waitsFor(edges)
+
+	// Walk agents in a fixed order so the same graph reports the same cycles
+	agents := make([]string, 0, len(adj))
+	for a := range adj {
+		agents = append(agents, a)
+	}
+	sort.Strings(agents)
+
+	// Tarjan's algorithm
+	var (
+		index   = make(map[string]int)
+		low     = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  []Deadlock
+	)
+	var visit func(agent string)
+	visit = func(agent string) {
+		index[agent] = len(index)
+		low[agent] = index[agent]
+		stack = append(stack, agent)
+		onStack[agent] = true
+
+		for _, e := range adj[agent] {
+			if _, seen := index[e.Holder]; !seen {
+				visit(e.Holder)
+				low[agent] = min(low[agent], low[e.Holder])
+			} else if onStack[e.Holder] {
+				low[agent] = min(low[agent], index[e.Holder])
+			}
+		}
+		if low[agent] != index[agent] {
+			return
+		}
+
+		// agent is the root of a component: pop it off the stack
+		group := make(map[string]bool)
+		first := agent
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			group[top] = true
+			if top < first {
+				first = top
+			}
+			if top == agent {
+				break
+			}
+		}
+		if len(group) > 1 {
+			cycles = append(cycles, shortestCycle(adj, first, group))
+		}
+	}
+
+	for _, a := range agents {
+		if _, seen := index[a]; !seen {
+			visit(a)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0].Waiter < cycles[j][0].Waiter })
+	return cycles
+}
+
+// waitsFor indexes the wait-for graph by waiter, each agent's edges in a
+// fixed order
+func waitsFor(edges []WaitEdge) map[string][]WaitEdge {
+	adj := make(map[string][]WaitEdge)
+	for _, e := range edges {
+		adj[e.Waiter] = append(adj[e.Waiter], e)
+	}
+	for _, out := range adj {
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Holder != out[j].Holder {
+				return out[i].Holder < out[j].Holder
+			}
+			return out[i].Resource < out[j].Resource
+		})
+	}
+	return adj
+}
+
+// shortestCycle returns the shortest cycle from agentID back to itself, or
+// nil if it isn't waiting in one. With within set, the cycle only goes
+// through those agents.
+func shortestCycle(adj map[string][]WaitEdge, agentID string, within map[string]bool) Deadlock {
+	// Breadth-first from agentID, remembering the edge each agent was
+	// reached by
+	via := make(map[string]WaitEdge)
+	queue := []string{agentID}
+	for len(queue) > 0 {
+		agent := queue[0]
+		queue = queue[1:]
+		for _, e := range adj[agent] {
+			if within != nil && !within[e.Holder] {
+				continue
+			}
+			if e.Holder == agentID {
+				cycle := Deadlock{e}
+				for e.Waiter != agentID {
+					e = via[e.Waiter]
+					cycle = append(cycle, e)
+				}
+				slices.Reverse(cycle)
+				return cycle
+			}
+			if _, seen := via[e.Holder]; !seen {
+				via[e.Holder] = e
+				queue = append(queue, e.Holder)
+			}
+		}
+	}
+	return nil
+}
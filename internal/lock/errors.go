@@ -0,0 +1,145 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors lock operations fail with. Use errors.Is to tell them apart; the
+// messages of the errors returned say more.
+var (
+	// ErrLocked means another agent holds a conflicting lock, or the
+	// resource is reserved for a queued agent. Use errors.As with a
+	// *LockedError to find out who.
+	ErrLocked = errors.New("resource is locked")
+
+	// ErrNotLocked means the resource has no lock to act on
+	ErrNotLocked = errors.New("resource is not locked")
+
+	// ErrNotOwner means the lock is held by a different agent
+	ErrNotOwner = errors.New("lock owned by different agent")
+
+	// ErrStale means a fencing token or lease is no longer current
+	ErrStale = errors.New("lock is no longer current")
+
+	// ErrTimeout means a wait or a lock update ran out of time
+	ErrTimeout = errors.New("timeout")
+
+	// ErrDeadlock means waiting would never end, or a wait was cancelled
+	// to break a deadlock
+	ErrDeadlock = errors.New("deadlock detected")
+
+	// ErrInvalidPattern means a resource pattern can't be parsed
+	ErrInvalidPattern = errors.New("invalid resource pattern")
+)
+
+// Error codes name the errors above where they can't be passed as values,
+// e.g. between the daemon and its clients
+const (
+	CodeLocked         = "locked"
+	CodeNotLocked      = "not_locked"
+	CodeNotOwner       = "not_owner"
+	CodeStale          = "stale"
+	CodeTimeout        = "timeout"
+	CodeDeadlock       = "deadlock"
+	CodeInvalidPattern = "invalid_pattern"
+)
+
+var errorCodes = []struct {
+	code string
+	err  error
+}{
+	{CodeLocked, ErrLocked},
+	{CodeNotLocked, ErrNotLocked},
+	{CodeNotOwner, ErrNotOwner},
+	{CodeStale, ErrStale},
+	{CodeTimeout, ErrTimeout},
+	{CodeDeadlock, ErrDeadlock},
+	{CodeInvalidPattern, ErrInvalidPattern},
+}
+
+// ErrorCode returns the code of the kind of error err is, or "" if it's
+// none of them
+func ErrorCode(err error) string {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+// Error is an error of the kind Code names with a message of its own. It
+// matches that kind's error with errors.Is.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	for _, c := range errorCodes {
+		if c.code == e.Code {
+			return c.err == target
+		}
+	}
+	return false
+}
+
+// errorf returns an *Error with the given code and formatted message
+func errorf(code, format string, args ...any) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// LockedError is returned when a lock can't be taken because another agent
+// holds a conflicting one. It matches ErrLocked.
+type LockedError struct {
+	// Resource is the resource that was requested
+	Resource string `json:"resource"`
+
+	// Lock is the conflicting lock, with Holder set to the agent blocking
+	// the request. It's on another resource when the patterns overlap.
+	Lock *Lock `json:"lock,omitempty"`
+
+	// Queued is set instead when the resource is reserved for an agent
+	// waiting in line for it
+	Queued *Ticket `json:"queued,omitempty"`
+}
+
+func (e *LockedError) Error() string {
+	switch {
+	case e.Queued != nil:
+		return fmt.Sprintf("resource '%s' is reserved for queued %s",
+			e.Resource, describeHolder(e.Queued.AgentID, e.Queued.AgentName, e.Queued.Operation))
+	case e.Lock == nil:
+		return fmt.Sprintf("resource '%s' is locked", e.Resource)
+	case e.Lock.Resource != e.Resource:
+		return fmt.Sprintf("resource '%s' overlaps '%s' locked by %s",
+			e.Resource, e.Lock.Resource, describeHolder(e.Lock.AgentID, e.Lock.AgentName, e.Lock.Operation))
+	case e.Lock.IsShared():
+		return fmt.Sprintf("resource '%s' is shared by %s",
+			e.Resource, describeHolder(e.Lock.AgentID, e.Lock.AgentName, e.Lock.Operation))
+	}
+	return fmt.Sprintf("resource '%s' is locked by %s",
+		e.Resource, describeHolder(e.Lock.AgentID, e.Lock.AgentName, e.Lock.Operation))
+}
+
+// describeHolder describes an agent holding or waiting for a lock, e.g.
+// "agent 'a1' (Backend): migration", leaving out what isn't known
+func describeHolder(agentID, agentName, operation string) string {
+	s := fmt.Sprintf("agent '%s'", agentID)
+	if agentName != "" {
+		s += fmt.Sprintf(" (%s)", agentName)
+	}
+	if operation != "" {
+		s += ": " + operation
+	}
+	return s
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
+)
+
+// issueToken records and returns the next fencing token for resource. It
+// must be called under the resource's guard. Tokens are persisted before the
+// lock is written, so a failed acquisition skips a number but a token is
+// never handed out twice. Fences outlive their locks so tokens keep
+// increasing across releases.
+func (m *Manager) issueToken(resource string) (uint64, error) {
+	store, err := m.backend()
+	if err != nil {
+		return 0, err
+	}
+
+	var last uint64
+	entry, err := store.Get(storage.Fences, encodeResource(resource))
+	if err == nil {
+		last, _ = strconv.ParseUint(strings.TrimSpace(string(entry.Value)), 10, 64)
+	} else if !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to read fencing token: %w", err)
+	}
+
+	// Never go backwards, even if the fence was lost
+	if existing, err := m.Read(resource); err == nil {
+		for _, h := range existing.AllHolders() {
+			if h.Token > last {
+				last = h.Token
+			}
+		}
+	}
+
+	next := last + 1
+	if err := store.Put(storage.Fences, encodeResource(resource), []byte(strconv.FormatUint(next, 10)+"\n")); err != nil {
+		return 0, fmt.Errorf("failed to write fencing token: %w", err)
+	}
+
+	return next, nil
+}
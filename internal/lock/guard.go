@@ -0,0 +1,93 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
+)
+
+// guardTimeout bounds how long we wait for another process's update
+const guardTimeout = 5 * time.Second
+
+// guardPath is the file serializing read-modify-write updates of resource's
+// lock where the store has no guard of its own
+func (m *Manager) guardPath(resource string) string {
+	return filepath.Join(m.coordDir, config.LocksDir, encodeResource(resource)+".guard")
+}
+
+// withGuard runs fn so that no other update of resource's lock interleaves.
+// See storage.WithGuard.
+func (m *Manager) withGuard(resource string, fn func() error) error {
+	store, _ := m.backend()
+	err := storage.WithGuard(store, encodeResource(resource), m.guardPath(resource), guardTimeout, fn)
+	if errors.Is(err, storage.ErrGuardTimeout) {
+		return errorf(CodeTimeout, "timed out waiting to update lock on '%s'", resource)
+	}
+	return err
+}
+
+// createLock atomically creates the lock for lock.Resource, failing with an
+// os.IsExist error if it's already taken. An existing lock is never
+// overwritten.
+func (m *Manager) createLock(lock *Lock) error {
+	store, err := m.backend()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock: %w", err)
+	}
+	key := encodeResource(lock.Resource)
+	if expiring, ok := store.(storage.Expiring); ok {
+		return expiring.CreateWithTTL(storage.Locks, key, data, lockTTL(lock))
+	}
+	return store.Create(storage.Locks, key, data)
+}
+
+// writeLock replaces the lock for lock.Resource. Readers never see a
+// partial lock. It must be called under the resource's guard.
+func (m *Manager) writeLock(lock *Lock) error {
+	store, err := m.backend()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock: %w", err)
+	}
+	key := encodeResource(lock.Resource)
+	if expiring, ok := store.(storage.Expiring); ok {
+		err = expiring.PutWithTTL(storage.Locks, key, data, lockTTL(lock))
+	} else {
+		err = store.Put(storage.Locks, key, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write lock: %w", err)
+	}
+
+	// The lock now lives under its collision-free key
+	m.removeLegacy(lock.Resource)
+	return nil
+}
+
+// lockTTL is how long the lock is needed: until the last holder's lease
+// runs out. Past that it's stale anyway, so a store may drop it.
+func lockTTL(lock *Lock) time.Duration {
+	var last time.Time
+	for _, h := range lock.AllHolders() {
+		if h.ExpiresAt().After(last) {
+			last = h.ExpiresAt()
+		}
+	}
+	if ttl := time.Until(last); ttl > 0 {
+		return ttl
+	}
+	// Already expired; keep it until someone cleans it up
+	return 0
+}
@@ -0,0 +1,160 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+)
+
+// agents returns the agent registry kept in the same store as the locks
+func (m *Manager) agents() (*agent.Manager, error) {
+	store, err := m.backend()
+	if err != nil {
+		return nil, err
+	}
+	return agent.NewManagerWithStore(m.coordDir, m.cfg, store), nil
+}
+
+// syncHoldings brings the LocksHeld of each of agentIDs' records up to date
+// after their locks changed, along with the task derived from them for
+// agents that haven't set one. It goes through the same store as the
+// change, so a transactional backend commits both together. The records'
+// heartbeats are kept as they were, since the agents may be holders that
+// lost a stale lock; agents that aren't registered or have stopped sending
+// heartbeats are left for gc to remove.
+//
+// It's best effort: the locks have changed either way, so records it fails
+// to update are left for gc's SyncAgents to repair.
+func (m *Manager) syncHoldings(agentIDs ...string) {
+	agents, err := m.agents()
+	if err != nil {
+		return
+	}
+	locks, err := m.List()
+	if err != nil {
+		return
+	}
+
+	for _, id := range agentIDs {
+		a, err := agents.Read(id)
+		if err != nil || !agents.IsAlive(a) {
+			continue
+		}
+		if held := heldIn(locks, id); !slices.Equal(a.LocksHeld, held) {
+			if err := agents.UpdateLocks(id, held); err != nil {
+				continue
+			}
+		}
+		agents.AutoTask(id, TaskFor(locks, id))
+	}
+}
+
+// TaskFor describes what agentID is doing from the locks it holds among
+// locks: the operations they were taken for, each with its resources, e.g.
+// "Adding OAuth (db/schema/*, config/auth.yaml)". It returns "" if the agent
+// holds none.
+func TaskFor(locks []Lock, agentID string) string {
+	var ops []string
+	resources := make(map[string][]string)
+	for _, l := range locks {
+		h := l.HolderFor(agentID)
+		if h == nil {
+			continue
+		}
+		if _, ok := resources[h.Operation]; !ok {
+			ops = append(ops, h.Operation)
+		}
+		resources[h.Operation] = append(resources[h.Operation], l.Resource)
+	}
+
+	parts := make([]string, len(ops))
+	for i, op := range ops {
+		held := strings.Join(resources[op], ", ")
+		if op == "" {
+			parts[i] = "holding " + held
+		} else {
+			parts[i] = fmt.Sprintf("%s (%s)", op, held)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// SyncAgents repairs the LocksHeld of live agents whose records disagree
+// with the locks, e.g. after a crash between writing the two on a backend
+// without transactions, and the tasks derived from them. It returns the
+// agents whose LocksHeld it repaired as "agent (resources now held)".
+func (m *Manager) SyncAgents() ([]string, error) {
+	var repaired []string
+	err := m.atomically(func(m *Manager) error {
+		agents, err := m.agents()
+		if err != nil {
+			return err
+		}
+		list, err := agents.List()
+		if err != nil {
+			return fmt.Errorf("failed to list agents: %w", err)
+		}
+		locks, err := m.List()
+		if err != nil {
+			return fmt.Errorf("failed to list locks: %w", err)
+		}
+
+		for _, a := range list {
+			if !agents.IsAlive(&a) {
+				continue
+			}
+			if held := heldIn(locks, a.ID); !slices.Equal(a.LocksHeld, held) {
+				if err := agents.UpdateLocks(a.ID, held); err != nil {
+					if os.IsNotExist(err) {
+						continue // Deregistered meanwhile
+					}
+					return fmt.Errorf("failed to record locks held by %s: %w", a.ID, err)
+				}
+				now := "none"
+				if len(held) > 0 {
+					now = strings.Join(held, ", ")
+				}
+				repaired = append(repaired, fmt.Sprintf("%s (%s)", a.ID, now))
+			}
+			if err := agents.AutoTask(a.ID, TaskFor(locks, a.ID)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to update task of %s: %w", a.ID, err)
+			}
+		}
+		return nil
+	})
+	return repaired, err
+}
+
+// heldIn returns the resources agentID holds among locks, sorted
+func heldIn(locks []Lock, agentID string) []string {
+	var held []string
+	for _, l := range locks {
+		if l.HeldBy(agentID) {
+			held = append(held, l.Resource)
+		}
+	}
+	sort.Strings(held)
+	return held
+}
+
+// holderIDs returns the agents holding resource, if it's locked
+func (m *Manager) holderIDs(resource string) []string {
+	existing, err := m.Read(resource)
+	if err != nil {
+		return nil
+	}
+	return existing.holderIDs()
+}
+
+// holderIDs returns the agents holding the lock
+func (l *Lock) holderIDs() []string {
+	var ids []string
+	for _, h := range l.AllHolders() {
+		ids = append(ids, h.AgentID)
+	}
+	return ids
+}
@@ -2,44 +2,238 @@ package lock
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
-	"syscall"
+	"sort"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/glob"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
 )
 
+// Mode is how a lock is held
+type Mode string
+
+const (
+	// Exclusive locks have one holder and conflict with every other lock
+	Exclusive Mode = "exclusive"
+	// Shared locks may have many holders and only conflict with exclusive locks
+	Shared Mode = "shared"
+)
+
+// Holder is one agent's claim on a lock
+type Holder struct {
+	AgentID    string     `json:"agent_id"`
+	AgentName  string     `json:"agent_name,omitempty"`
+	Operation  string     `json:"operation,omitempty"`
+	AcquiredAt time.Time  `json:"acquired_at"`
+	RenewedAt  *time.Time `json:"renewed_at,omitempty"`
+	TTLSeconds int        `json:"ttl_seconds"`
+	PID        int        `json:"pid"`
+	Token      uint64     `json:"token,omitempty"`
+
+	// Holds counts how many times the holder has acquired the lock without
+	// releasing it; zero means once
+	Holds int `json:"holds,omitempty"`
+}
+
+// HoldCount returns how many releases it takes for the holder to let go
+func (h *Holder) HoldCount() int {
+	if h.Holds < 1 {
+		return 1
+	}
+	return h.Holds
+}
+
+// LeaseStart returns when the holder's current lease began: the last renewal,
+// or the acquisition if it was never renewed
+func (h *Holder) LeaseStart() time.Time {
+	if h.RenewedAt != nil && h.RenewedAt.After(h.AcquiredAt) {
+		return *h.RenewedAt
+	}
+	return h.AcquiredAt
+}
+
+// ExpiresAt returns when the holder's lease runs out
+func (h *Holder) ExpiresAt() time.Time {
+	return h.LeaseStart().Add(time.Duration(h.TTLSeconds) * time.Second)
+}
+
 type Lock struct {
-	Resource   string    `json:"resource"`
-	AgentID    string    `json:"agent_id"`
-	AgentName  string    `json:"agent_name,omitempty"`
-	Operation  string    `json:"operation,omitempty"`
-	AcquiredAt time.Time `json:"acquired_at"`
-	TTLSeconds int       `json:"ttl_seconds"`
-	PID        int       `json:"pid"`
+	Resource string   `json:"resource"`
+	Mode     Mode     `json:"mode,omitempty"`
+	Files    []string `json:"files,omitempty"`
+
+	// Holder is the exclusive holder. For shared locks it mirrors the first
+	// entry of Holders so older versions still see the lock as taken.
+	Holder
+
+	// Holders lists every holder of a shared lock
+	Holders []Holder `json:"holders,omitempty"`
+}
+
+// IsShared reports whether the lock is held in shared mode
+func (l *Lock) IsShared() bool {
+	return l.Mode == Shared
+}
+
+// AllHolders returns every agent holding the lock
+func (l *Lock) AllHolders() []Holder {
+	if l.IsShared() && len(l.Holders) > 0 {
+		return l.Holders
+	}
+	return []Holder{l.Holder}
+}
+
+// HeldBy reports whether agentID is one of the lock's holders
+func (l *Lock) HeldBy(agentID string) bool {
+	for _, h := range l.AllHolders() {
+		if h.AgentID == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// HolderFor returns agentID's hold on the lock, or nil if it has none
+func (l *Lock) HolderFor(agentID string) *Holder {
+	for _, h := range l.AllHolders() {
+		if h.AgentID == agentID {
+			return &h
+		}
+	}
+	return nil
+}
+
+// OtherHolder returns a holder other than agentID, or nil if there is none
+func (l *Lock) OtherHolder(agentID string) *Holder {
+	for _, h := range l.AllHolders() {
+		if h.AgentID != agentID {
+			return &h
+		}
+	}
+	return nil
+}
+
+// setHolders replaces the holders of a shared lock
+func (l *Lock) setHolders(holders []Holder) {
+	l.Holders = holders
+	if len(holders) > 0 {
+		l.Holder = holders[0]
+	}
+}
+
+// putHolder replaces h.AgentID's hold on the lock with h
+func (l *Lock) putHolder(h Holder) {
+	if !l.IsShared() {
+		l.Holder = h
+		return
+	}
+	holders := append([]Holder(nil), l.Holders...)
+	for i := range holders {
+		if holders[i].AgentID == h.AgentID {
+			holders[i] = h
+		}
+	}
+	l.setHolders(holders)
+}
+
+// Patterns returns the file patterns the lock covers: the file groups of a
+// logical resource, or the resource pattern itself
+func (l *Lock) Patterns() []string {
+	if len(l.Files) > 0 {
+		return l.Files
+	}
+	return []string{l.Resource}
+}
+
+// Covers reports whether the lock applies to the given file
+func (l *Lock) Covers(filePath string) bool {
+	for _, p := range l.Patterns() {
+		if matched, _ := doublestar.Match(p, filePath); matched {
+			return true
+		}
+	}
+	return false
 }
 
 type Manager struct {
 	coordDir string
 	cfg      *config.Config
+
+	// store keeps the locks, fencing tokens and agent heartbeats. Wait
+	// queues always live in the coord dir, so they aren't offered with
+	// backends shared between hosts.
+	store    storage.Store
+	storeErr error
 }
 
 func NewManager(coordDir string, cfg *config.Config) *Manager {
 	if coordDir == "" {
 		coordDir = config.DefaultCoordDir
 	}
+	store, err := storage.Open(coordDir, cfg)
 	return &Manager{
 		coordDir: coordDir,
 		cfg:      cfg,
+		store:    store,
+		storeErr: err,
+	}
+}
+
+// backend returns the store, or why it couldn't be opened
+func (m *Manager) backend() (storage.Store, error) {
+	if m.storeErr != nil {
+		return nil, fmt.Errorf("failed to open %s backend: %w", m.cfg.Settings.Backend, m.storeErr)
+	}
+	return m.store, nil
+}
+
+// atomically runs fn with a Manager whose changes are committed together,
+// for backends that support transactions. Transactions are taken before any
+// guard, so processes can't end up waiting for each other.
+func (m *Manager) atomically(fn func(m *Manager) error) error {
+	store, err := m.backend()
+	if err != nil {
+		return err
 	}
+	tx, ok := store.(storage.Transactional)
+	if !ok {
+		return fn(m)
+	}
+	return tx.Atomically(func(s storage.Store) error {
+		bound := *m
+		bound.store = s
+		return fn(&bound)
+	})
 }
 
-// Acquire attempts to create a lock for the given resource
+// Acquire attempts to create an exclusive lock for the given resource
 func (m *Manager) Acquire(resource, agentID, agentName, operation string, ttl int) error {
+	return m.AcquireMode(resource, Exclusive, agentID, agentName, operation, ttl)
+}
+
+// AcquireMode attempts to lock the given resource in the given mode. Shared
+// locks join existing shared holders; exclusive locks are refused while any
+// other agent holds the resource. An agent that already holds the resource
+// takes another hold on it, which needs a Release of its own.
+func (m *Manager) AcquireMode(resource string, mode Mode, agentID, agentName, operation string, ttl int) error {
+	return m.atomically(func(m *Manager) error {
+		// Holders of a stale lock we take over lose it
+		previous := m.holderIDs(resource)
+		if err := m.acquireMode(resource, mode, agentID, agentName, operation, ttl); err != nil {
+			return err
+		}
+		m.syncHoldings(append(previous, agentID)...)
+		return nil
+	})
+}
+
+func (m *Manager) acquireMode(resource string, mode Mode, agentID, agentName, operation string, ttl int) error {
 	if err := config.EnsureDirs(m.coordDir); err != nil {
 		return fmt.Errorf("failed to create directories: %w", err)
 	}
@@ -48,10 +242,13 @@ func (m *Manager) Acquire(resource, agentID, agentName, operation string, ttl in
 		ttl = m.cfg.Settings.DefaultTTL
 	}
 
-	lockPath := m.lockPath(resource)
+	// Logical resources lock every file group listed under their name
+	var files []string
+	if logical := m.cfg.FindLogical(resource); logical != nil {
+		files = logical.Files
+	}
 
-	lock := Lock{
-		Resource:   resource,
+	holder := Holder{
 		AgentID:    agentID,
 		AgentName:  agentName,
 		Operation:  operation,
@@ -59,59 +256,352 @@ func (m *Manager) Acquire(resource, agentID, agentName, operation string, ttl in
 		TTLSeconds: ttl,
 		PID:        os.Getpid(),
 	}
+	lock := Lock{
+		Resource: resource,
+		Files:    files,
+		Holder:   holder,
+	}
+	if mode == Shared {
+		lock.Mode = Shared
+		lock.setHolders([]Holder{holder})
+	}
 
-	data, err := json.MarshalIndent(lock, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal lock: %w", err)
+	for _, p := range lock.Patterns() {
+		if !doublestar.ValidatePattern(p) {
+			return errorf(CodeInvalidPattern, "invalid resource pattern: %s", p)
+		}
 	}
 
-	// O_EXCL ensures atomic creation - fails if file exists
-	fd, err := syscall.Open(lockPath, syscall.O_CREAT|syscall.O_EXCL|syscall.O_WRONLY, 0644)
-	if err != nil {
-		// Check if existing lock is stale
-		existing, readErr := m.Read(resource)
-		if readErr == nil {
-			if m.IsStale(existing) {
-				// Remove stale lock and retry
-				if removeErr := os.Remove(lockPath); removeErr == nil {
-					return m.Acquire(resource, agentID, agentName, operation, ttl)
-				}
+	// Refuse if another agent holds a pattern matching some of the same files
+	if conflict, err := m.findConflict(&lock); err != nil {
+		return err
+	} else if conflict != nil {
+		return overlapError(resource, conflict)
+	}
+
+	// Every change to the lock file happens under the resource's guard, so
+	// fencing tokens are issued in the order the lock changes hands
+	retry := false
+	err := m.withGuard(resource, func() error {
+		// Waiters queued ahead of us go first
+		if ticket, err := m.queueBlocker(resource, mode, agentID); err != nil {
+			return err
+		} else if ticket != nil {
+			return &LockedError{Resource: resource, Queued: ticket}
+		}
+
+		token, err := m.issueToken(resource)
+		if err != nil {
+			return err
+		}
+		lock.Token = token
+		if lock.IsShared() {
+			lock.setHolders([]Holder{lock.Holder})
+		}
+
+		// Creation is atomic and never overwrites - fails if the lock exists
+		err = m.createLock(&lock)
+		if err == nil {
+			// Locks written by older versions live under the lossy file name
+			if legacy, readErr := m.readLegacy(resource); readErr == nil && !m.IsStale(legacy) {
+				m.deleteLock(encodeResource(resource))
+				return lockedError(resource, legacy, agentID)
+			}
+			m.removeLegacy(resource)
+			return m.removeTickets(resource, agentID)
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to acquire lock: %w", err)
+		}
+
+		// A legacy lock for another resource may occupy this key
+		if squatter, entry, readErr := m.readLock(encodeResource(resource)); readErr == nil && squatter.Resource != resource {
+			if migrateErr := m.migrateEntry(entry, squatter); migrateErr != nil {
+				return fmt.Errorf("failed to move legacy lock for '%s': %w", squatter.Resource, migrateErr)
 			}
-			return fmt.Errorf("resource '%s' is locked by agent '%s' (%s): %s",
-				resource, existing.AgentID, existing.AgentName, existing.Operation)
+			retry = true
+			return nil
+		}
+
+		// Join a shared lock or take over a stale one. Whoever gets the guard
+		// first takes a stale lock over; the rest then find a live lock and
+		// are refused.
+		existing, readErr := m.Read(resource)
+		if os.IsNotExist(readErr) {
+			// Released by a version that doesn't use the guard
+			retry = true
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to acquire lock: %w", readErr)
 		}
-		return fmt.Errorf("failed to acquire lock: %w", err)
+		if err := m.join(existing, &lock, mode); err != nil {
+			return err
+		}
+		return m.removeTickets(resource, agentID)
+	})
+	if err != nil {
+		return err
+	}
+	if retry {
+		return m.acquireMode(resource, mode, agentID, agentName, operation, ttl)
 	}
-	defer syscall.Close(fd)
 
-	if _, err := syscall.Write(fd, data); err != nil {
-		os.Remove(lockPath)
-		return fmt.Errorf("failed to write lock: %w", err)
+	// An overlapping lock may have been created between the check above and
+	// our create. Back off so at most one of two racing agents keeps its lock.
+	if conflict, err := m.findConflict(&lock); err != nil || conflict != nil {
+		m.Release(resource, agentID)
+		if err != nil {
+			return err
+		}
+		return overlapError(resource, conflict)
 	}
 
 	return nil
 }
 
-// Release removes a lock if owned by the given agent
-func (m *Manager) Release(resource, agentID string) error {
-	lockPath := m.lockPath(resource)
+// AcquireAll locks every resource or none of them. Resources are acquired in
+// sorted order so agents requesting overlapping sets can't deadlock, and any
+// locks taken before a failure are released again.
+func (m *Manager) AcquireAll(resources []string, mode Mode, agentID, agentName, operation string, ttl int) error {
+	// With a transactional backend, nobody sees some of the locks taken
+	// before all of them are
+	return m.atomically(func(m *Manager) error {
+		return m.acquireAll(resources, mode, agentID, agentName, operation, ttl)
+	})
+}
 
-	existing, err := m.Read(resource)
+func (m *Manager) acquireAll(resources []string, mode Mode, agentID, agentName, operation string, ttl int) error {
+	ordered := canonicalOrder(resources)
+
+	var acquired []string
+	for _, resource := range ordered {
+		if err := m.AcquireMode(resource, mode, agentID, agentName, operation, ttl); err != nil {
+			// Give back the holds this call took; ones from before survive
+			for i := len(acquired) - 1; i >= 0; i-- {
+				m.Release(acquired[i], agentID)
+			}
+			return err
+		}
+		acquired = append(acquired, resource)
+	}
+
+	return nil
+}
+
+// canonicalOrder returns resources sorted and without duplicates
+func canonicalOrder(resources []string) []string {
+	ordered := append([]string(nil), resources...)
+	sort.Strings(ordered)
+
+	out := ordered[:0]
+	for i, r := range ordered {
+		if i == 0 || r != ordered[i-1] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// join merges a request into the existing lock for the same resource. It must
+// be called under the resource's guard.
+func (m *Manager) join(existing, request *Lock, mode Mode) error {
+	live := m.liveHolders(existing)
+	for _, h := range live {
+		if h.AgentID == request.AgentID {
+			return m.reenter(existing, request, h, len(live), mode)
+		}
+	}
+
+	switch {
+	case len(live) == 0:
+		// Every holder is stale, take the lock over
+		return m.writeLock(request)
+
+	case mode == Shared && existing.IsShared():
+		holders := []Holder{request.Holder}
+		for _, h := range live {
+			if h.AgentID != request.AgentID {
+				holders = append(holders, h)
+			}
+		}
+		existing.setHolders(holders)
+		return m.writeLock(existing)
+	}
+
+	return lockedError(existing.Resource, existing, request.AgentID)
+}
+
+// reenter takes another hold on a lock for its holder held, one of live
+// holders. The lock doesn't change hands, so the hold keeps its fencing
+// token, and it starts a new lease. An exclusive hold covers shared
+// requests too. It must be called under the resource's guard.
+func (m *Manager) reenter(existing, request *Lock, held Holder, live int, mode Mode) error {
+	held.Holds = held.HoldCount() + 1
+	now := time.Now().UTC()
+	held.RenewedAt = &now
+	if request.TTLSeconds > held.TTLSeconds {
+		held.TTLSeconds = request.TTLSeconds
+	}
+
+	switch {
+	case !existing.IsShared() || mode == Shared:
+		existing.putHolder(held)
+		return m.writeLock(existing)
+
+	case live == 1:
+		// Sole shared holder upgrading to exclusive, under the new token
+		request.Holds = held.Holds
+		return m.writeLock(request)
+	}
+
+	return lockedError(existing.Resource, existing, request.AgentID)
+}
+
+// liveHolders returns the holders of lock that haven't gone stale
+func (m *Manager) liveHolders(lock *Lock) []Holder {
+	var live []Holder
+	for _, h := range lock.AllHolders() {
+		if !m.isHolderStale(&h) {
+			live = append(live, h)
+		}
+	}
+	return live
+}
+
+// lockedError reports that holder's lock keeps agentID from locking
+// resource, naming one of its other holders
+func lockedError(resource string, holder *Lock, agentID string) error {
+	conflict := *holder
+	if h := holder.OtherHolder(agentID); h != nil {
+		conflict.Holder = *h
+	}
+	return &LockedError{Resource: resource, Lock: &conflict}
+}
+
+// findConflict returns a lock held by another live agent on a different
+// resource whose patterns overlap the candidate's. Shared locks don't conflict
+// with each other. Same-resource conflicts are handled by Acquire itself.
+// The returned lock's Holder is set to the conflicting agent.
+func (m *Manager) findConflict(candidate *Lock) (*Lock, error) {
+	locks, err := m.List()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // Already unlocked
+		return nil, fmt.Errorf("failed to list locks: %w", err)
+	}
+
+	for _, l := range locks {
+		if l.Resource == candidate.Resource || (candidate.IsShared() && l.IsShared()) {
+			continue
+		}
+
+		var other *Holder
+		for _, h := range m.liveHolders(&l) {
+			if h.AgentID != candidate.AgentID {
+				other = &h
+				break
+			}
+		}
+		if other == nil {
+			continue
+		}
+
+		if patternsOverlap(candidate.Patterns(), l.Patterns()) {
+			l.Holder = *other
+			return &l, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func patternsOverlap(a, b []string) bool {
+	for _, pa := range a {
+		for _, pb := range b {
+			overlaps, err := glob.Overlaps(pa, pb)
+			if err != nil {
+				// Unparseable patterns can't be reasoned about; don't let one
+				// bad lock file block every acquisition
+				continue
+			}
+			if overlaps {
+				return true
+			}
 		}
-		return err
 	}
+	return false
+}
+
+func overlapError(resource string, holder *Lock) error {
+	return &LockedError{Resource: resource, Lock: holder}
+}
+
+// Release gives up one of the given agent's holds on a lock. The agent lets
+// go once it has released the lock as many times as it acquired it. Shared
+// locks are only removed once their last holder lets go.
+func (m *Manager) Release(resource, agentID string) error {
+	return m.releaseHolds(resource, agentID, false)
+}
+
+// ForceRelease lets go of the given agent's lock however many holds it has
+// on it, for cleaning up after nested work that didn't release
+func (m *Manager) ForceRelease(resource, agentID string) error {
+	return m.releaseHolds(resource, agentID, true)
+}
 
-	if existing.AgentID != agentID {
-		return fmt.Errorf("lock owned by different agent: %s", existing.AgentID)
+func (m *Manager) releaseHolds(resource, agentID string, force bool) error {
+	if _, err := m.Read(resource); os.IsNotExist(err) {
+		return nil // Already unlocked
 	}
 
-	return os.Remove(lockPath)
+	return m.atomically(func(m *Manager) error {
+		if err := m.release(resource, agentID, force); err != nil {
+			return err
+		}
+		m.syncHoldings(agentID)
+		return nil
+	})
+}
+
+func (m *Manager) release(resource, agentID string, force bool) error {
+	return m.withGuard(resource, func() error {
+		existing, err := m.Read(resource)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // Already unlocked
+			}
+			return err
+		}
+
+		held := existing.HolderFor(agentID)
+		if held == nil {
+			return errorf(CodeNotOwner, "lock owned by different agent: %s", existing.AgentID)
+		}
+
+		if !force && held.HoldCount() > 1 {
+			held.Holds--
+			existing.putHolder(*held)
+			return m.writeLock(existing)
+		}
+
+		if existing.IsShared() {
+			var remaining []Holder
+			for _, h := range existing.Holders {
+				if h.AgentID != agentID {
+					remaining = append(remaining, h)
+				}
+			}
+			if len(remaining) > 0 {
+				existing.setHolders(remaining)
+				return m.writeLock(existing)
+			}
+		}
+
+		return m.removeLock(resource)
+	})
 }
 
-// ReleaseAll releases all locks held by the given agent
+// ReleaseAll lets go of every lock held by the given agent, however many
+// holds it has on them
 func (m *Manager) ReleaseAll(agentID string) error {
 	locks, err := m.List()
 	if err != nil {
@@ -120,8 +610,8 @@ func (m *Manager) ReleaseAll(agentID string) error {
 
 	var errs []error
 	for _, lock := range locks {
-		if lock.AgentID == agentID {
-			if err := m.Release(lock.Resource, agentID); err != nil {
+		if lock.HeldBy(agentID) {
+			if err := m.ForceRelease(lock.Resource, agentID); err != nil {
 				errs = append(errs, err)
 			}
 		}
@@ -133,145 +623,459 @@ func (m *Manager) ReleaseAll(agentID string) error {
 	return nil
 }
 
-// Read loads a lock from disk
-func (m *Manager) Read(resource string) (*Lock, error) {
-	lockPath := m.lockPath(resource)
-	data, err := os.ReadFile(lockPath)
+// Renew extends the given agent's lease on a lock, starting a new TTL period
+// from now. A ttl of 0 keeps the holder's current TTL.
+func (m *Manager) Renew(resource, agentID string, ttl int) error {
+	if _, err := m.Read(resource); err != nil {
+		if os.IsNotExist(err) {
+			return errorf(CodeNotLocked, "resource '%s' is not locked", resource)
+		}
+		return err
+	}
+
+	return m.atomically(func(m *Manager) error {
+		return m.renew(resource, agentID, ttl)
+	})
+}
+
+func (m *Manager) renew(resource, agentID string, ttl int) error {
+	return m.withGuard(resource, func() error {
+		existing, err := m.Read(resource)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return errorf(CodeNotLocked, "resource '%s' is not locked", resource)
+			}
+			return err
+		}
+
+		if !existing.HeldBy(agentID) {
+			return errorf(CodeNotOwner, "lock owned by different agent: %s", existing.AgentID)
+		}
+
+		renew := func(h *Holder) {
+			now := time.Now().UTC()
+			h.RenewedAt = &now
+			if ttl > 0 {
+				h.TTLSeconds = ttl
+			}
+		}
+
+		if existing.IsShared() {
+			holders := append([]Holder(nil), existing.Holders...)
+			for i := range holders {
+				if holders[i].AgentID == agentID {
+					renew(&holders[i])
+				}
+			}
+			existing.setHolders(holders)
+		} else {
+			renew(&existing.Holder)
+		}
+
+		return m.writeLock(existing)
+	})
+}
+
+// RenewAll extends every lease held by the given agent, keeping their TTLs
+func (m *Manager) RenewAll(agentID string) error {
+	locks, err := m.List()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var lock Lock
-	if err := json.Unmarshal(data, &lock); err != nil {
-		return nil, err
+	var errs []error
+	for _, lock := range locks {
+		if lock.HeldBy(agentID) {
+			if err := m.Renew(lock.Resource, agentID, 0); err != nil {
+				errs = append(errs, err)
+			}
+		}
 	}
 
-	return &lock, nil
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to renew some locks: %v", errs)
+	}
+	return nil
 }
 
-// List returns all current locks
-func (m *Manager) List() ([]Lock, error) {
-	locksDir := filepath.Join(m.coordDir, config.LocksDir)
-	entries, err := os.ReadDir(locksDir)
+// Verify checks that token is a current fencing token for resource: the
+// lock exists and a live holder acquired it with that token. Callers about to
+// act on the resource should refuse to proceed if it returns an error.
+func (m *Manager) Verify(resource string, token uint64) error {
+	existing, err := m.Read(resource)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil
+			return errorf(CodeStale, "token %d is no longer current: resource '%s' is not locked", token, resource)
 		}
-		return nil, err
+		return err
 	}
 
-	var locks []Lock
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lock") {
+	for _, h := range existing.AllHolders() {
+		if h.Token != token {
 			continue
 		}
+		if m.isHolderStale(&h) {
+			return errorf(CodeStale, "token %d is no longer current: lease on '%s' expired", token, resource)
+		}
+		return nil
+	}
+
+	return errorf(CodeStale, "token %d is no longer current for '%s' (current: %d)", token, resource, existing.Token)
+}
+
+// Read loads a lock from the store, falling back to the legacy key
+func (m *Manager) Read(resource string) (*Lock, error) {
+	key := encodeResource(resource)
+	lock, _, err := m.readLock(key)
+	if os.IsNotExist(err) {
+		return m.readLegacy(resource)
+	}
+	if err == nil && lock.Resource != resource {
+		// Legacy lock for a different resource that flattened to this name
+		return nil, &os.PathError{Op: "open", Path: key, Err: os.ErrNotExist}
+	}
+	return lock, err
+}
+
+// readLegacy loads a lock stored under the pre-escaping file name. Several
+// resources share each legacy name, so the stored resource must match.
+func (m *Manager) readLegacy(resource string) (*Lock, error) {
+	key := legacyName(resource)
+	if key == encodeResource(resource) {
+		return nil, &os.PathError{Op: "open", Path: key, Err: os.ErrNotExist}
+	}
+
+	lock, _, err := m.readLock(key)
+	if err != nil {
+		return nil, err
+	}
+	if lock.Resource != resource {
+		return nil, &os.PathError{Op: "open", Path: key, Err: os.ErrNotExist}
+	}
+	return lock, nil
+}
+
+// MigrateLegacy moves locks stored under the old lossy naming scheme to
+// their collision-free keys. Locks whose new key is already taken are left
+// alone. It returns the resources that were migrated.
+func (m *Manager) MigrateLegacy() ([]string, error) {
+	store, err := m.backend()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.List(storage.Locks)
+	if err != nil {
+		return nil, err
+	}
 
-		lockPath := filepath.Join(locksDir, entry.Name())
-		data, err := os.ReadFile(lockPath)
+	var migrated []string
+	for i := range entries {
+		entry := &entries[i]
+		lock, err := decodeLock(entry)
 		if err != nil {
 			continue
 		}
+		if isCanonicalName(entry.Key, lock.Resource) || entry.Key != legacyName(lock.Resource) {
+			continue
+		}
+
+		if err := m.migrateEntry(entry, lock); err == nil {
+			migrated = append(migrated, lock.Resource)
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrateEntry moves a legacy lock to the key encodeResource gives its
+// resource. Create fails if the key is taken, so a live lock is never
+// clobbered, and the legacy entry is only removed if it hasn't changed.
+func (m *Manager) migrateEntry(entry *storage.Entry, lock *Lock) error {
+	store, err := m.backend()
+	if err != nil {
+		return err
+	}
+	if err := store.Create(storage.Locks, encodeResource(lock.Resource), entry.Value); err != nil {
+		return err
+	}
+	return store.CompareAndDelete(storage.Locks, entry.Key, entry.Value)
+}
+
+// List returns all current locks
+func (m *Manager) List() ([]Lock, error) {
+	store, err := m.backend()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.List(storage.Locks)
+	if err != nil {
+		return nil, err
+	}
 
-		var lock Lock
-		if err := json.Unmarshal(data, &lock); err != nil {
+	var locks []Lock
+	for i := range entries {
+		lock, err := decodeLock(&entries[i])
+		if err != nil {
 			continue
 		}
-		locks = append(locks, lock)
+		locks = append(locks, *lock)
 	}
 
 	return locks, nil
 }
 
-// IsStale checks if a lock has expired
+// IsStale checks if a lock has expired, i.e. every holder is stale
 func (m *Manager) IsStale(lock *Lock) bool {
+	return len(m.liveHolders(lock)) == 0
+}
+
+// Lease is a holder's claim on a lock as of when it was checked
+type Lease struct {
+	AgentID          string    `json:"agent_id"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RemainingSeconds int       `json:"remaining_seconds"`
+	Stale            bool      `json:"stale"`
+}
+
+// Leases reports how long each of the lock's holders has left, and which
+// have gone stale
+func (m *Manager) Leases(lock *Lock) []Lease {
+	var leases []Lease
+	for _, h := range lock.AllHolders() {
+		remaining := time.Until(h.ExpiresAt())
+		if remaining < 0 {
+			remaining = 0
+		}
+		leases = append(leases, Lease{
+			AgentID:          h.AgentID,
+			ExpiresAt:        h.ExpiresAt(),
+			RemainingSeconds: int(remaining.Round(time.Second) / time.Second),
+			Stale:            m.isHolderStale(&h),
+		})
+	}
+	return leases
+}
+
+// isHolderStale checks if a single holder's claim has expired
+func (m *Manager) isHolderStale(h *Holder) bool {
 	// Check TTL
-	if time.Since(lock.AcquiredAt) > time.Duration(lock.TTLSeconds)*time.Second {
+	if time.Now().After(h.ExpiresAt()) {
 		return true
 	}
 
 	// Check agent heartbeat
-	heartbeatPath := filepath.Join(m.coordDir, config.AgentsDir, lock.AgentID+".agent")
-	info, err := os.Stat(heartbeatPath)
+	var heartbeat *storage.Entry
+	store, err := m.backend()
+	if err == nil {
+		heartbeat, err = agent.ReadEntry(store, h.AgentID)
+	}
 	if err != nil {
 		// No heartbeat file - check if lock is old enough to be considered stale
-		if time.Since(lock.AcquiredAt) > time.Duration(m.cfg.Settings.StaleThreshold)*time.Second {
+		if time.Since(h.LeaseStart()) > time.Duration(m.cfg.Settings.StaleThreshold)*time.Second {
 			return true
 		}
 		return false
 	}
 
 	// Heartbeat exists but is too old
-	if time.Since(info.ModTime()) > time.Duration(m.cfg.Settings.StaleThreshold)*time.Second {
+	if time.Since(lastHeartbeat(heartbeat)) > time.Duration(m.cfg.Settings.StaleThreshold)*time.Second {
 		return true
 	}
 
 	return false
 }
 
-// CleanStale removes all stale locks
+// lastHeartbeat returns when the agent whose record is entry last sent a
+// heartbeat. Records are also written on behalf of other agents, e.g. when
+// their locks change, so the write time is only used for records that don't
+// say.
+func lastHeartbeat(entry *storage.Entry) time.Time {
+	var a agent.Agent
+	if err := json.Unmarshal(entry.Value, &a); err == nil && !a.LastHeartbeat.IsZero() {
+		return a.LastHeartbeat
+	}
+	return entry.ModTime
+}
+
+// CleanStale removes all stale locks and drops stale holders from shared
+// locks that are still in use. Dropped holders are reported as
+// "resource (agent)".
 func (m *Manager) CleanStale() ([]string, error) {
+	var cleaned []string
+	err := m.atomically(func(m *Manager) error {
+		var err error
+		cleaned, err = m.cleanStale()
+		return err
+	})
+	return cleaned, err
+}
+
+func (m *Manager) cleanStale() ([]string, error) {
 	locks, err := m.List()
 	if err != nil {
 		return nil, err
 	}
 
-	var cleaned []string
+	var cleaned, dropped []string
 	for _, lock := range locks {
-		if m.IsStale(&lock) {
-			lockPath := m.lockPath(lock.Resource)
-			if err := os.Remove(lockPath); err == nil {
-				cleaned = append(cleaned, lock.Resource)
-			}
+		live := m.liveHolders(&lock)
+		if len(live) == len(lock.AllHolders()) {
+			continue
 		}
+
+		m.withGuard(lock.Resource, func() error {
+			current, err := m.Read(lock.Resource)
+			if err != nil {
+				return err
+			}
+
+			live := m.liveHolders(current)
+			if len(live) == 0 {
+				if err := m.removeLock(current.Resource); err == nil {
+					cleaned = append(cleaned, current.Resource)
+					dropped = append(dropped, current.holderIDs()...)
+				}
+				return nil
+			}
+
+			if current.IsShared() && len(live) < len(current.Holders) {
+				for _, h := range current.Holders {
+					if m.isHolderStale(&h) {
+						cleaned = append(cleaned, fmt.Sprintf("%s (%s)", current.Resource, h.AgentID))
+						dropped = append(dropped, h.AgentID)
+					}
+				}
+				current.setHolders(live)
+				return m.writeLock(current)
+			}
+			return nil
+		})
 	}
 
+	if len(dropped) > 0 {
+		m.syncHoldings(dropped...)
+	}
 	return cleaned, nil
 }
 
-// Check returns the lock if the given file matches a protected pattern and is locked
+// Check returns the lock if the given file matches a protected pattern or
+// logical resource and is locked
 func (m *Manager) Check(filePath string) (*Lock, bool, error) {
-	// First check if file matches any protected pattern
-	protected := false
-	var matchedPattern string
-
-	for _, p := range m.cfg.Protected {
-		matched, err := doublestar.Match(p.Pattern, filePath)
-		if err != nil {
-			continue
-		}
-		if matched {
-			protected = true
-			matchedPattern = p.Pattern
-			break
-		}
-	}
-
-	if !protected {
+	if m.protectingResource(filePath) == "" {
 		return nil, false, nil
 	}
 
-	// Check if there's a lock for this pattern
+	// Check if there's a lock covering this file
 	locks, err := m.List()
 	if err != nil {
 		return nil, true, err
 	}
 
+	lock, protected := m.CheckIn(locks, filePath)
+	return lock, protected, nil
+}
+
+// CheckIn is Check against an already loaded list of locks
+func (m *Manager) CheckIn(locks []Lock, filePath string) (*Lock, bool) {
+	resource := m.protectingResource(filePath)
+	if resource == "" {
+		return nil, false
+	}
+
 	for _, lock := range locks {
-		// Check if the lock's resource pattern matches
-		if lock.Resource == matchedPattern {
-			return &lock, true, nil
+		if lock.Resource == resource || lock.Covers(filePath) {
+			return &lock, true
+		}
+	}
+
+	return nil, true
+}
+
+// FileCheck is the outcome of checking a file for an agent
+type FileCheck struct {
+	File      string `json:"file"`
+	Protected bool   `json:"protected"`
+	Resource  string `json:"resource,omitempty"`
+
+	// Blocker is another agent holding a lock on the file
+	Blocker *Holder `json:"blocker,omitempty"`
+
+	// Acquired is set when the agent holds the file's lock after the check,
+	// with Token its fencing token
+	Acquired bool   `json:"acquired,omitempty"`
+	Token    uint64 `json:"token,omitempty"`
+
+	// Error says why the lock couldn't be acquired when no single holder
+	// is to blame, e.g. an overlapping lock
+	Error string `json:"error,omitempty"`
+}
+
+// IsBlocked reports whether the agent may not modify the file
+func (c *FileCheck) IsBlocked() bool {
+	return c.Blocker != nil || c.Error != ""
+}
+
+// CheckFile checks whether filePath is protected and locked by an agent
+// other than agentID. With acquire, an unlocked protected file is locked for
+// agentID, as CheckOrAcquire does. Failures other than ErrLocked are
+// returned rather than reported in the check.
+func (m *Manager) CheckFile(filePath string, acquire bool, agentID, agentName, operation string) (*FileCheck, error) {
+	if !acquire {
+		if m.protectingResource(filePath) == "" {
+			return &FileCheck{File: filePath}, nil
 		}
-		// Also check if lock resource matches the file
-		matched, _ := doublestar.Match(lock.Resource, filePath)
-		if matched {
-			return &lock, true, nil
+		locks, err := m.List()
+		if err != nil {
+			return nil, err
 		}
+		return m.CheckFileIn(locks, filePath, agentID), nil
 	}
 
-	return nil, true, nil
+	check := &FileCheck{File: filePath}
+	existing, err := m.CheckOrAcquire(filePath, agentID, agentName, operation)
+	switch {
+	case err != nil && !errors.Is(err, ErrLocked):
+		return nil, err
+	case err != nil:
+		check.Protected = true
+		if existing == nil {
+			check.Error = err.Error()
+			break
+		}
+		check.Resource = existing.Resource
+		check.Blocker = existing.OtherHolder(agentID)
+		if check.Blocker == nil {
+			check.Blocker = &existing.Holder
+		}
+	case existing != nil:
+		check.Protected = true
+		check.Resource = existing.Resource
+		if h := existing.HolderFor(agentID); h != nil {
+			check.Acquired = true
+			check.Token = h.Token
+		}
+	}
+	return check, nil
+}
+
+// CheckFileIn is CheckFile without acquiring, against an already loaded list
+// of locks
+func (m *Manager) CheckFileIn(locks []Lock, filePath, agentID string) *FileCheck {
+	check := &FileCheck{File: filePath}
+	existing, protected := m.CheckIn(locks, filePath)
+	check.Protected = protected
+	if existing != nil {
+		check.Resource = existing.Resource
+		check.Blocker = existing.OtherHolder(agentID)
+		if h := existing.HolderFor(agentID); h != nil {
+			check.Token = h.Token
+		}
+	}
+	return check
 }
 
-// CheckOrAcquire checks if a file is protected and locked, and acquires if not
+// CheckOrAcquire checks if a file is protected and locked, and acquires if
+// not. A lock the agent already holds is left as it is, without another hold.
 func (m *Manager) CheckOrAcquire(filePath, agentID, agentName, operation string) (*Lock, error) {
 	lock, protected, err := m.Check(filePath)
 	if err != nil {
@@ -283,22 +1087,25 @@ func (m *Manager) CheckOrAcquire(filePath, agentID, agentName, operation string)
 	}
 
 	if lock != nil {
-		if lock.AgentID == agentID {
+		if other := lock.OtherHolder(agentID); other != nil {
+			return lock, lockedError(lock.Resource, lock, agentID)
+		}
+		if !lock.IsShared() {
 			return lock, nil // We already have the lock
 		}
-		return lock, fmt.Errorf("resource locked by %s: %s", lock.AgentID, lock.Operation)
-	}
 
-	// Find the matching pattern to use as resource
-	var resource string
-	for _, p := range m.cfg.Protected {
-		matched, _ := doublestar.Match(p.Pattern, filePath)
-		if matched {
-			resource = p.Pattern
-			break
+		// Editing needs exclusive access; upgrade our shared lock, then give
+		// back the extra hold the upgrade took
+		if err := m.Acquire(lock.Resource, agentID, agentName, operation, 0); err != nil {
+			return lock, err
 		}
+		if err := m.Release(lock.Resource, agentID); err != nil {
+			return nil, err
+		}
+		return m.Read(lock.Resource)
 	}
 
+	resource := m.protectingResource(filePath)
 	if err := m.Acquire(resource, agentID, agentName, operation, 0); err != nil {
 		return nil, err
 	}
@@ -306,11 +1113,74 @@ func (m *Manager) CheckOrAcquire(filePath, agentID, agentName, operation string)
 	return m.Read(resource)
 }
 
-func (m *Manager) lockPath(resource string) string {
-	// Convert resource pattern to safe filename
-	safe := strings.ReplaceAll(resource, "/", "-")
-	safe = strings.ReplaceAll(safe, "\\", "-")
-	safe = strings.ReplaceAll(safe, "*", "_")
-	safe = strings.ReplaceAll(safe, "?", "_")
-	return filepath.Join(m.coordDir, config.LocksDir, safe+".lock")
+// protectingResource returns the resource that guards filePath: the first
+// matching protected pattern, else the first logical resource listing it.
+// It returns "" for unprotected files.
+func (m *Manager) protectingResource(filePath string) string {
+	for _, p := range m.cfg.Protected {
+		if matched, _ := doublestar.Match(p.Pattern, filePath); matched {
+			return p.Pattern
+		}
+	}
+
+	for _, lr := range m.cfg.Logical {
+		for _, f := range lr.Files {
+			if matched, _ := doublestar.Match(f, filePath); matched {
+				return lr.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// removeLock deletes the lock for resource, wherever it is stored
+func (m *Manager) removeLock(resource string) error {
+	err := m.deleteLock(encodeResource(resource))
+	if os.IsNotExist(err) {
+		if _, legacyErr := m.readLegacy(resource); legacyErr == nil {
+			return m.deleteLock(legacyName(resource))
+		}
+	}
+	return err
+}
+
+// removeLegacy deletes resource's lock under its legacy key, if there is one
+func (m *Manager) removeLegacy(resource string) {
+	if _, err := m.readLegacy(resource); err == nil {
+		m.deleteLock(legacyName(resource))
+	}
+}
+
+func (m *Manager) deleteLock(key string) error {
+	store, err := m.backend()
+	if err != nil {
+		return err
+	}
+	return store.Delete(storage.Locks, key)
+}
+
+// readLock loads the lock stored under key, along with the raw entry
+func (m *Manager) readLock(key string) (*Lock, *storage.Entry, error) {
+	store, err := m.backend()
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, err := store.Get(storage.Locks, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	lock, err := decodeLock(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lock, entry, nil
+}
+
+func decodeLock(entry *storage.Entry) (*Lock, error) {
+	var lock Lock
+	if err := json.Unmarshal(entry.Value, &lock); err != nil {
+		return nil, err
+	}
+	return &lock, nil
 }
@@ -1,11 +1,20 @@
 package lock
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
 	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
 )
 
 func TestAcquireRelease(t *testing.T) {
@@ -65,8 +74,71 @@ func TestReleaseWrongAgent(t *testing.T) {
 
 	// Try to release as agent-2
 	err = mgr.Release("test-resource", "agent-2")
-	if err == nil {
-		t.Fatal("Expected error when wrong agent tries to release")
+	if !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("Expected ErrNotOwner when wrong agent tries to release, got %v", err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+	writeHeartbeat(t, coordDir, "agent-1")
+
+	if err := mgr.Acquire("db/**/*", "agent-1", "Backend", "migration", 300); err != nil {
+		t.Fatal(err)
+	}
+
+	// The holder comes with the error, for the same resource or an
+	// overlapping one
+	for _, resource := range []string{"db/**/*", "db/schema/*"} {
+		err := mgr.Acquire(resource, "agent-2", "", "", 300)
+		var locked *LockedError
+		if !errors.As(err, &locked) || !errors.Is(err, ErrLocked) {
+			t.Fatalf("Expected a LockedError for %s, got %v", resource, err)
+		}
+		if locked.Resource != resource || locked.Lock == nil || locked.Lock.Resource != "db/**/*" ||
+			locked.Lock.AgentID != "agent-1" || locked.Lock.Operation != "migration" {
+			t.Errorf("Expected agent-1's lock on db/**/* as the holder, got %+v", locked)
+		}
+		if ErrorCode(err) != CodeLocked {
+			t.Errorf("Expected code %q, got %q", CodeLocked, ErrorCode(err))
+		}
+	}
+
+	// Unknown names and operations are left out of the message
+	err := mgr.Acquire("db/**/*", "agent-3", "", "", 300)
+	if want := "resource 'db/**/*' is locked by agent 'agent-1' (Backend): migration"; err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+	noName := &LockedError{Resource: "x", Lock: &Lock{Resource: "x", Mode: Shared, Holder: Holder{AgentID: "r1", Operation: "read"}}}
+	if want := "resource 'x' is shared by agent 'r1': read"; noName.Error() != want {
+		t.Errorf("Expected %q, got %q", want, noName.Error())
+	}
+
+	if err := mgr.Renew("go.mod", "agent-1", 0); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("Expected ErrNotLocked renewing an unlocked resource, got %v", err)
+	}
+	if err := mgr.Renew("db/**/*", "agent-2", 0); !errors.Is(err, ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner renewing another agent's lock, got %v", err)
+	}
+	if err := mgr.Verify("db/**/*", 12345); !errors.Is(err, ErrStale) {
+		t.Errorf("Expected ErrStale for a wrong token, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = mgr.Wait(ctx, "db/**/*", "agent-2", WaitOptions{Interval: 20 * time.Millisecond})
+	if !errors.Is(err, ErrTimeout) || ErrorCode(err) != CodeTimeout {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	if errors.Is(err, ErrLocked) {
+		t.Errorf("A timeout should only match ErrTimeout: %v", err)
+	}
+	if ErrorCode(errors.New("disk full")) != "" {
+		t.Error("Other errors should have no code")
 	}
 }
 
@@ -129,3 +201,1219 @@ func TestReleaseAll(t *testing.T) {
 		t.Fatalf("Wrong lock remaining")
 	}
 }
+
+func TestLockPathNoCollisions(t *testing.T) {
+	mgr := NewManager(t.TempDir(), config.DefaultConfig())
+
+	pairs := [][2]string{
+		{"db/schema/*", "db-schema-?"},
+		{"db/schema/*", "db-schema-_"},
+		{"a/b", "a-b"},
+		{"a\\b", "a/b"},
+		{"a*b", "a?b"},
+		{"a%2Fb", "a/b"},
+		{"src/Auth", "src/auth"},
+		{"README.md", "readme.md"},
+		{"a%2fb", "a%2Fb"},
+	}
+
+	// Names must differ on case-insensitive filesystems too
+	for _, p := range pairs {
+		if strings.EqualFold(mgr.lockPath(p[0]), mgr.lockPath(p[1])) {
+			t.Errorf("resources %q and %q share lock file %s", p[0], p[1], mgr.lockPath(p[0]))
+		}
+	}
+
+	long := strings.Repeat("src/components/", 30) + "*"
+	name := filepath.Base(mgr.lockPath(long))
+	if len(name) > 255 {
+		t.Errorf("lock file name too long: %d bytes", len(name))
+	}
+	if mgr.lockPath(long) == mgr.lockPath(long+"*") {
+		t.Error("hashed names collide")
+	}
+}
+
+func TestDistinctResourcesDoNotBlock(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	if err := mgr.Acquire("a/b", "agent-1", "", "", 300); err != nil {
+		t.Fatalf("Failed to acquire a/b: %v", err)
+	}
+	if err := mgr.Acquire("a-b", "agent-2", "", "", 300); err != nil {
+		t.Fatalf("a-b should not be blocked by a/b: %v", err)
+	}
+
+	if err := mgr.Release("a-b", "agent-2"); err != nil {
+		t.Fatalf("Failed to release a-b: %v", err)
+	}
+	if _, err := mgr.Read("a/b"); err != nil {
+		t.Fatalf("Releasing a-b removed a/b: %v", err)
+	}
+}
+
+func TestLegacyLockFiles(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+	config.EnsureDirs(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	// Write a lock the way older versions did
+	legacy := Lock{
+		Resource: "db/schema/*",
+		Holder: Holder{
+			AgentID:    "agent-1",
+			AcquiredAt: time.Now().UTC(),
+			TTLSeconds: 300,
+		},
+	}
+	data, _ := json.Marshal(legacy)
+	if err := os.WriteFile(mgr.legacyLockPath("db/schema/*"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeHeartbeat(t, coordDir, "agent-1")
+
+	if _, err := mgr.Read("db/schema/*"); err != nil {
+		t.Fatalf("Failed to read legacy lock: %v", err)
+	}
+	if _, err := mgr.Read("db-schema-_"); err == nil {
+		t.Fatal("Legacy lock should only match its own resource")
+	}
+	if err := mgr.Acquire("db/schema/*", "agent-2", "", "", 300); err == nil {
+		t.Fatal("Expected legacy lock to block acquire")
+	}
+
+	migrated, err := mgr.MigrateLegacy()
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if len(migrated) != 1 || migrated[0] != "db/schema/*" {
+		t.Fatalf("Unexpected migrated resources: %v", migrated)
+	}
+	if _, err := os.Stat(mgr.legacyLockPath("db/schema/*")); !os.IsNotExist(err) {
+		t.Fatal("Legacy lock file should be gone after migration")
+	}
+
+	if err := mgr.Release("db/schema/*", "agent-1"); err != nil {
+		t.Fatalf("Failed to release migrated lock: %v", err)
+	}
+	locks, _ := mgr.List()
+	if len(locks) != 0 {
+		t.Fatalf("Expected no locks, got %d", len(locks))
+	}
+}
+
+// lockPath is the file the file backend keeps resource's lock in
+func (m *Manager) lockPath(resource string) string {
+	return storage.NewFileStore(m.coordDir).Path(storage.Locks, encodeResource(resource))
+}
+
+// legacyLockPath is the file older versions kept resource's lock in
+func (m *Manager) legacyLockPath(resource string) string {
+	return storage.NewFileStore(m.coordDir).Path(storage.Locks, legacyName(resource))
+}
+
+// writeHeartbeat creates a fresh agent file so locks held by id aren't stale
+func writeHeartbeat(t *testing.T, coordDir, id string) {
+	t.Helper()
+	if err := config.EnsureDirs(coordDir); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(coordDir, config.AgentsDir, id+".agent")
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLegacyLockOccupyingNewName(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+	config.EnsureDirs(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	// The legacy name of db/schema/* is exactly the new name of db-schema-_
+	legacy := Lock{
+		Resource: "db/schema/*",
+		Holder:   Holder{AgentID: "agent-1", AcquiredAt: time.Now().UTC(), TTLSeconds: 300},
+	}
+	data, _ := json.Marshal(legacy)
+	os.WriteFile(mgr.legacyLockPath("db/schema/*"), data, 0644)
+	writeHeartbeat(t, coordDir, "agent-1")
+
+	if err := mgr.Acquire("db-schema-_", "agent-2", "", "", 300); err != nil {
+		t.Fatalf("Legacy lock for another resource blocked acquire: %v", err)
+	}
+	if l, err := mgr.Read("db/schema/*"); err != nil || l.AgentID != "agent-1" {
+		t.Fatalf("Legacy lock was lost: %v", err)
+	}
+}
+
+func TestAcquireOverlappingPatterns(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+	writeHeartbeat(t, coordDir, "agent-1")
+
+	if err := mgr.Acquire("db/**/*", "agent-1", "", "rewriting db", 300); err != nil {
+		t.Fatalf("Failed to acquire db/**/*: %v", err)
+	}
+
+	for _, resource := range []string{"db/schema/*", "db/schema/users.sql", "**/*.sql"} {
+		err := mgr.Acquire(resource, "agent-2", "", "", 300)
+		if err == nil {
+			t.Errorf("Expected %s to be refused while db/**/* is held", resource)
+			continue
+		}
+		if !strings.Contains(err.Error(), "db/**/*") {
+			t.Errorf("Error should name the conflicting lock: %v", err)
+		}
+	}
+
+	// Disjoint patterns are fine
+	if err := mgr.Acquire("migrations/*", "agent-2", "", "", 300); err != nil {
+		t.Fatalf("Disjoint pattern refused: %v", err)
+	}
+
+	// The holder may take narrower locks inside its own pattern
+	if err := mgr.Acquire("db/schema/*", "agent-1", "", "", 300); err != nil {
+		t.Fatalf("Owner refused overlapping lock: %v", err)
+	}
+}
+
+func TestAcquireInvalidPattern(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+	if err := mgr.Acquire("db/[schema", "agent-1", "", "", 300); !errors.Is(err, ErrInvalidPattern) {
+		t.Fatalf("Expected ErrInvalidPattern for malformed pattern, got %v", err)
+	}
+}
+
+func TestLogicalResources(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Logical = []config.LogicalResource{
+		{Name: "auth-system", Files: []string{"src/auth/**/*", "src/middleware/auth*", "prisma/schema.prisma"}},
+		{Name: "api-routes", Files: []string{"src/routes/**/*"}},
+	}
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	if err := mgr.Acquire("auth-system", "agent-1", "", "Adding OAuth", 300); err != nil {
+		t.Fatalf("Failed to lock logical resource: %v", err)
+	}
+
+	// Files inside the group are blocked for other agents
+	for _, f := range []string{"src/auth/oauth/google.ts", "src/middleware/auth.ts", "prisma/schema.prisma"} {
+		lock, protected, err := mgr.Check(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !protected || lock == nil || lock.Resource != "auth-system" {
+			t.Errorf("Expected %s to be locked by auth-system, got protected=%v lock=%v", f, protected, lock)
+		}
+		if _, err := mgr.CheckOrAcquire(f, "agent-2", "", ""); err == nil {
+			t.Errorf("Expected CheckOrAcquire of %s to be blocked", f)
+		}
+	}
+
+	// Overlapping patterns are refused too
+	if err := mgr.Acquire("src/auth/*", "agent-2", "", "", 300); err == nil {
+		t.Error("Expected src/auth/* to conflict with auth-system")
+	}
+
+	// Files outside the group are unaffected
+	if _, protected, _ := mgr.Check("src/ui/button.ts"); protected {
+		t.Error("src/ui/button.ts should not be protected")
+	}
+
+	// A file only covered by an unlocked logical resource acquires it
+	lock, err := mgr.CheckOrAcquire("src/routes/users.ts", "agent-2", "", "")
+	if err != nil {
+		t.Fatalf("Failed to acquire api-routes: %v", err)
+	}
+	if lock == nil || lock.Resource != "api-routes" {
+		t.Fatalf("Expected api-routes lock, got %v", lock)
+	}
+}
+
+func TestSharedLocks(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	// Several readers can share the schema
+	for _, id := range []string{"agent-1", "agent-2", "agent-3"} {
+		if err := mgr.AcquireMode("db/schema/*", Shared, id, "", "reading", 300); err != nil {
+			t.Fatalf("%s failed to take shared lock: %v", id, err)
+		}
+	}
+
+	l, err := mgr.Read("db/schema/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.IsShared() || len(l.AllHolders()) != 3 {
+		t.Fatalf("Expected 3 shared holders, got %+v", l.AllHolders())
+	}
+
+	// Writers are refused while readers remain, on the same or overlapping resources
+	if err := mgr.Acquire("db/schema/*", "agent-4", "", "writing", 300); err == nil {
+		t.Fatal("Expected exclusive lock to be refused by shared holders")
+	}
+	if err := mgr.Acquire("db/**/*", "agent-4", "", "writing", 300); err == nil {
+		t.Fatal("Expected overlapping exclusive lock to be refused by shared holders")
+	}
+
+	// Overlapping shared locks are fine
+	if err := mgr.AcquireMode("db/**/*", Shared, "agent-4", "", "reading", 300); err != nil {
+		t.Fatalf("Overlapping shared lock refused: %v", err)
+	}
+	mgr.Release("db/**/*", "agent-4")
+
+	// Releasing one holder keeps the lock for the rest
+	if err := mgr.Release("db/schema/*", "agent-1"); err != nil {
+		t.Fatalf("Failed to release shared hold: %v", err)
+	}
+	l, err = mgr.Read("db/schema/*")
+	if err != nil {
+		t.Fatalf("Lock vanished after one holder released: %v", err)
+	}
+	if l.HeldBy("agent-1") || len(l.AllHolders()) != 2 {
+		t.Fatalf("Unexpected holders after release: %+v", l.AllHolders())
+	}
+	if l.AgentID != l.Holders[0].AgentID {
+		t.Fatal("Top-level holder should mirror the first shared holder")
+	}
+
+	// A non-holder can't release
+	if err := mgr.Release("db/schema/*", "agent-1"); err == nil {
+		t.Fatal("Expected error releasing a lock we no longer hold")
+	}
+
+	if err := mgr.ReleaseAll("agent-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The last holder can upgrade to exclusive
+	if err := mgr.Acquire("db/schema/*", "agent-3", "", "writing", 300); err != nil {
+		t.Fatalf("Sole shared holder failed to upgrade: %v", err)
+	}
+	l, _ = mgr.Read("db/schema/*")
+	if l.IsShared() || l.AgentID != "agent-3" {
+		t.Fatalf("Expected exclusive lock for agent-3, got %+v", l)
+	}
+
+	// And now shared requests are refused
+	if err := mgr.AcquireMode("db/schema/*", Shared, "agent-1", "", "reading", 300); err == nil {
+		t.Fatal("Expected shared lock to be refused by exclusive holder")
+	}
+}
+
+func TestCleanStaleSharedHolders(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	mgr.AcquireMode("db/schema/*", Shared, "agent-1", "", "", 300)
+	mgr.AcquireMode("db/schema/*", Shared, "agent-2", "", "", 1)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	l, _ := mgr.Read("db/schema/*")
+	if mgr.IsStale(l) {
+		t.Fatal("Lock with a live holder should not be stale")
+	}
+	leases := make(map[string]Lease)
+	for _, lease := range mgr.Leases(l) {
+		leases[lease.AgentID] = lease
+	}
+	if live := leases["agent-1"]; live.Stale || live.RemainingSeconds < 290 {
+		t.Errorf("Expected agent-1's lease live, got %+v", live)
+	}
+	if stale := leases["agent-2"]; !stale.Stale || stale.RemainingSeconds != 0 {
+		t.Errorf("Expected agent-2's lease stale, got %+v", stale)
+	}
+
+	cleaned, err := mgr.CleanStale()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cleaned) != 1 || cleaned[0] != "db/schema/* (agent-2)" {
+		t.Fatalf("Unexpected cleaned entries: %v", cleaned)
+	}
+
+	l, err = mgr.Read("db/schema/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.HeldBy("agent-2") || !l.HeldBy("agent-1") {
+		t.Fatalf("Unexpected holders after clean: %+v", l.AllHolders())
+	}
+}
+
+func TestAcquireAll(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	resources := []string{"prisma/schema.prisma", "prisma/migrations/**/*", "package.json"}
+	if err := mgr.AcquireAll(resources, Exclusive, "agent-1", "", "migrating", 300); err != nil {
+		t.Fatalf("Failed to acquire all: %v", err)
+	}
+	locks, _ := mgr.List()
+	if len(locks) != 3 {
+		t.Fatalf("Expected 3 locks, got %d", len(locks))
+	}
+	mgr.ReleaseAll("agent-1")
+
+	// agent-2 holds the last resource in canonical order, so agent-1 gets
+	// the first two before failing and must roll them back
+	if err := mgr.Acquire("prisma/schema.prisma", "agent-2", "", "", 300); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.AcquireAll(resources, Exclusive, "agent-1", "", "migrating", 300); err == nil {
+		t.Fatal("Expected AcquireAll to fail")
+	}
+
+	locks, _ = mgr.List()
+	if len(locks) != 1 || locks[0].AgentID != "agent-2" {
+		t.Fatalf("Partial acquisition was not rolled back: %+v", locks)
+	}
+}
+
+func TestAcquireAllKeepsPriorHolds(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	mgr.AcquireMode("a", Shared, "agent-1", "", "", 300)
+	mgr.Acquire("c", "agent-2", "", "", 300)
+
+	if err := mgr.AcquireAll([]string{"c", "a", "b", "a"}, Shared, "agent-1", "", "", 300); err == nil {
+		t.Fatal("Expected AcquireAll to fail on c")
+	}
+
+	if l, err := mgr.Read("a"); err != nil || !l.HeldBy("agent-1") {
+		t.Fatal("Rollback released a lock held before the call")
+	} else if holds := l.HolderFor("agent-1").HoldCount(); holds != 1 {
+		t.Fatalf("Rollback left %d holds on a, want 1", holds)
+	}
+	if _, err := mgr.Read("b"); !os.IsNotExist(err) {
+		t.Fatal("Rollback left b locked")
+	}
+}
+
+func TestReentrantLocks(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Protected = []config.ProtectedPath{{Pattern: "db/**/*"}}
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	// A nested step re-acquires its agent's lock instead of being refused
+	if err := mgr.Acquire("db/**/*", "agent-1", "", "migrate", 300); err != nil {
+		t.Fatal(err)
+	}
+	outer, _ := mgr.Read("db/**/*")
+	if err := mgr.Acquire("db/**/*", "agent-1", "", "codegen", 600); err != nil {
+		t.Fatalf("Re-acquiring our own lock failed: %v", err)
+	}
+	if err := mgr.AcquireMode("db/**/*", Shared, "agent-1", "", "", 0); err != nil {
+		t.Fatalf("Exclusive holder refused a shared hold: %v", err)
+	}
+	l, _ := mgr.Read("db/**/*")
+	if l.HoldCount() != 3 || l.Token != outer.Token || l.Operation != "migrate" || l.TTLSeconds != 600 || l.IsShared() {
+		t.Fatalf("Expected 3 exclusive holds keeping the token, got %+v", l)
+	}
+
+	// Checks from hooks don't take holds of their own
+	if _, err := mgr.CheckOrAcquire("db/users.sql", "agent-1", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each release gives back one hold
+	for want := 2; want > 0; want-- {
+		if err := mgr.Release("db/**/*", "agent-1"); err != nil {
+			t.Fatal(err)
+		}
+		if l, err := mgr.Read("db/**/*"); err != nil || l.HoldCount() != want {
+			t.Fatalf("Expected %d holds left, got %+v %v", want, l, err)
+		}
+		if err := mgr.Acquire("db/**/*", "agent-2", "", "", 300); !errors.Is(err, ErrLocked) {
+			t.Fatalf("Expected the lock kept while held, got %v", err)
+		}
+	}
+	mgr.Release("db/**/*", "agent-1")
+	if _, err := mgr.Read("db/**/*"); !os.IsNotExist(err) {
+		t.Fatal("Expected the last release to unlock")
+	}
+
+	// Shared holders count their holds separately
+	mgr.AcquireMode("db/**/*", Shared, "agent-1", "", "", 300)
+	mgr.AcquireMode("db/**/*", Shared, "agent-1", "", "", 300)
+	mgr.AcquireMode("db/**/*", Shared, "agent-2", "", "", 300)
+	mgr.Release("db/**/*", "agent-1")
+	if l, _ := mgr.Read("db/**/*"); !l.HeldBy("agent-1") || l.HolderFor("agent-2").HoldCount() != 1 {
+		t.Fatalf("Expected both shared holders left, got %+v", l.AllHolders())
+	}
+
+	// Upgrading to exclusive is a hold too, and a hook's upgrade isn't
+	mgr.ForceRelease("db/**/*", "agent-2")
+	if err := mgr.Acquire("db/**/*", "agent-1", "", "", 300); err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+	if l, _ := mgr.Read("db/**/*"); l.IsShared() || l.HoldCount() != 2 {
+		t.Fatalf("Expected 2 exclusive holds after upgrading, got %+v", l)
+	}
+	mgr.ReleaseAll("agent-1")
+	mgr.AcquireMode("db/**/*", Shared, "agent-1", "", "", 300)
+	if l, err := mgr.CheckOrAcquire("db/users.sql", "agent-1", "", ""); err != nil || l.IsShared() || l.HoldCount() != 1 {
+		t.Fatalf("Expected the hook to upgrade without a hold, got %+v %v", l, err)
+	}
+
+	// ForceRelease drops every hold, and only the holder's
+	mgr.Acquire("db/**/*", "agent-1", "", "", 300)
+	if err := mgr.ForceRelease("db/**/*", "agent-2"); !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("Expected ErrNotOwner, got %v", err)
+	}
+	if err := mgr.ForceRelease("db/**/*", "agent-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Read("db/**/*"); !os.IsNotExist(err) {
+		t.Fatal("Expected ForceRelease to unlock")
+	}
+}
+
+func TestAgentHoldings(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+	agents := agent.NewManager(coordDir, cfg)
+	agents.Register("agent-1", "")
+	agents.Register("agent-2", "")
+
+	held := func(id string) []string {
+		t.Helper()
+		a, err := agents.Read(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.LocksHeld
+	}
+	expect := func(id string, want ...string) {
+		t.Helper()
+		if got := held(id); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %s to hold %v, got %v", id, want, got)
+		}
+	}
+
+	// Every change to who holds a lock updates the holders' records
+	mgr.AcquireAll([]string{"b", "a"}, Exclusive, "agent-1", "", "migrate", 300)
+	mgr.AcquireMode("c", Shared, "agent-1", "", "", 300)
+	mgr.AcquireMode("c", Shared, "agent-2", "", "", 300)
+	expect("agent-1", "a", "b", "c")
+	expect("agent-2", "c")
+
+	// Agents that haven't set a task show one made from their locks
+	if a, _ := agents.Read("agent-1"); a.CurrentTask != "migrate (a, b); holding c" || !a.TaskAuto {
+		t.Errorf("Expected a task made from agent-1's locks, got %q", a.CurrentTask)
+	}
+	agents.UpdateTask("agent-2", "Reading schema")
+
+	mgr.Release("a", "agent-1")
+	expect("agent-1", "b", "c")
+	mgr.ReleaseAll("agent-1")
+	expect("agent-1")
+	expect("agent-2", "c")
+	if a, _ := agents.Read("agent-1"); a.CurrentTask != "" {
+		t.Errorf("Expected agent-1's task gone with its locks, got %q", a.CurrentTask)
+	}
+
+	// Taking over a stale lock takes it off the old holder's record
+	mgr.Acquire("d", "agent-2", "", "", 1)
+	expect("agent-2", "c", "d")
+	time.Sleep(1100 * time.Millisecond)
+	before, _ := agents.Read("agent-2")
+	if err := mgr.Acquire("d", "agent-1", "", "", 300); err != nil {
+		t.Fatal(err)
+	}
+	expect("agent-1", "d")
+	expect("agent-2", "c")
+	// ...without counting as a heartbeat from it
+	if a, _ := agents.Read("agent-2"); !a.LastHeartbeat.Equal(before.LastHeartbeat) {
+		t.Errorf("Expected agent-2's heartbeat kept at %v, got %v", before.LastHeartbeat, a.LastHeartbeat)
+	}
+
+	// Hooks' checks count too
+	cfg.Protected = []config.ProtectedPath{{Pattern: "db/**/*"}}
+	if _, err := mgr.CheckOrAcquire("db/users.sql", "agent-2", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	expect("agent-2", "c", "db/**/*")
+	if a, _ := agents.Read("agent-2"); a.CurrentTask != "Reading schema" {
+		t.Errorf("Expected agent-2's own task kept, got %q", a.CurrentTask)
+	}
+
+	// Records that can't be updated don't fail the lock change
+	agentPath := filepath.Join(coordDir, config.AgentsDir, "agent-1.agent")
+	good, _ := os.ReadFile(agentPath)
+	os.WriteFile(agentPath, []byte("{"), 0644)
+	if err := mgr.Acquire("e", "agent-1", "", "", 300); err != nil {
+		t.Errorf("Expected the lock taken despite agent-1's unreadable record, got %v", err)
+	}
+	mgr.Release("e", "agent-1")
+	os.WriteFile(agentPath, good, 0644)
+
+	// SyncAgents repairs records that drifted
+	agents.UpdateLocks("agent-1", []string{"gone"})
+	synced, err := mgr.SyncAgents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(synced) != 1 || synced[0] != "agent-1 (d)" {
+		t.Errorf("Expected agent-1 repaired, got %v", synced)
+	}
+	expect("agent-1", "d")
+	if synced, _ := mgr.SyncAgents(); len(synced) != 0 {
+		t.Errorf("Expected nothing left to repair, got %v", synced)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	if err := mgr.Acquire("db/schema/*", "agent-1", "", "long migration", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	if err := mgr.Renew("db/schema/*", "agent-1", 0); err != nil {
+		t.Fatalf("Failed to renew: %v", err)
+	}
+	time.Sleep(600 * time.Millisecond)
+
+	// Past the original TTL, but within the renewed lease
+	l, _ := mgr.Read("db/schema/*")
+	if mgr.IsStale(l) {
+		t.Fatal("Renewed lock went stale at its original expiry")
+	}
+	if err := mgr.Acquire("db/schema/*", "agent-2", "", "", 300); err == nil {
+		t.Fatal("Renewed lock was taken over")
+	}
+
+	if err := mgr.Renew("db/schema/*", "agent-1", 600); err != nil {
+		t.Fatal(err)
+	}
+	l, _ = mgr.Read("db/schema/*")
+	if l.TTLSeconds != 600 || l.RenewedAt == nil || !l.AcquiredAt.Before(*l.RenewedAt) {
+		t.Fatalf("Unexpected lease after renewal: %+v", l.Holder)
+	}
+
+	if err := mgr.Renew("db/schema/*", "agent-2", 0); err == nil {
+		t.Fatal("Expected error renewing another agent's lock")
+	}
+	if err := mgr.Renew("not-locked", "agent-1", 0); err == nil {
+		t.Fatal("Expected error renewing an unlocked resource")
+	}
+}
+
+func TestRenewAllShared(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	mgr.AcquireMode("db/schema/*", Shared, "agent-1", "", "", 300)
+	mgr.AcquireMode("db/schema/*", Shared, "agent-2", "", "", 300)
+	mgr.Acquire("package.json", "agent-1", "", "", 300)
+
+	if err := mgr.RenewAll("agent-1"); err != nil {
+		t.Fatalf("Failed to renew all: %v", err)
+	}
+
+	l, _ := mgr.Read("db/schema/*")
+	for _, h := range l.AllHolders() {
+		renewed := h.RenewedAt != nil
+		if renewed != (h.AgentID == "agent-1") {
+			t.Errorf("Holder %s renewed=%v", h.AgentID, renewed)
+		}
+	}
+	l, _ = mgr.Read("package.json")
+	if l.RenewedAt == nil {
+		t.Error("package.json was not renewed")
+	}
+}
+
+func TestFencingTokens(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	mgr.Acquire("db/schema/*", "agent-1", "", "", 1)
+	first, _ := mgr.Read("db/schema/*")
+	if first.Token == 0 {
+		t.Fatal("Expected a fencing token")
+	}
+	if err := mgr.Verify("db/schema/*", first.Token); err != nil {
+		t.Fatalf("Fresh token should verify: %v", err)
+	}
+
+	// agent-1 stalls past its lease and agent-2 takes over
+	time.Sleep(1100 * time.Millisecond)
+	if err := mgr.Verify("db/schema/*", first.Token); !errors.Is(err, ErrStale) {
+		t.Fatalf("Expired lease should not verify, got %v", err)
+	}
+	if err := mgr.Acquire("db/schema/*", "agent-2", "", "", 300); err != nil {
+		t.Fatalf("Failed to take over stale lock: %v", err)
+	}
+	second, _ := mgr.Read("db/schema/*")
+	if second.Token <= first.Token {
+		t.Fatalf("Token did not increase: %d -> %d", first.Token, second.Token)
+	}
+	if err := mgr.Verify("db/schema/*", first.Token); err == nil {
+		t.Fatal("Superseded token should not verify")
+	}
+
+	// Tokens keep increasing across release and re-acquire
+	mgr.Release("db/schema/*", "agent-2")
+	if err := mgr.Verify("db/schema/*", second.Token); err == nil {
+		t.Fatal("Token for a released lock should not verify")
+	}
+	mgr.AcquireMode("db/schema/*", Shared, "agent-3", "", "", 300)
+	mgr.AcquireMode("db/schema/*", Shared, "agent-4", "", "", 300)
+	third, _ := mgr.Read("db/schema/*")
+	h3, h4 := third.HolderFor("agent-3"), third.HolderFor("agent-4")
+	if h3.Token <= second.Token || h4.Token <= h3.Token {
+		t.Fatalf("Shared tokens not increasing: %d, %d after %d", h3.Token, h4.Token, second.Token)
+	}
+	if err := mgr.Verify("db/schema/*", h3.Token); err != nil {
+		t.Fatalf("Shared holder token should verify: %v", err)
+	}
+
+	// Tokens are per resource
+	mgr.Acquire("package.json", "agent-1", "", "", 300)
+	other, _ := mgr.Read("package.json")
+	if other.Token != 1 {
+		t.Fatalf("Expected first token for a new resource to be 1, got %d", other.Token)
+	}
+}
+
+func TestWaitQueue(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	if err := mgr.Acquire("db/schema/*", "agent-1", "", "migration", 300); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"agent-2", "agent-3"} {
+		if _, err := mgr.Enqueue("db/schema/*", Exclusive, id, "", "next"); err != nil {
+			t.Fatalf("Failed to enqueue %s: %v", id, err)
+		}
+	}
+
+	// Re-enqueueing keeps the agent's place
+	ticket, err := mgr.Enqueue("db/schema/*", Exclusive, "agent-2", "", "next")
+	if err != nil || ticket.Seq != 1 {
+		t.Fatalf("Expected agent-2 to keep ticket 1, got %+v (%v)", ticket, err)
+	}
+	if pos, _ := mgr.Position("db/schema/*", "agent-3"); pos != 1 {
+		t.Errorf("Expected agent-3 at position 1, got %d", pos)
+	}
+
+	if holder, _, _ := mgr.Blocker("db/schema/*", Exclusive, "agent-2"); holder == nil || holder.AgentID != "agent-1" {
+		t.Errorf("Expected agent-2 to be blocked by agent-1, got %+v", holder)
+	}
+
+	mgr.Release("db/schema/*", "agent-1")
+
+	// Agents behind the head of the queue, or not queued at all, must wait
+	for _, id := range []string{"agent-3", "agent-4"} {
+		if err := mgr.Acquire("db/schema/*", id, "", "", 300); err == nil {
+			t.Fatalf("%s jumped the queue", id)
+		}
+	}
+	if _, ahead, _ := mgr.Blocker("db/schema/*", Exclusive, "agent-3"); ahead == nil || ahead.AgentID != "agent-2" {
+		t.Errorf("Expected agent-3 to be queued behind agent-2, got %+v", ahead)
+	}
+
+	if err := mgr.Acquire("db/schema/*", "agent-2", "", "", 300); err != nil {
+		t.Fatalf("Head of queue failed to acquire: %v", err)
+	}
+	if pos, _ := mgr.Position("db/schema/*", "agent-2"); pos != -1 {
+		t.Error("Ticket should be removed once the lock is granted")
+	}
+	mgr.Release("db/schema/*", "agent-2")
+
+	if err := mgr.Acquire("db/schema/*", "agent-3", "", "", 300); err != nil {
+		t.Fatalf("Next in queue failed to acquire: %v", err)
+	}
+	if queues, _ := mgr.Queues(); len(queues) != 0 {
+		t.Errorf("Expected empty queues, got %v", queues)
+	}
+}
+
+func TestWaitQueueShared(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	mgr.Enqueue("db/schema/*", Shared, "reader-1", "", "")
+	mgr.Enqueue("db/schema/*", Exclusive, "writer", "", "")
+
+	// Readers may pass readers queued ahead of them, but not a writer
+	if err := mgr.AcquireMode("db/schema/*", Shared, "reader-2", "", "", 300); err == nil {
+		t.Fatal("Reader jumped ahead of a queued writer")
+	}
+	if err := mgr.AcquireMode("db/schema/*", Shared, "reader-1", "", "", 300); err != nil {
+		t.Fatalf("Queued reader failed to acquire: %v", err)
+	}
+	if err := mgr.AcquireMode("db/schema/*", Exclusive, "writer", "", "", 300); err == nil {
+		t.Fatal("Writer acquired while a reader holds the lock")
+	}
+	mgr.Release("db/schema/*", "reader-1")
+	if err := mgr.AcquireMode("db/schema/*", Exclusive, "writer", "", "", 300); err != nil {
+		t.Fatalf("Writer failed to acquire: %v", err)
+	}
+}
+
+func TestAbandonedTickets(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	ticket, err := mgr.Enqueue("db/schema/*", Exclusive, "gone", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A waiter that stopped polling no longer holds its place
+	old := time.Now().Add(-time.Duration(cfg.Settings.StaleThreshold+1) * time.Second)
+	os.Chtimes(mgr.ticketPath(ticket), old, old)
+
+	if err := mgr.Acquire("db/schema/*", "agent-1", "", "", 300); err != nil {
+		t.Fatalf("Abandoned ticket blocked acquire: %v", err)
+	}
+
+	cleaned, err := mgr.CleanQueues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cleaned) != 1 || cleaned[0] != "db/schema/* (gone)" {
+		t.Errorf("Expected abandoned ticket to be cleaned, got %v", cleaned)
+	}
+}
+
+func TestDeadlockDetection(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	mgr.Acquire("db/schema/*", "agent-1", "", "", 300)
+	mgr.Acquire("src/api/*", "agent-2", "", "", 300)
+
+	// agent-1 waits for agent-2: no cycle yet
+	mgr.Enqueue("src/api/*", Exclusive, "agent-1", "", "")
+	if err := mgr.CheckDeadlock("agent-1"); err != nil {
+		t.Fatalf("Unexpected deadlock: %v", err)
+	}
+
+	// agent-2 waits for agent-1, closing the loop
+	time.Sleep(10 * time.Millisecond)
+	mgr.Watch("db/schema/*", Exclusive, "agent-2", "", "")
+	err := mgr.CheckDeadlock("agent-2")
+	if !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("Expected deadlock to be detected, got %v", err)
+	}
+	for _, want := range []string{"agent-1 waits for agent-2", "agent-2 waits for agent-1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Error %q doesn't name %q", err, want)
+		}
+	}
+
+	// gc cancels the most recent wait
+	broken, err := mgr.BreakDeadlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broken) != 1 || broken[0] != "db/schema/* (agent-2)" {
+		t.Fatalf("Expected agent-2's wait to be cancelled, got %v", broken)
+	}
+	ticket, _ := mgr.Ticket("db/schema/*", "agent-2")
+	if ticket == nil || !strings.Contains(ticket.Aborted, "deadlock detected") {
+		t.Errorf("Expected cancelled ticket to say why, got %+v", ticket)
+	}
+	if deadlocks, _ := mgr.Deadlocks(); len(deadlocks) != 0 {
+		t.Errorf("Deadlock survived gc: %v", deadlocks)
+	}
+}
+
+func TestDeadlockThroughQueue(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	// agent-3 is queued ahead of agent-1 for a lock agent-2 holds, and
+	// waits on agent-1 for another resource
+	mgr.Acquire("db/schema/*", "agent-1", "", "", 300)
+	mgr.Acquire("src/api/*", "agent-2", "", "", 300)
+	mgr.Enqueue("src/api/*", Exclusive, "agent-3", "", "")
+	mgr.Enqueue("src/api/*", Exclusive, "agent-1", "", "")
+	mgr.Enqueue("db/schema/*", Exclusive, "agent-3", "", "")
+
+	err := mgr.CheckDeadlock("agent-3")
+	if err == nil || !strings.Contains(err.Error(), "agent-1 waits for agent-3 on 'src/api/*'") {
+		t.Fatalf("Expected queue-order deadlock, got %v", err)
+	}
+	if err := mgr.CheckDeadlock("agent-2"); err != nil {
+		t.Errorf("agent-2 isn't waiting, got %v", err)
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	edges := []WaitEdge{
+		{Waiter: "a", Holder: "b", Resource: "x"},
+		{Waiter: "b", Holder: "c", Resource: "y"},
+		{Waiter: "c", Holder: "a", Resource: "z"},
+		{Waiter: "d", Holder: "a", Resource: "x"},
+	}
+	cycles := findCycles(edges)
+	if len(cycles) != 1 || len(cycles[0]) != 3 || cycles[0].Involves("d") {
+		t.Fatalf("Expected one 3-agent cycle, got %v", cycles)
+	}
+	if got := cycles[0].String(); got != "a waits for b on 'x' → b waits for c on 'y' → c waits for a on 'z'" {
+		t.Errorf("Unexpected description: %s", got)
+	}
+
+	if cycles := findCycles(edges[:2]); len(cycles) != 0 {
+		t.Errorf("Expected no cycles, got %v", cycles)
+	}
+
+	// Two cycles through a and b. Once a → b → a is found, b is done, so
+	// walking the graph once from a never finds the cycle through c.
+	shared := []WaitEdge{
+		{Waiter: "a", Holder: "b", Resource: "x"},
+		{Waiter: "b", Holder: "a", Resource: "y"},
+		{Waiter: "a", Holder: "c", Resource: "z"},
+		{Waiter: "c", Holder: "b", Resource: "x"},
+	}
+	if cycles := findCycles(shared); len(cycles) != 1 {
+		t.Errorf("Expected the agents to be reported as one group, got %v", cycles)
+	}
+	cycle := shortestCycle(waitsFor(shared), "c", nil)
+	if got := cycle.String(); got != "c waits for b on 'x' → b waits for a on 'y' → a waits for c on 'z'" {
+		t.Errorf("Expected c's cycle to be found, got %q", got)
+	}
+	if cycle := shortestCycle(waitsFor(shared), "d", nil); cycle != nil {
+		t.Errorf("d isn't waiting, got %v", cycle)
+	}
+}
+
+func TestWatchResource(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	if err := mgr.Acquire("db/schema/*", "agent-1", "", "", 300); err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := mgr.Enqueue("db/schema/*", Exclusive, "agent-2", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := mgr.WatchResource("db/schema/*")
+	if err != nil {
+		t.Skipf("file notifications unavailable: %v", err)
+	}
+	defer watcher.Close()
+
+	// Touching our own ticket must not wake us
+	mgr.Touch(ticket)
+	select {
+	case <-watcher.C:
+		t.Fatal("Woken by touching own ticket")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	mgr.Release("db/schema/*", "agent-1")
+	select {
+	case <-watcher.C:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Not woken when the lock was released")
+	}
+}
+
+func TestWait(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	if err := mgr.Acquire("db/schema/*", "agent-1", "", "migration", 300); err != nil {
+		t.Fatal(err)
+	}
+
+	// Times out while the lock is held
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := mgr.Wait(ctx, "db/schema/*", "agent-2", WaitOptions{Acquire: true})
+	if err == nil || !strings.Contains(err.Error(), "locked by agent-1 (migration)") {
+		t.Fatalf("Expected timeout naming the holder, got %v", err)
+	}
+	if pos, _ := mgr.Position("db/schema/*", "agent-2"); pos != -1 {
+		t.Error("Timed out waiter should leave the queue")
+	}
+
+	// Takes the lock once it's released
+	var statuses []WaitStatus
+	done := make(chan error)
+	go func() {
+		done <- mgr.Wait(context.Background(), "db/schema/*", "agent-2", WaitOptions{
+			Acquire:  true,
+			Interval: 50 * time.Millisecond,
+			Progress: func(s WaitStatus) { statuses = append(statuses, s) },
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	mgr.Release("db/schema/*", "agent-1")
+
+	if err := <-done; err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if l, _ := mgr.Read("db/schema/*"); l == nil || l.AgentID != "agent-2" {
+		t.Error("Expected agent-2 to hold the lock")
+	}
+	if len(statuses) != 1 || statuses[0].Holder == nil || statuses[0].Position != 0 {
+		t.Errorf("Expected one progress report while locked, got %+v", statuses)
+	}
+
+	// Errors waiting won't fix are returned straight away
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := mgr.Wait(ctx, "db/[x", "agent-3", WaitOptions{Acquire: true}); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern, got %v", err)
+	}
+}
+
+func TestAcquireContext(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+	writeHeartbeat(t, coordDir, "agent-1")
+
+	// Free resources are granted straight away
+	if err := mgr.AcquireContext(context.Background(), []string{"db/**/*", "go.mod"}, Exclusive, "agent-1", "", "migration", 300); err != nil {
+		t.Fatalf("AcquireContext failed: %v", err)
+	}
+
+	// Times out while any of them is held, leaving nothing behind
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := mgr.AcquireContext(ctx, []string{"api/*", "db/schema/*"}, Exclusive, "agent-2", "", "", 300)
+	if !errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), "agent-1") {
+		t.Fatalf("Expected a timeout naming the holder, got %v", err)
+	}
+	if l, _ := mgr.Read("api/*"); l != nil {
+		t.Error("Timed out acquire should hold nothing")
+	}
+	if queues, _ := mgr.Queues(); len(queues) != 0 {
+		t.Errorf("Timed out acquire should leave no waits behind, got %+v", queues)
+	}
+
+	// Other errors aren't retried
+	if err := mgr.AcquireContext(context.Background(), []string{"db/[x"}, Exclusive, "agent-2", "", "", 300); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern, got %v", err)
+	}
+
+	// Granted soon after the holder lets go, and the wait shows in status
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- mgr.AcquireContext(context.Background(), []string{"db/schema/*"}, Exclusive, "agent-2", "", "codegen", 300)
+	}()
+	time.Sleep(300 * time.Millisecond)
+	if queue, _ := mgr.Queue("db/schema/*"); len(queue) != 1 || queue[0].AgentID != "agent-2" || !queue[0].Watch {
+		t.Errorf("Expected agent-2's wait registered, got %+v", queue)
+	}
+	mgr.Release("db/**/*", "agent-1")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("AcquireContext failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("AcquireContext didn't return after release")
+	}
+	if waited := time.Since(start); waited > 2*time.Second {
+		t.Errorf("Took %s to notice the release", waited)
+	}
+	if l, _ := mgr.Read("db/schema/*"); l == nil || l.AgentID != "agent-2" {
+		t.Error("Expected agent-2 to hold the lock")
+	}
+
+	// Cancelling gives up
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := mgr.AcquireContext(ctx, []string{"db/schema/*"}, Exclusive, "agent-3", "", "", 300); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSQLiteBackend(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Settings.Backend = storage.BackendSQLite
+	cfg.Save(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+	if _, err := mgr.backend(); err != nil {
+		t.Skipf("sqlite backend unavailable: %v", err)
+	}
+
+	if err := mgr.Acquire("db/**/*", "agent-1", "", "migration", 300); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := mgr.Acquire("db/schema/*", "agent-2", "", "", 300); err == nil {
+		t.Fatal("Overlapping lock should be refused")
+	}
+	if _, err := os.Stat(mgr.lockPath("db/**/*")); !os.IsNotExist(err) {
+		t.Error("The sqlite backend shouldn't write lock files")
+	}
+
+	// A failed multi-resource lock leaves nothing behind
+	if err := mgr.AcquireAll([]string{"go.mod", "db/schema/*"}, Exclusive, "agent-2", "", "", 300); err == nil {
+		t.Fatal("AcquireAll should fail on the overlapping resource")
+	}
+	if _, err := mgr.Read("go.mod"); !os.IsNotExist(err) {
+		t.Errorf("AcquireAll left go.mod locked: %v", err)
+	}
+
+	if err := mgr.Release("db/**/*", "agent-1"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := mgr.Acquire("db/schema/*", "agent-2", "", "", 300); err != nil {
+		t.Fatalf("Acquire after release failed: %v", err)
+	}
+	l, err := mgr.Read("db/schema/*")
+	if err != nil || l.AgentID != "agent-2" || l.Token == 0 {
+		t.Fatalf("Unexpected lock %+v: %v", l, err)
+	}
+
+	// Fencing tokens keep increasing across releases
+	mgr.Release("db/schema/*", "agent-2")
+	mgr.Acquire("db/schema/*", "agent-3", "", "", 300)
+	if next, _ := mgr.Read("db/schema/*"); next == nil || next.Token <= l.Token {
+		t.Errorf("Expected a token above %d, got %+v", l.Token, next)
+	}
+
+	history, err := mgr.store.(storage.Historian).History(storage.Locks, encodeResource("db/schema/*"))
+	if err != nil || len(history) < 3 {
+		t.Errorf("Expected the lock's history to be kept, got %d changes: %v", len(history), err)
+	}
+}
+
+func TestRedisBackend(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	// Two checkouts on different hosts sharing one server
+	newHost := func() *Manager {
+		coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+		cfg := config.DefaultConfig()
+		cfg.Settings.Backend = storage.BackendRedis
+		cfg.Settings.RedisURL = "redis://" + server.Addr()
+		cfg.Save(coordDir)
+		return NewManager(coordDir, cfg)
+	}
+	hostA, hostB := newHost(), newHost()
+
+	if err := hostA.Acquire("db/**/*", "agent-1", "", "migration", 60); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := hostB.Acquire("db/schema/*", "agent-2", "", "", 60); err == nil {
+		t.Fatal("A lock taken on another host should be refused")
+	}
+	if _, err := os.Stat(hostA.lockPath("db/**/*")); !os.IsNotExist(err) {
+		t.Error("The redis backend shouldn't write lock files")
+	}
+
+	// Locks expire with their lease, even if nobody cleans up
+	if ttl := server.TTL("claude-coord:locks:" + encodeResource("db/**/*")); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Expected the lock to expire within its 60s lease, got %v", ttl)
+	}
+	server.FastForward(61 * time.Second)
+	if err := hostB.Acquire("db/schema/*", "agent-2", "", "", 60); err != nil {
+		t.Fatalf("Acquire after the lease ran out failed: %v", err)
+	}
+
+	// Renewing extends the key's lifetime too
+	server.FastForward(30 * time.Second)
+	if err := hostB.Renew("db/schema/*", "agent-2", 60); err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+	if ttl := server.TTL("claude-coord:locks:" + encodeResource("db/schema/*")); ttl < 50*time.Second {
+		t.Errorf("Expected renewal to reset the TTL, got %v", ttl)
+	}
+
+	if err := hostA.Release("db/schema/*", "agent-2"); err != nil {
+		t.Fatalf("Release from another host failed: %v", err)
+	}
+	if _, err := hostB.Read("db/schema/*"); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock to be gone, got %v", err)
+	}
+
+	// Queues would only order waiters on one host, so they're refused
+	if _, err := hostA.Enqueue("db/schema/*", Exclusive, "agent-1", "", ""); err == nil {
+		t.Error("Expected queueing to be refused with the redis backend")
+	}
+	if err := hostA.AcquireContext(context.Background(), []string{"db/schema/*"}, Exclusive, "agent-1", "", "", 60); err != nil {
+		t.Errorf("AcquireContext failed: %v", err)
+	}
+}
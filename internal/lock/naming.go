@@ -0,0 +1,30 @@
+package lock
+
+import (
+	"strings"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
+)
+
+// encodeResource converts a resource pattern into the key its lock, fence
+// and queue are stored under. See storage.EncodeKey; the original resource
+// is stored inside the lock.
+func encodeResource(resource string) string {
+	return storage.EncodeKey(resource)
+}
+
+// isCanonicalName reports whether name (without the ".lock" suffix) is the
+// file name encodeResource produces for resource.
+func isCanonicalName(name, resource string) bool {
+	return name == encodeResource(resource)
+}
+
+// legacyName is the lossy flattening used by earlier releases. It is only
+// used to find lock files written before the switch to encodeResource.
+func legacyName(resource string) string {
+	safe := strings.ReplaceAll(resource, "/", "-")
+	safe = strings.ReplaceAll(safe, "\\", "-")
+	safe = strings.ReplaceAll(safe, "*", "_")
+	safe = strings.ReplaceAll(safe, "?", "_")
+	return safe
+}
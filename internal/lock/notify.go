@@ -0,0 +1,137 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
+)
+
+// ChangeWatcher wakes waiters when locks or a resource's wait queue change,
+// so they don't have to poll. Lock changes come from the store; wait queues
+// are watched with inotify on Linux and the platform's equivalent elsewhere.
+type ChangeWatcher struct {
+	// C receives a value after something changed. Changes that happen before
+	// the last one was received are coalesced.
+	C <-chan struct{}
+
+	locks   *storage.Watcher
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// WatchLocks starts watching for changes to any lock
+func (m *Manager) WatchLocks() (*ChangeWatcher, error) {
+	store, err := m.backend()
+	if err != nil {
+		return nil, err
+	}
+	locks, err := store.Watch(storage.Locks)
+	if err != nil {
+		return nil, err
+	}
+	return newChangeWatcher(locks, nil, nil), nil
+}
+
+// WatchResource starts watching the locks and the wait queue of resource.
+// Callers should keep polling as well: stale locks expire without anything
+// changing, and notifications may be unavailable.
+func (m *Manager) WatchResource(resource string) (*ChangeWatcher, error) {
+	if err := config.EnsureDirs(m.coordDir); err != nil {
+		return nil, err
+	}
+
+	store, err := m.backend()
+	if err != nil {
+		return nil, err
+	}
+	locks, err := store.Watch(storage.Locks)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		locks.Close()
+		return nil, err
+	}
+
+	dirs := []string{filepath.Join(m.coordDir, config.QueueDir)}
+	// The queue directory only exists while someone is waiting
+	if _, err := os.Stat(m.queueDir(resource)); err == nil {
+		dirs = append(dirs, m.queueDir(resource))
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			locks.Close()
+			return nil, err
+		}
+	}
+
+	return newChangeWatcher(locks, watcher, func(event fsnotify.Event) {
+		// Start watching the resource's queue once it is created
+		if event.Has(fsnotify.Create) && event.Name == m.queueDir(resource) {
+			watcher.Add(event.Name)
+		}
+	}), nil
+}
+
+func newChangeWatcher(locks *storage.Watcher, watcher *fsnotify.Watcher, onQueue func(fsnotify.Event)) *ChangeWatcher {
+	ch := make(chan struct{}, 1)
+	w := &ChangeWatcher{C: ch, locks: locks, watcher: watcher, done: make(chan struct{})}
+	go w.run(ch, onQueue)
+	return w
+}
+
+// run forwards notifications to ch until the watcher is closed. onQueue sees
+// each queue event first.
+func (w *ChangeWatcher) run(ch chan struct{}, onQueue func(fsnotify.Event)) {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if w.watcher != nil {
+		events, errs = w.watcher.Events, w.watcher.Errors
+	}
+
+	notify := func() {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+
+	for {
+		select {
+		case <-w.locks.C:
+			notify()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			// Waiters touch their tickets on every check; reacting to
+			// that would wake them in a loop
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			onQueue(event)
+			notify()
+		case _, ok := <-errs:
+			if !ok {
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Close stops watching
+func (w *ChangeWatcher) Close() error {
+	close(w.done)
+	if w.watcher != nil {
+		w.watcher.Close()
+	}
+	return w.locks.Close()
+}
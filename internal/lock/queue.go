@@ -0,0 +1,415 @@
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/storage"
+)
+
+// Ticket is an agent's place in the wait queue for a resource
+type Ticket struct {
+	Resource   string    `json:"resource"`
+	Seq        uint64    `json:"seq"`
+	Mode       Mode      `json:"mode,omitempty"`
+	AgentID    string    `json:"agent_id"`
+	AgentName  string    `json:"agent_name,omitempty"`
+	Operation  string    `json:"operation,omitempty"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	PID        int       `json:"pid"`
+
+	// Watch tickets belong to agents that wait for a resource without
+	// queueing to take it. They don't hold a place in line.
+	Watch bool `json:"watch,omitempty"`
+
+	// Aborted is set when the wait was cancelled on the waiter's behalf,
+	// e.g. to break a deadlock, and says why
+	Aborted string `json:"aborted,omitempty"`
+
+	// LastSeen is the ticket file's modification time, refreshed by Touch
+	LastSeen time.Time `json:"-"`
+}
+
+// Enqueue registers agentID as waiting for resource and returns its ticket.
+// An agent already in the queue keeps its place. It fails with the redis
+// backend, as the queue isn't shared between hosts.
+func (m *Manager) Enqueue(resource string, mode Mode, agentID, agentName, operation string) (*Ticket, error) {
+	return m.enqueue(resource, mode, agentID, agentName, operation, false)
+}
+
+// Watch registers agentID as waiting for resource to become available
+// without queueing for it, so the wait shows up in status and deadlock
+// detection
+func (m *Manager) Watch(resource string, mode Mode, agentID, agentName, operation string) (*Ticket, error) {
+	return m.enqueue(resource, mode, agentID, agentName, operation, true)
+}
+
+func (m *Manager) enqueue(resource string, mode Mode, agentID, agentName, operation string, watch bool) (*Ticket, error) {
+	// Tickets live in the coord dir, so with a store shared between hosts
+	// the queue would only order waiters on this one
+	if !watch && m.cfg.Settings.Backend == storage.BackendRedis {
+		return nil, fmt.Errorf("queueing isn't supported with the %s backend; use lock --wait instead", storage.BackendRedis)
+	}
+	if err := config.EnsureDirs(m.coordDir); err != nil {
+		return nil, fmt.Errorf("failed to create directories: %w", err)
+	}
+	if err := os.MkdirAll(m.queueDir(resource), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue: %w", err)
+	}
+
+	var ticket *Ticket
+	err := m.withGuard(resource, func() error {
+		tickets, err := m.readTickets(resource)
+		if err != nil {
+			return err
+		}
+
+		var last uint64
+		for i := range tickets {
+			if tickets[i].Seq > last {
+				last = tickets[i].Seq
+			}
+			if tickets[i].AgentID != agentID {
+				continue
+			}
+			if tickets[i].Watch == watch && tickets[i].Aborted == "" {
+				ticket = &tickets[i]
+				return m.Touch(ticket)
+			}
+			// Replace a cancelled ticket or one of the other kind
+			if err := os.Remove(m.ticketPath(&tickets[i])); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		ticket = &Ticket{
+			Resource:   resource,
+			Seq:        last + 1,
+			Mode:       mode,
+			AgentID:    agentID,
+			AgentName:  agentName,
+			Operation:  operation,
+			EnqueuedAt: time.Now().UTC(),
+			PID:        os.Getpid(),
+			Watch:      watch,
+		}
+		return m.writeTicket(ticket)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ticket.LastSeen = time.Now()
+	return ticket, nil
+}
+
+// Dequeue removes agentID from the wait queue for resource
+func (m *Manager) Dequeue(resource, agentID string) error {
+	if _, err := os.Stat(m.queueDir(resource)); os.IsNotExist(err) {
+		return nil
+	}
+	return m.withGuard(resource, func() error {
+		return m.removeTickets(resource, agentID)
+	})
+}
+
+// Touch marks a ticket as still wanted. Waiters call it on every poll so
+// tickets of waiters that went away can be told apart and skipped.
+func (m *Manager) Touch(ticket *Ticket) error {
+	now := time.Now()
+	if err := os.Chtimes(m.ticketPath(ticket), now, now); err != nil {
+		return err
+	}
+	ticket.LastSeen = now
+	return nil
+}
+
+// Ticket returns agentID's ticket for resource, including a cancelled one,
+// or nil if it has none
+func (m *Manager) Ticket(resource, agentID string) (*Ticket, error) {
+	tickets, err := m.readTickets(resource)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tickets {
+		if tickets[i].AgentID == agentID {
+			return &tickets[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Abort cancels agentID's wait for resource. The waiter finds out on its
+// next poll and gives up with the given reason.
+func (m *Manager) Abort(resource, agentID, reason string) error {
+	return m.withGuard(resource, func() error {
+		tickets, err := m.readTickets(resource)
+		if err != nil {
+			return err
+		}
+		for i := range tickets {
+			if tickets[i].AgentID == agentID && tickets[i].Aborted == "" {
+				tickets[i].Aborted = reason
+				if err := m.writeTicket(&tickets[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Queue returns the live tickets waiting for resource, in queue order
+func (m *Manager) Queue(resource string) ([]Ticket, error) {
+	tickets, err := m.readTickets(resource)
+	if err != nil {
+		return nil, err
+	}
+	return m.liveTickets(tickets), nil
+}
+
+// Queues returns the live wait queues of every resource that has one
+func (m *Manager) Queues() (map[string][]Ticket, error) {
+	entries, err := os.ReadDir(filepath.Join(m.coordDir, config.QueueDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	queues := make(map[string][]Ticket)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		tickets, err := m.readTicketDir(filepath.Join(m.coordDir, config.QueueDir, entry.Name()))
+		if err != nil || len(tickets) == 0 {
+			continue
+		}
+
+		if live := m.liveTickets(tickets); len(live) > 0 {
+			queues[live[0].Resource] = live
+		}
+	}
+	return queues, nil
+}
+
+// Position returns agentID's 0-based place in the live queue for resource,
+// or -1 if it isn't queued
+func (m *Manager) Position(resource, agentID string) (int, error) {
+	tickets, err := m.Queue(resource)
+	if err != nil {
+		return -1, err
+	}
+	pos := 0
+	for _, t := range tickets {
+		if t.Watch {
+			continue
+		}
+		if t.AgentID == agentID {
+			return pos, nil
+		}
+		pos++
+	}
+	return -1, nil
+}
+
+// Blocker returns what currently stops agentID from acquiring resource in
+// mode: a lock held by another live agent, or a queued ticket that goes
+// first. Both are nil when the resource is available to it.
+func (m *Manager) Blocker(resource string, mode Mode, agentID string) (*Lock, *Ticket, error) {
+	existing, err := m.Read(resource)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	if err == nil && !(mode == Shared && existing.IsShared()) {
+		for _, h := range m.liveHolders(existing) {
+			if h.AgentID != agentID {
+				existing.Holder = h
+				return existing, nil, nil
+			}
+		}
+	}
+
+	ticket, err := m.queueBlocker(resource, mode, agentID)
+	return nil, ticket, err
+}
+
+// CleanQueues removes tickets left behind by waiters that went away. Cleaned
+// tickets are reported as "resource (agent)".
+func (m *Manager) CleanQueues() ([]string, error) {
+	queueRoot := filepath.Join(m.coordDir, config.QueueDir)
+	entries, err := os.ReadDir(queueRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var cleaned []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(queueRoot, entry.Name())
+		tickets, err := m.readTicketDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, t := range tickets {
+			if m.isTicketStale(&t) {
+				if err := os.Remove(m.ticketPath(&t)); err == nil {
+					cleaned = append(cleaned, fmt.Sprintf("%s (%s)", t.Resource, t.AgentID))
+				}
+			}
+		}
+		// Fails harmlessly while tickets remain
+		os.Remove(dir)
+	}
+	return cleaned, nil
+}
+
+// queueBlocker returns the first live ticket that must be served before
+// agentID may take resource in mode, or nil. Exclusive requests must be at
+// the head of the queue. Shared requests may also go ahead of their turn if
+// only shared requests are queued before them, since they'd all be granted
+// together. An agent without a ticket counts as queued at the end, and an
+// agent already holding the resource never waits on the queue.
+func (m *Manager) queueBlocker(resource string, mode Mode, agentID string) (*Ticket, error) {
+	if existing, err := m.Read(resource); err == nil && existing.HeldBy(agentID) {
+		return nil, nil
+	}
+
+	tickets, err := m.Queue(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tickets {
+		t := &tickets[i]
+		if t.Watch {
+			continue
+		}
+		if t.AgentID == agentID {
+			return nil, nil
+		}
+		if mode != Shared || t.Mode != Shared {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+// liveTickets filters out tickets of waiters that went away or gave up
+func (m *Manager) liveTickets(tickets []Ticket) []Ticket {
+	var live []Ticket
+	for _, t := range tickets {
+		if t.Aborted == "" && !m.isTicketStale(&t) {
+			live = append(live, t)
+		}
+	}
+	return live
+}
+
+func (m *Manager) isTicketStale(t *Ticket) bool {
+	return time.Since(t.LastSeen) > time.Duration(m.cfg.Settings.StaleThreshold)*time.Second
+}
+
+// removeTickets deletes agentID's tickets for resource. It must be called
+// under the resource's guard.
+func (m *Manager) removeTickets(resource, agentID string) error {
+	tickets, err := m.readTickets(resource)
+	if err != nil {
+		return err
+	}
+	for _, t := range tickets {
+		if t.AgentID == agentID {
+			if err := os.Remove(m.ticketPath(&t)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	// Fails harmlessly while other tickets remain
+	os.Remove(m.queueDir(resource))
+	return nil
+}
+
+// readTickets returns every ticket for resource, stale or not, in queue order
+func (m *Manager) readTickets(resource string) ([]Ticket, error) {
+	return m.readTicketDir(m.queueDir(resource))
+}
+
+func (m *Manager) readTicketDir(dir string) ([]Ticket, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var tickets []Ticket
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".ticket") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var t Ticket
+		if err := json.Unmarshal(data, &t); err != nil {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			t.LastSeen = info.ModTime()
+		}
+		tickets = append(tickets, t)
+	}
+
+	sort.Slice(tickets, func(i, j int) bool {
+		return tickets[i].Seq < tickets[j].Seq
+	})
+	return tickets, nil
+}
+
+// writeTicket replaces the ticket's file, keeping its modification time so
+// rewriting a ticket doesn't revive an abandoned one
+func (m *Manager) writeTicket(t *Ticket) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := m.ticketPath(t)
+	tmp := fmt.Sprintf("%s.tmp-%d", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if !t.LastSeen.IsZero() {
+		os.Chtimes(tmp, t.LastSeen, t.LastSeen)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (m *Manager) queueDir(resource string) string {
+	return filepath.Join(m.coordDir, config.QueueDir, encodeResource(resource))
+}
+
+func (m *Manager) ticketPath(t *Ticket) string {
+	return filepath.Join(m.queueDir(t.Resource), fmt.Sprintf("%020d.ticket", t.Seq))
+}
@@ -0,0 +1,128 @@
+package lock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+)
+
+const (
+	stressDirEnv   = "CLAUDE_COORD_STRESS_DIR"
+	stressAgentEnv = "CLAUDE_COORD_STRESS_AGENT"
+	stressStartEnv = "CLAUDE_COORD_STRESS_START"
+	stressResource = "db/schema/*"
+)
+
+// TestStaleTakeoverStress plants a stale lock and lets several processes race
+// to take it over at the same instant. Exactly one of them may win each round.
+func TestStaleTakeoverStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping multi-process stress test in short mode")
+	}
+
+	const rounds = 10
+	const takers = 8
+
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+	config.EnsureDirs(coordDir)
+
+	mgr := NewManager(coordDir, cfg)
+
+	for round := 0; round < rounds; round++ {
+		stale := Lock{
+			Resource: stressResource,
+			Holder: Holder{
+				AgentID:    "crashed-agent",
+				AcquiredAt: time.Now().Add(-time.Hour).UTC(),
+				TTLSeconds: 1,
+			},
+		}
+		data, _ := json.Marshal(stale)
+		if err := os.WriteFile(mgr.lockPath(stressResource), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Start all takers at the same moment to maximize contention
+		start := time.Now().Add(500 * time.Millisecond).UnixNano()
+		outputs := make([]*bytes.Buffer, takers)
+		cmds := make([]*exec.Cmd, takers)
+		for i := range cmds {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperTakeover$")
+			cmd.Env = append(os.Environ(),
+				stressDirEnv+"="+coordDir,
+				fmt.Sprintf("%s=taker-%d-%d", stressAgentEnv, round, i),
+				stressStartEnv+"="+strconv.FormatInt(start, 10),
+			)
+			outputs[i] = &bytes.Buffer{}
+			cmd.Stdout = outputs[i]
+			cmd.Stderr = outputs[i]
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			cmds[i] = cmd
+		}
+
+		var winners []string
+		for i, cmd := range cmds {
+			if err := cmd.Wait(); err != nil {
+				t.Fatalf("taker %d failed: %v\n%s", i, err, outputs[i])
+			}
+			scanner := bufio.NewScanner(outputs[i])
+			for scanner.Scan() {
+				if w, ok := strings.CutPrefix(scanner.Text(), "WON "); ok {
+					winners = append(winners, w)
+				}
+			}
+		}
+
+		if len(winners) != 1 {
+			t.Fatalf("round %d: expected exactly one winner, got %v", round, winners)
+		}
+
+		l, err := mgr.Read(stressResource)
+		if err != nil {
+			t.Fatalf("round %d: lock missing after takeover: %v", round, err)
+		}
+		if l.AgentID != winners[0] {
+			t.Fatalf("round %d: lock held by %s, but %s won", round, l.AgentID, winners[0])
+		}
+
+		if err := mgr.Release(stressResource, winners[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestHelperTakeover is run as a child process by TestStaleTakeoverStress
+func TestHelperTakeover(t *testing.T) {
+	coordDir := os.Getenv(stressDirEnv)
+	if coordDir == "" {
+		t.Skip("helper process for TestStaleTakeoverStress")
+	}
+
+	cfg, err := config.Load(coordDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := NewManager(coordDir, cfg)
+	agentID := os.Getenv(stressAgentEnv)
+
+	start, _ := strconv.ParseInt(os.Getenv(stressStartEnv), 10, 64)
+	time.Sleep(time.Until(time.Unix(0, start)))
+
+	if err := mgr.Acquire(stressResource, agentID, "", "stress", 300); err == nil {
+		fmt.Println("WON " + agentID)
+	}
+}
@@ -0,0 +1,160 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultWaitInterval is how often Wait checks a resource when nothing
+// wakes it earlier
+const DefaultWaitInterval = 5 * time.Second
+
+// WaitOptions configures Wait
+type WaitOptions struct {
+	Mode Mode
+
+	// Acquire queues for the resource and takes the lock when our turn
+	// comes, instead of just waiting for it to become available
+	Acquire bool
+
+	AgentName string
+	Operation string
+	TTL       int
+
+	// Interval is the fallback check interval; zero means
+	// DefaultWaitInterval
+	Interval time.Duration
+
+	// Progress, if set, is called whenever what we're waiting for changes
+	Progress func(WaitStatus)
+}
+
+// WaitStatus describes what a waiter is waiting for
+type WaitStatus struct {
+	// Holder is a lock held by another agent, with Holder set to that agent
+	Holder *Lock
+
+	// Ahead is a queued agent that will be served first
+	Ahead *Ticket
+
+	// Position is our 0-based place in the queue, or -1 if not queued
+	Position int
+
+	// Err is the last failed attempt to acquire the lock
+	Err error
+}
+
+func (s WaitStatus) String() string {
+	switch {
+	case s.Err != nil:
+		return s.Err.Error()
+	case s.Holder != nil:
+		return "locked by " + describeAgent(s.Holder.AgentID, s.Holder.Operation)
+	case s.Ahead != nil:
+		return "queued behind " + describeAgent(s.Ahead.AgentID, s.Ahead.Operation)
+	}
+	return "available"
+}
+
+func describeAgent(agentID, operation string) string {
+	if operation == "" {
+		return agentID
+	}
+	return fmt.Sprintf("%s (%s)", agentID, operation)
+}
+
+// Wait blocks until resource is available to agentID, or with
+// opts.Acquire, until agentID has locked it. The wait is registered so it
+// shows up in status and deadlock detection, and waiters are woken by file
+// system notifications where available. Wait fails straight away if it
+// would deadlock, and when ctx is done. Callers can tell a timeout or
+// cancellation apart by checking ctx.Err().
+func (m *Manager) Wait(ctx context.Context, resource, agentID string, opts WaitOptions) error {
+	interval := opts.Interval
+	if interval == 0 {
+		interval = DefaultWaitInterval
+	}
+
+	// With Acquire we also take a place in line
+	register := m.Watch
+	if opts.Acquire {
+		register = m.Enqueue
+	}
+	ticket, err := register(resource, opts.Mode, agentID, opts.AgentName, opts.Operation)
+	if err != nil {
+		return fmt.Errorf("failed to join queue: %w", err)
+	}
+	defer m.Dequeue(resource, agentID)
+
+	// Waiting would never end if the holder is, through some chain of
+	// agents, waiting for us
+	if err := m.CheckDeadlock(agentID); err != nil {
+		return fmt.Errorf("cannot wait for %s: %w", resource, err)
+	}
+
+	// Wake up as soon as something changes, rather than on the next check
+	var changes <-chan struct{}
+	if watcher, err := m.WatchResource(resource); err == nil {
+		defer watcher.Close()
+		changes = watcher.C
+	}
+
+	last := ""
+	for {
+		if current, err := m.Ticket(resource, agentID); err == nil && current != nil && current.Aborted != "" {
+			// gc cancelled our wait to break a deadlock
+			return errorf(CodeDeadlock, "gave up waiting for %s: %s", resource, current.Aborted)
+		}
+		if err := m.Touch(ticket); err != nil {
+			// Our ticket was cleaned up, take a new place in line
+			if ticket, err = register(resource, opts.Mode, agentID, opts.AgentName, opts.Operation); err != nil {
+				return fmt.Errorf("failed to join queue: %w", err)
+			}
+		}
+
+		holder, ahead, err := m.Blocker(resource, opts.Mode, agentID)
+		if err != nil {
+			return err
+		}
+		status := WaitStatus{Holder: holder, Ahead: ahead, Position: -1}
+
+		if holder == nil && ahead == nil {
+			if !opts.Acquire {
+				return nil
+			}
+
+			// Acquire re-checks the queue under the resource's guard, so
+			// nobody can slip in ahead of us
+			status.Err = m.AcquireMode(resource, opts.Mode, agentID, opts.AgentName, opts.Operation, opts.TTL)
+			if status.Err == nil {
+				return nil
+			}
+			if !errors.Is(status.Err, ErrLocked) {
+				// Waiting won't help, e.g. an invalid pattern
+				return status.Err
+			}
+		}
+
+		if opts.Acquire {
+			status.Position, _ = m.Position(resource, agentID)
+		}
+		if report := fmt.Sprintf("%s/%d", status, status.Position); report != last {
+			last = report
+			if opts.Progress != nil {
+				opts.Progress(status)
+			}
+		}
+
+		select {
+		case <-changes:
+		case <-time.After(interval):
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return errorf(CodeTimeout, "timeout waiting for %s (%s)", resource, status)
+			}
+			return ctx.Err()
+		}
+	}
+}
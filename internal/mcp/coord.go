@@ -0,0 +1,520 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// DefaultWaitTimeout bounds coord_wait calls that don't set a timeout
+const DefaultWaitTimeout = 60
+
+// Coordinator provides claude-coord's tools to one MCP session. All tool
+// calls act as the session's agent, so its identity stays the same for the
+// whole session.
+type Coordinator struct {
+	cfg      *config.Config
+	lockMgr  *lock.Manager
+	agentMgr *agent.Manager
+
+	mu        sync.Mutex
+	agentID   string
+	agentName string
+	started   bool
+	stop      chan struct{}
+
+	// generated is set when the agent ID was made up for this session,
+	// rather than shared with e.g. the hooks of the Claude Code session
+	// that started the server
+	generated bool
+}
+
+// NewCoordinator creates a coordinator for a session. An empty agentID is
+// filled in when the client connects, and an empty agentName is taken from
+// the client's name.
+func NewCoordinator(coordDir string, cfg *config.Config, agentID, agentName string) *Coordinator {
+	return &Coordinator{
+		cfg:       cfg,
+		lockMgr:   lock.NewManager(coordDir, cfg),
+		agentMgr:  agent.NewManager(coordDir, cfg),
+		agentID:   agentID,
+		agentName: agentName,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Server returns an MCP server exposing the coordination tools
+func (c *Coordinator) Server(version string) *Server {
+	s := NewServer("claude-coord", version)
+	s.OnInitialize = func(client ClientInfo) error {
+		c.mu.Lock()
+		if c.agentName == "" {
+			c.agentName = client.Name
+		}
+		c.mu.Unlock()
+		_, err := c.identity()
+		return err
+	}
+
+	for _, t := range c.tools() {
+		s.AddTool(t)
+	}
+	return s
+}
+
+// AgentID returns the session's agent ID, or "" before it is known
+func (c *Coordinator) AgentID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.agentID
+}
+
+// Close ends the session: the agent's locks are released, and it is
+// deregistered if its ID was generated for the session. An agent ID given
+// to NewCoordinator may be in use elsewhere, so its record stays.
+func (c *Coordinator) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started {
+		return nil
+	}
+	c.started = false
+	close(c.stop)
+
+	if err := c.lockMgr.ReleaseAll(c.agentID); err != nil {
+		return fmt.Errorf("failed to release locks: %w", err)
+	}
+	if !c.generated {
+		return nil
+	}
+	return c.agentMgr.Deregister(c.agentID)
+}
+
+// identity returns the session's agent ID, registering the agent and
+// starting its heartbeat the first time
+func (c *Coordinator) identity() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return c.agentID, nil
+	}
+
+	if c.agentID == "" {
+		c.agentID = agent.GenerateID()
+		c.generated = true
+	}
+	if err := c.agentMgr.Register(c.agentID, c.agentName); err != nil {
+		return "", fmt.Errorf("failed to register agent: %w", err)
+	}
+	interval := c.cfg.Settings.HeartbeatInterval
+	if interval <= 0 {
+		interval = config.DefaultHeartbeat
+	}
+	go c.agentMgr.RunHeartbeat(c.agentID, time.Duration(interval)*time.Second, c.stop,
+		agent.RenewLocks(c.lockMgr.RenewAll))
+	c.started = true
+	return c.agentID, nil
+}
+
+func (c *Coordinator) tools() []Tool {
+	return []Tool{
+		{
+			Name:        "coord_status",
+			Description: "List current locks, registered agents, agents waiting for resources, and any deadlocks between them.",
+			InputSchema: objectSchema(nil),
+			Handler:     c.status,
+		},
+		{
+			Name: "coord_lock",
+			Description: "Lock one or more resources (protected patterns such as \"db/schema/*\", or logical resource names) " +
+				"before modifying matching files. Several resources are locked all-or-nothing. " +
+				"Fails if another agent holds any of them. Locking a resource this session already holds takes " +
+				"another hold on it, which needs its own unlock.",
+			InputSchema: objectSchema(map[string]any{
+				"resources": arrayOf("string", "Resources to lock"),
+				"shared":    prop("boolean", "Take a shared (read) lock that other readers can hold too"),
+				"operation": prop("string", "What you're doing, shown to other agents"),
+				"ttl":       prop("integer", "Lock timeout in seconds (default from config)"),
+			}, "resources"),
+			Handler: c.lock,
+		},
+		{
+			Name:        "coord_unlock",
+			Description: "Release locks held by this session, one hold at a time.",
+			InputSchema: objectSchema(map[string]any{
+				"resources": arrayOf("string", "Resources to release"),
+				"force":     prop("boolean", "Drop every hold on the resources, not just one"),
+				"all":       prop("boolean", "Release every lock held by this session"),
+			}),
+			Handler: c.unlock,
+		},
+		{
+			Name: "coord_check",
+			Description: "Check whether files are protected and whether another agent holds a lock on them. " +
+				"With acquire, lock unlocked protected files for this session.",
+			InputSchema: objectSchema(map[string]any{
+				"files":     arrayOf("string", "File paths relative to the project root"),
+				"acquire":   prop("boolean", "Lock protected files that aren't locked yet"),
+				"operation": prop("string", "What you're doing, for acquired locks"),
+			}, "files"),
+			Handler: c.check,
+		},
+		{
+			Name: "coord_wait",
+			Description: "Wait until a resource is available. With acquire, queue for it and lock it when this " +
+				"session's turn comes. Fails if waiting would deadlock.",
+			InputSchema: objectSchema(map[string]any{
+				"resource":        prop("string", "Resource to wait for"),
+				"acquire":         prop("boolean", "Queue for the resource and lock it"),
+				"shared":          prop("boolean", "Wait for a shared (read) lock"),
+				"operation":       prop("string", "What you're doing, shown to other agents"),
+				"ttl":             prop("integer", "Lock timeout in seconds when acquiring (default from config)"),
+				"timeout_seconds": prop("integer", fmt.Sprintf("Maximum time to wait (default %d)", DefaultWaitTimeout)),
+			}, "resource"),
+			Handler: c.wait,
+		},
+		{
+			Name:        "coord_extend",
+			Description: "Renew the lease on a lock held by this session during long-running work.",
+			InputSchema: objectSchema(map[string]any{
+				"resource": prop("string", "Resource whose lock to renew"),
+				"ttl":      prop("integer", "New lock timeout in seconds (default: keep the current one)"),
+			}, "resource"),
+			Handler: c.extend,
+		},
+	}
+}
+
+type lockStatus struct {
+	lock.Lock
+	Stale bool `json:"stale"`
+}
+
+type agentStatus struct {
+	agent.Agent
+	Alive bool `json:"alive"`
+}
+
+type statusResult struct {
+	AgentID   string                   `json:"agent_id"`
+	Locks     []lockStatus             `json:"locks"`
+	Agents    []agentStatus            `json:"agents"`
+	Waiting   map[string][]lock.Ticket `json:"waiting"`
+	Deadlocks []string                 `json:"deadlocks"`
+}
+
+func (c *Coordinator) status(ctx context.Context, args json.RawMessage) (any, error) {
+	agentID, err := c.identity()
+	if err != nil {
+		return nil, err
+	}
+
+	locks, err := c.lockMgr.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locks: %w", err)
+	}
+	agents, err := c.agentMgr.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	queues, err := c.lockMgr.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+	deadlocks, err := c.lockMgr.Deadlocks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for deadlocks: %w", err)
+	}
+
+	result := statusResult{
+		AgentID:   agentID,
+		Locks:     []lockStatus{},
+		Agents:    []agentStatus{},
+		Waiting:   map[string][]lock.Ticket{},
+		Deadlocks: []string{},
+	}
+	for _, l := range locks {
+		result.Locks = append(result.Locks, lockStatus{Lock: l, Stale: c.lockMgr.IsStale(&l)})
+	}
+	for _, a := range agents {
+		result.Agents = append(result.Agents, agentStatus{Agent: a, Alive: c.agentMgr.IsAlive(&a)})
+	}
+	sort.Slice(result.Agents, func(i, j int) bool { return result.Agents[i].ID < result.Agents[j].ID })
+	for r, tickets := range queues {
+		result.Waiting[r] = tickets
+	}
+	for _, d := range deadlocks {
+		result.Deadlocks = append(result.Deadlocks, d.String())
+	}
+	return result, nil
+}
+
+type heldLock struct {
+	Resource  string    `json:"resource"`
+	Mode      lock.Mode `json:"mode"`
+	Files     []string  `json:"files,omitempty"`
+	Token     uint64    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (c *Coordinator) held(resource, agentID string) (heldLock, error) {
+	l, err := c.lockMgr.Read(resource)
+	if err != nil {
+		return heldLock{}, fmt.Errorf("failed to read lock: %w", err)
+	}
+	h := l.HolderFor(agentID)
+	if h == nil {
+		return heldLock{}, fmt.Errorf("lock on %s is not held by %s", resource, agentID)
+	}
+
+	mode := l.Mode
+	if mode == "" {
+		mode = lock.Exclusive
+	}
+	return heldLock{
+		Resource:  resource,
+		Mode:      mode,
+		Files:     l.Files,
+		Token:     h.Token,
+		ExpiresAt: h.ExpiresAt(),
+	}, nil
+}
+
+func (c *Coordinator) lock(ctx context.Context, args json.RawMessage) (any, error) {
+	var params struct {
+		Resources []string `json:"resources"`
+		Shared    bool     `json:"shared"`
+		Operation string   `json:"operation"`
+		TTL       int      `json:"ttl"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	if len(params.Resources) == 0 {
+		return nil, fmt.Errorf("resources is required")
+	}
+
+	agentID, err := c.identity()
+	if err != nil {
+		return nil, err
+	}
+
+	mode := lock.Exclusive
+	if params.Shared {
+		mode = lock.Shared
+	}
+	if err := c.lockMgr.AcquireAll(params.Resources, mode, agentID, c.agentName, params.Operation, params.TTL); err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		AgentID string     `json:"agent_id"`
+		Locked  []heldLock `json:"locked"`
+	}{AgentID: agentID}
+	for _, r := range params.Resources {
+		h, err := c.held(r, agentID)
+		if err != nil {
+			return nil, err
+		}
+		result.Locked = append(result.Locked, h)
+	}
+	return result, nil
+}
+
+func (c *Coordinator) unlock(ctx context.Context, args json.RawMessage) (any, error) {
+	var params struct {
+		Resources []string `json:"resources"`
+		Force     bool     `json:"force"`
+		All       bool     `json:"all"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	if len(params.Resources) == 0 && !params.All {
+		return nil, fmt.Errorf("resources or all is required")
+	}
+
+	agentID, err := c.identity()
+	if err != nil {
+		return nil, err
+	}
+
+	released := []string{}
+	if params.All {
+		locks, err := c.lockMgr.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list locks: %w", err)
+		}
+		for _, l := range locks {
+			if l.HeldBy(agentID) {
+				released = append(released, l.Resource)
+			}
+		}
+		if err := c.lockMgr.ReleaseAll(agentID); err != nil {
+			return nil, err
+		}
+	} else {
+		release := c.lockMgr.Release
+		if params.Force {
+			release = c.lockMgr.ForceRelease
+		}
+		for _, r := range params.Resources {
+			if err := release(r, agentID); err != nil {
+				return nil, fmt.Errorf("failed to release %s: %w", r, err)
+			}
+			released = append(released, r)
+		}
+	}
+
+	return struct {
+		Released []string `json:"released"`
+	}{released}, nil
+}
+
+// fileCheck is a file's check, with whether this session may modify it
+type fileCheck struct {
+	lock.FileCheck
+	Blocked bool `json:"blocked"`
+}
+
+func (c *Coordinator) check(ctx context.Context, args json.RawMessage) (any, error) {
+	var params struct {
+		Files     []string `json:"files"`
+		Acquire   bool     `json:"acquire"`
+		Operation string   `json:"operation"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	if len(params.Files) == 0 {
+		return nil, fmt.Errorf("files is required")
+	}
+
+	agentID, err := c.identity()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []fileCheck{}
+	for _, f := range params.Files {
+		check, err := c.lockMgr.CheckFile(f, params.Acquire, agentID, c.agentName, params.Operation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", f, err)
+		}
+		results = append(results, fileCheck{FileCheck: *check, Blocked: check.IsBlocked()})
+	}
+
+	return struct {
+		AgentID string      `json:"agent_id"`
+		Files   []fileCheck `json:"files"`
+	}{agentID, results}, nil
+}
+
+func (c *Coordinator) wait(ctx context.Context, args json.RawMessage) (any, error) {
+	var params struct {
+		Resource       string `json:"resource"`
+		Acquire        bool   `json:"acquire"`
+		Shared         bool   `json:"shared"`
+		Operation      string `json:"operation"`
+		TTL            int    `json:"ttl"`
+		TimeoutSeconds int    `json:"timeout_seconds"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	if params.Resource == "" {
+		return nil, fmt.Errorf("resource is required")
+	}
+	if params.TimeoutSeconds <= 0 {
+		params.TimeoutSeconds = DefaultWaitTimeout
+	}
+
+	agentID, err := c.identity()
+	if err != nil {
+		return nil, err
+	}
+
+	mode := lock.Exclusive
+	if params.Shared {
+		mode = lock.Shared
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(params.TimeoutSeconds)*time.Second)
+	defer cancel()
+	err = c.lockMgr.Wait(ctx, params.Resource, agentID, lock.WaitOptions{
+		Mode:      mode,
+		Acquire:   params.Acquire,
+		AgentName: c.agentName,
+		Operation: params.Operation,
+		TTL:       params.TTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !params.Acquire {
+		return struct {
+			Resource  string `json:"resource"`
+			Available bool   `json:"available"`
+		}{params.Resource, true}, nil
+	}
+	return c.held(params.Resource, agentID)
+}
+
+func (c *Coordinator) extend(ctx context.Context, args json.RawMessage) (any, error) {
+	var params struct {
+		Resource string `json:"resource"`
+		TTL      int    `json:"ttl"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	if params.Resource == "" {
+		return nil, fmt.Errorf("resource is required")
+	}
+
+	agentID, err := c.identity()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.lockMgr.Renew(params.Resource, agentID, params.TTL); err != nil {
+		return nil, err
+	}
+	return c.held(params.Resource, agentID)
+}
+
+// objectSchema returns a JSON schema for an object with the given
+// properties
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	if properties == nil {
+		properties = map[string]any{}
+	}
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func prop(typ, description string) map[string]any {
+	return map[string]any{"type": typ, "description": description}
+}
+
+func arrayOf(typ, description string) map[string]any {
+	return map[string]any{
+		"type":        "array",
+		"items":       map[string]any{"type": typ},
+		"description": description,
+	}
+}
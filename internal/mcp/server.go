@@ -0,0 +1,299 @@
+// Package mcp implements a Model Context Protocol server over stdio, as
+// used by Claude Code to call tools provided by local processes.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the newest protocol revision the server speaks
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions lists the protocol revisions the server accepts
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Tool is a tool the server exposes to clients
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	// Handler runs the tool. Its result is sent back as JSON; an error is
+	// reported to the client as a failed tool call, not a protocol error.
+	Handler func(ctx context.Context, args json.RawMessage) (any, error) `json:"-"`
+}
+
+// ClientInfo identifies the client, as sent in its initialize request
+type ClientInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Server is an MCP server. Requests are handled concurrently, so a long
+// running tool call doesn't hold up others.
+type Server struct {
+	name    string
+	version string
+	tools   []Tool
+
+	// OnInitialize, if set, is called with the client's details before the
+	// initialize request is answered
+	OnInitialize func(ClientInfo) error
+
+	out     io.Writer
+	outMu   sync.Mutex
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	pending map[string]context.CancelFunc
+}
+
+// NewServer creates a server that reports the given name and version
+func NewServer(name, version string) *Server {
+	return &Server{
+		name:    name,
+		version: version,
+		pending: make(map[string]context.CancelFunc),
+	}
+}
+
+// AddTool registers a tool
+func (s *Server) AddTool(t Tool) {
+	s.tools = append(s.tools, t)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes replies
+// to w until r is exhausted or ctx is done, returning ctx's error in the
+// latter case. Tool calls still running are waited for, and cancelled
+// along with ctx.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.out = w
+
+	// Calls in flight finish before we return. They're cancelled if ctx is.
+	defer s.wg.Wait()
+
+	// Read in the background so a cancelled ctx isn't stuck behind a
+	// blocking read
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	for {
+		var line []byte
+		select {
+		case line = <-lines:
+		case err := <-readErr:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.reply(nil, nil, &rpcError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			// Replies to requests we made, or garbage
+			if len(req.ID) > 0 && req.Method == "" {
+				continue
+			}
+			s.reply(req.ID, nil, &rpcError{Code: codeInvalidRequest, Message: "invalid request"})
+			continue
+		}
+
+		s.handle(ctx, &req)
+	}
+}
+
+func (s *Server) handle(ctx context.Context, req *request) {
+	isNotification := len(req.ID) == 0
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string     `json:"protocolVersion"`
+			ClientInfo      ClientInfo `json:"clientInfo"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.reply(req.ID, nil, &rpcError{Code: codeInvalidParams, Message: err.Error()})
+			return
+		}
+		if s.OnInitialize != nil {
+			if err := s.OnInitialize(params.ClientInfo); err != nil {
+				s.reply(req.ID, nil, &rpcError{Code: codeInvalidRequest, Message: err.Error()})
+				return
+			}
+		}
+
+		version := ProtocolVersion
+		if supportedVersions[params.ProtocolVersion] {
+			version = params.ProtocolVersion
+		}
+		s.reply(req.ID, map[string]any{
+			"protocolVersion": version,
+			"capabilities": map[string]any{
+				"tools": map[string]any{},
+			},
+			"serverInfo": map[string]any{
+				"name":    s.name,
+				"version": s.version,
+			},
+		}, nil)
+
+	case "ping":
+		s.reply(req.ID, map[string]any{}, nil)
+
+	case "tools/list":
+		s.reply(req.ID, map[string]any{"tools": s.tools}, nil)
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.reply(req.ID, nil, &rpcError{Code: codeInvalidParams, Message: err.Error()})
+			return
+		}
+		tool := s.findTool(params.Name)
+		if tool == nil {
+			s.reply(req.ID, nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", params.Name)})
+			return
+		}
+		if len(params.Arguments) == 0 {
+			params.Arguments = json.RawMessage("{}")
+		}
+
+		callCtx, cancel := context.WithCancel(ctx)
+		key := string(req.ID)
+		s.mu.Lock()
+		s.pending[key] = cancel
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.pending, key)
+				s.mu.Unlock()
+				cancel()
+			}()
+
+			result, err := tool.Handler(callCtx, params.Arguments)
+			s.reply(req.ID, toolResult(result, err), nil)
+		}()
+
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		if json.Unmarshal(req.Params, &params) == nil {
+			s.mu.Lock()
+			if cancel, ok := s.pending[string(params.RequestID)]; ok {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+
+	default:
+		if !isNotification {
+			s.reply(req.ID, nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)})
+		}
+	}
+}
+
+func (s *Server) findTool(name string) *Tool {
+	for i := range s.tools {
+		if s.tools[i].Name == name {
+			return &s.tools[i]
+		}
+	}
+	return nil
+}
+
+// toolResult wraps a tool's result or error in a tools/call reply. Results
+// are sent both as text, for clients that only read content, and as
+// structured content.
+func toolResult(result any, err error) map[string]any {
+	if err != nil {
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+
+	data, jsonErr := json.MarshalIndent(result, "", "  ")
+	if jsonErr != nil {
+		return toolResult(nil, jsonErr)
+	}
+	return map[string]any{
+		"content":           []map[string]any{{"type": "text", "text": string(data)}},
+		"structuredContent": result,
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rpcErr *rpcError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	data, err := json.Marshal(response{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr})
+	if err != nil {
+		data, _ = json.Marshal(response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: codeInvalidRequest, Message: err.Error()}})
+	}
+
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
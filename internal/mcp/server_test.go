@@ -0,0 +1,196 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// session drives a server over pipes the way an MCP client would
+type session struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Scanner
+	nextID int
+	done   chan error
+}
+
+func startSession(t *testing.T, c *Coordinator) *session {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	s := &session{t: t, in: inW, out: bufio.NewScanner(outR), done: make(chan error, 1)}
+	go func() {
+		s.done <- c.Server("test").Serve(context.Background(), inR, outW)
+		outW.Close()
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		<-s.done
+		c.Close()
+	})
+
+	s.call("initialize", map[string]any{
+		"protocolVersion": "2025-06-18",
+		"clientInfo":      map[string]any{"name": "test-client", "version": "1.0"},
+	})
+	return s
+}
+
+func (s *session) call(method string, params any) map[string]any {
+	s.t.Helper()
+	s.nextID++
+	req, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": s.nextID, "method": method, "params": params})
+	if _, err := s.in.Write(append(req, '\n')); err != nil {
+		s.t.Fatal(err)
+	}
+
+	if !s.out.Scan() {
+		s.t.Fatalf("No response to %s", method)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(s.out.Bytes(), &resp); err != nil {
+		s.t.Fatal(err)
+	}
+	if resp["id"] != float64(s.nextID) {
+		s.t.Fatalf("Response id %v, expected %d", resp["id"], s.nextID)
+	}
+	return resp
+}
+
+// tool calls a tool and returns its structured result, or the error text
+func (s *session) tool(name string, args map[string]any) (map[string]any, string) {
+	s.t.Helper()
+	resp := s.call("tools/call", map[string]any{"name": name, "arguments": args})
+	result, ok := resp["result"].(map[string]any)
+	if !ok {
+		s.t.Fatalf("Protocol error calling %s: %v", name, resp["error"])
+	}
+	if result["isError"] == true {
+		content := result["content"].([]any)
+		return nil, content[0].(map[string]any)["text"].(string)
+	}
+	return result["structuredContent"].(map[string]any), ""
+}
+
+func newCoordDir(t *testing.T) (string, *config.Config) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Save(coordDir)
+	return coordDir, cfg
+}
+
+func TestProtocol(t *testing.T) {
+	coordDir, cfg := newCoordDir(t)
+	s := startSession(t, NewCoordinator(coordDir, cfg, "agent-1", ""))
+
+	resp := s.call("tools/list", nil)
+	tools := resp["result"].(map[string]any)["tools"].([]any)
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.(map[string]any)["name"].(string))
+	}
+	want := "coord_status coord_lock coord_unlock coord_check coord_wait coord_extend"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("Expected tools %q, got %q", want, got)
+	}
+
+	if resp := s.call("no/such/method", nil); resp["error"] == nil {
+		t.Error("Expected error for unknown method")
+	}
+	if resp := s.call("ping", nil); resp["error"] != nil {
+		t.Errorf("Ping failed: %v", resp["error"])
+	}
+}
+
+func TestCoordinationTools(t *testing.T) {
+	coordDir, cfg := newCoordDir(t)
+	alice := NewCoordinator(coordDir, cfg, "", "")
+	a := startSession(t, alice)
+	b := startSession(t, NewCoordinator(coordDir, cfg, "agent-b", "bob"))
+
+	// The session's identity is fixed when the client connects
+	if alice.AgentID() == "" {
+		t.Fatal("Expected an agent ID to be assigned on initialize")
+	}
+
+	locked, errText := a.tool("coord_lock", map[string]any{"resources": []string{"db/schema/*"}, "operation": "migration"})
+	if errText != "" {
+		t.Fatalf("Lock failed: %s", errText)
+	}
+	if locked["agent_id"] != alice.AgentID() {
+		t.Errorf("Lock taken as %v, expected %s", locked["agent_id"], alice.AgentID())
+	}
+
+	if _, errText := b.tool("coord_lock", map[string]any{"resources": []string{"db/schema/*"}}); errText == "" {
+		t.Fatal("Second session acquired a held lock")
+	}
+
+	checked, _ := b.tool("coord_check", map[string]any{"files": []string{"db/schema/users.sql", "README.md"}, "acquire": true})
+	files := checked["files"].([]any)
+	first := files[0].(map[string]any)
+	blocker, _ := first["blocker"].(map[string]any)
+	if first["blocked"] != true || blocker["agent_id"] != alice.AgentID() || blocker["operation"] != "migration" {
+		t.Errorf("Expected users.sql blocked by alice, got %v", first)
+	}
+	if files[1].(map[string]any)["protected"] != false {
+		t.Errorf("README.md shouldn't be protected: %v", files[1])
+	}
+
+	status, _ := b.tool("coord_status", nil)
+	if len(status["locks"].([]any)) != 1 || len(status["agents"].([]any)) != 2 {
+		t.Errorf("Unexpected status: %v", status)
+	}
+
+	if _, errText := a.tool("coord_extend", map[string]any{"resource": "db/schema/*", "ttl": 600}); errText != "" {
+		t.Errorf("Extend failed: %s", errText)
+	}
+
+	// A queued wait gets the lock once it's released
+	if _, errText := b.tool("coord_wait", map[string]any{"resource": "db/schema/*", "acquire": true, "timeout_seconds": 1}); !strings.Contains(errText, "timeout") {
+		t.Errorf("Expected wait to time out, got %q", errText)
+	}
+	if _, errText := a.tool("coord_unlock", map[string]any{"all": true}); errText != "" {
+		t.Fatalf("Unlock failed: %s", errText)
+	}
+	waited, errText := b.tool("coord_wait", map[string]any{"resource": "db/schema/*", "acquire": true, "timeout_seconds": 5})
+	if errText != "" {
+		t.Fatalf("Wait failed: %s", errText)
+	}
+	if token, _ := waited["token"].(float64); token <= locked["locked"].([]any)[0].(map[string]any)["token"].(float64) {
+		t.Errorf("Expected a newer fencing token, got %v", waited["token"])
+	}
+}
+
+func TestCloseKeepsSharedIdentity(t *testing.T) {
+	coordDir, cfg := newCoordDir(t)
+	agentMgr := agent.NewManager(coordDir, cfg)
+
+	shared := NewCoordinator(coordDir, cfg, "session-1", "")
+	s := startSession(t, shared)
+	if _, errText := s.tool("coord_lock", map[string]any{"resources": []string{"package.json"}}); errText != "" {
+		t.Fatalf("Lock failed: %s", errText)
+	}
+	shared.Close()
+	if _, err := agentMgr.Read("session-1"); err != nil {
+		t.Errorf("Expected the shared agent to stay registered: %v", err)
+	}
+	if l, _ := lock.NewManager(coordDir, cfg).Read("package.json"); l != nil {
+		t.Errorf("Expected the session's locks to be released, got %+v", l)
+	}
+
+	own := NewCoordinator(coordDir, cfg, "", "")
+	startSession(t, own)
+	own.Close()
+	if _, err := agentMgr.Read(own.AgentID()); err == nil {
+		t.Error("Expected the generated agent to be deregistered")
+	}
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+)
+
+// FileStore keeps each entry in its own file: locks/<key>.lock,
+// locks/<key>.fence and agents/<key>.agent. Keys must be valid file names.
+// Writes go through a temporary file so an entry is never seen half-written.
+type FileStore struct {
+	coordDir string
+}
+
+// NewFileStore returns a file store for coordDir
+func NewFileStore(coordDir string) *FileStore {
+	return &FileStore{coordDir: coordDir}
+}
+
+// layout returns the directory and file suffix of a namespace
+func (s *FileStore) layout(ns Namespace) (string, string) {
+	switch ns {
+	case Locks:
+		return filepath.Join(s.coordDir, config.LocksDir), ".lock"
+	case Fences:
+		return filepath.Join(s.coordDir, config.LocksDir), ".fence"
+	case Agents:
+		return filepath.Join(s.coordDir, config.AgentsDir), ".agent"
+	}
+	return filepath.Join(s.coordDir, string(ns)), "." + string(ns)
+}
+
+// Path returns the file holding key
+func (s *FileStore) Path(ns Namespace, key string) string {
+	dir, suffix := s.layout(ns)
+	return filepath.Join(dir, key+suffix)
+}
+
+// Get returns the entry for key
+func (s *FileStore) Get(ns Namespace, key string) (*Entry, error) {
+	return readEntry(s.Path(ns, key), key)
+}
+
+// List returns every entry in ns, sorted by key
+func (s *FileStore) List(ns Namespace) ([]Entry, error) {
+	dir, suffix := s.layout(ns)
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, de := range dirEntries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), suffix) {
+			continue
+		}
+		key := strings.TrimSuffix(de.Name(), suffix)
+		entry, err := readEntry(filepath.Join(dir, de.Name()), key)
+		if err != nil {
+			// Removed since we listed the directory
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// link is os.Link, replaceable by tests
+var link = os.Link
+
+// Create atomically creates the file for key, failing with an os.IsExist
+// error if it's already taken. The contents are hard-linked into place from
+// a temporary file, so an existing entry is never overwritten. Where hard
+// links aren't supported, the file is created exclusively and written in
+// place instead, so readers may briefly see it empty.
+func (s *FileStore) Create(ns Namespace, key string, value []byte) error {
+	tmpName, err := s.writeTemp(ns, value)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpName)
+
+	err = link(tmpName, s.Path(ns, key))
+	if err == nil || os.IsExist(err) {
+		return err
+	}
+	return createExclusive(s.Path(ns, key), value)
+}
+
+// createExclusive creates path with value, failing if it already exists
+func createExclusive(path string, value []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(value); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// Put replaces the file for key. The new contents are renamed into place.
+func (s *FileStore) Put(ns Namespace, key string, value []byte) error {
+	tmpName, err := s.writeTemp(ns, value)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, s.Path(ns, key)); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// CompareAndDelete removes the file for key if it still holds old. The file
+// is first moved aside, so a writer can't replace it between the comparison
+// and the removal; a changed entry is moved back unless it has been
+// recreated meanwhile.
+func (s *FileStore) CompareAndDelete(ns Namespace, key string, old []byte) error {
+	path := s.Path(ns, key)
+	dir, _ := s.layout(ns)
+
+	aside := filepath.Join(dir, ".del-"+filepath.Base(path))
+	if err := os.Rename(path, aside); err != nil {
+		return err
+	}
+	defer os.Remove(aside)
+
+	current, err := os.ReadFile(aside)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, old) {
+		os.Link(aside, path)
+		return ErrConflict
+	}
+	return nil
+}
+
+// Delete removes the file for key
+func (s *FileStore) Delete(ns Namespace, key string) error {
+	return os.Remove(s.Path(ns, key))
+}
+
+// Watch notifies of changes to files in ns
+func (s *FileStore) Watch(ns Namespace) (*Watcher, error) {
+	dir, suffix := s.layout(ns)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return watchDir(dir, func(name string) bool {
+		return strings.HasSuffix(name, suffix)
+	})
+}
+
+// Close does nothing; files need no cleanup
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// writeTemp writes value to a new temporary file in ns's directory and
+// returns its name. Temporary files never carry an entry suffix.
+func (s *FileStore) writeTemp(ns Namespace, value []byte) (string, error) {
+	dir, _ := s.layout(ns)
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	// Temporary files are private; entries are readable like other files
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func readEntry(path, key string) (*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(f); err != nil {
+		return nil, err
+	}
+	return &Entry{Key: key, Value: buf.Bytes(), ModTime: info.ModTime()}, nil
+}
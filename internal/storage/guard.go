@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"time"
+)
+
+// WithGuard runs fn so that no other update guarded by the same name
+// interleaves. Stores shared between hosts provide their own guard;
+// otherwise the guard file at path does, which must be in the coord dir.
+func WithGuard(store Store, name, path string, timeout time.Duration, fn func() error) error {
+	if guarded, ok := store.(Guarded); ok {
+		return guarded.Guard(name, timeout, fn)
+	}
+	return withFileGuard(path, timeout, fn)
+}
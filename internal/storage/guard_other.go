@@ -0,0 +1,45 @@
+//go:build !unix
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// guardStale is when a guard is assumed to be left behind by a crash.
+// Guards are only held for a single read-modify-write.
+const guardStale = 10 * time.Second
+
+// withFileGuard runs fn while holding the guard file at path, so
+// read-modify-write updates never interleave. Without flock the guard is an
+// O_EXCL file; one abandoned by a crashed process is broken after
+// guardStale.
+func withFileGuard(path string, timeout time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		fd, err := syscall.Open(path, syscall.O_CREAT|syscall.O_EXCL|syscall.O_WRONLY, 0644)
+		if err == nil {
+			syscall.Close(fd)
+			break
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to take guard: %w", err)
+		}
+
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > guardStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: %s", ErrGuardTimeout, path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer os.Remove(path)
+
+	return fn()
+}
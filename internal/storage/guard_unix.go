@@ -0,0 +1,68 @@
+//go:build unix
+
+package storage
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// withFileGuard runs fn while holding an exclusive flock on the guard file
+// at path, so read-modify-write updates (including stale lock takeover)
+// never interleave. The kernel drops the flock if the holder dies, so a
+// crashed process can never leave a guard that others must break.
+//
+// The guard file is removed on the way out while still locked. A waiter that
+// then gets the flock on the unlinked file notices that the path no longer
+// refers to it and starts over.
+func withFileGuard(path string, timeout time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to take guard: %w", err)
+		}
+
+		locked := false
+		for {
+			err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+			if err == nil {
+				locked = true
+				break
+			}
+			if err != syscall.EWOULDBLOCK || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if !locked {
+			f.Close()
+			return fmt.Errorf("%w: %s", ErrGuardTimeout, path)
+		}
+
+		if sameFile(f, path) {
+			defer f.Close()
+			defer os.Remove(path)
+			return fn()
+		}
+
+		// The previous holder removed the guard; retry on the current file
+		f.Close()
+	}
+}
+
+// sameFile reports whether the open file f is still the one at path
+func sameFile(f *os.File, path string) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, current)
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// maxKeyLen keeps file names derived from keys well below the 255 byte
+// limit most filesystems impose, leaving room for a suffix such as ".lock".
+const maxKeyLen = 200
+
+// hashedPrefix marks keys derived from a hash of the name. '~' is always
+// escaped by EncodeKey, so hashed and escaped keys never collide.
+const hashedPrefix = "~"
+
+// EncodeKey converts a name, such as a resource pattern or an agent ID, into
+// a key that is unique per name and safe as a file name, even on
+// case-insensitive filesystems. Lower-case letters, digits, '.', '_' and '-'
+// are kept as-is and every other byte, upper-case letters included, is
+// written as %XX, so the mapping is reversible. Names that would be too long
+// are replaced by a hash, so entries should store the name they're for.
+func EncodeKey(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isSafeKeyByte(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	encoded := b.String()
+	// A bare "." or ".." would still be valid with a suffix, but keep keys
+	// from starting with a dot so their files are never hidden.
+	if strings.HasPrefix(encoded, ".") {
+		encoded = "%2E" + encoded[1:]
+	}
+
+	if len(encoded) > maxKeyLen {
+		sum := sha256.Sum256([]byte(name))
+		return hashedPrefix + hex.EncodeToString(sum[:])
+	}
+	return encoded
+}
+
+func isSafeKeyByte(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		return true
+	case c == '.', c == '_', c == '-':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+)
+
+// DefaultRedisPrefix is prepended to every key the Redis backend writes
+const DefaultRedisPrefix = "claude-coord"
+
+// redisGuardLease is how long a guard outlives a crashed holder. Guards are
+// only held for a single read-modify-write.
+const redisGuardLease = 10 * time.Second
+
+// redisCompareAndDelete deletes KEYS[1] if its value, past the write time
+// header, is ARGV[1]. It returns 1 if deleted, 0 if the value differs and -1
+// if there's no such key.
+const redisCompareAndDelete = `
+local v = redis.call('GET', KEYS[1])
+if not v then return -1 end
+local i = string.find(v, '\n', 1, true)
+if not i or string.sub(v, i + 1) ~= ARGV[1] then return 0 end
+redis.call('DEL', KEYS[1])
+return 1
+`
+
+// redisReleaseGuard deletes the guard KEYS[1] if it still holds our token
+const redisReleaseGuard = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+// RedisStore keeps entries in Redis, so agents on different hosts can share
+// locks. Values are stored with a header recording when they were written,
+// since heartbeats rely on ModTime, and every write is published so watchers
+// on any host wake up.
+type RedisStore struct {
+	opts   *redisOptions
+	prefix string
+
+	mu   sync.Mutex
+	conn *redisConn
+}
+
+// openRedis connects to the server configured in cfg
+func openRedis(cfg *config.Config) (Store, error) {
+	if cfg == nil || cfg.Settings.RedisURL == "" {
+		return nil, fmt.Errorf("the redis backend needs settings.redis_url in %s", config.ConfigFileName)
+	}
+	// Keep passwords out of config.yaml, e.g. redis://:${REDIS_PASSWORD}@redis:6379
+	opts, err := parseRedisURL(os.ExpandEnv(cfg.Settings.RedisURL))
+	if err != nil {
+		return nil, err
+	}
+	prefix := cfg.Settings.RedisPrefix
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+
+	s := &RedisStore{opts: opts, prefix: prefix}
+	if _, err := s.do("PING"); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", opts.addr, err)
+	}
+	return s, nil
+}
+
+// do runs a command on the shared connection, reconnecting if the last one
+// broke. Commands are never retried, since a write may have been applied.
+func (s *RedisStore) do(args ...any) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := dialRedis(s.opts)
+		if err != nil {
+			return nil, err
+		}
+		s.conn = conn
+	}
+	reply, err := s.conn.do(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		s.conn.close()
+		s.conn = nil
+	}
+	return reply, err
+}
+
+func (s *RedisStore) key(ns Namespace, key string) string {
+	return s.prefix + ":" + string(ns) + ":" + key
+}
+
+func (s *RedisStore) channel(ns Namespace) string {
+	return s.prefix + ":changes:" + string(ns)
+}
+
+// publish wakes watchers of ns. Watchers also poll, so a lost notification
+// only delays them.
+func (s *RedisStore) publish(ns Namespace) {
+	s.do("PUBLISH", s.channel(ns), "1")
+}
+
+// Get returns the entry for key
+func (s *RedisStore) Get(ns Namespace, key string) (*Entry, error) {
+	reply, err := s.do("GET", s.key(ns, key))
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, notFound(ns, key)
+	}
+	return decodeRedisEntry(key, reply)
+}
+
+// List returns every entry in ns, sorted by key
+func (s *RedisStore) List(ns Namespace) ([]Entry, error) {
+	prefix := s.key(ns, "")
+	pattern := redisGlobEscape(prefix) + "*"
+
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := s.do("SCAN", cursor, "MATCH", pattern, "COUNT", 100)
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply: %v", reply)
+		}
+		cursor = redisString(parts[0])
+		batch, _ := parts[1].([]any)
+		for _, k := range batch {
+			keys = append(keys, redisString(k))
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	args := []any{"MGET"}
+	for _, k := range keys {
+		args = append(args, k)
+	}
+	reply, err := s.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := reply.([]any)
+
+	var entries []Entry
+	for i, v := range values {
+		// Deleted or expired since the scan
+		if v == nil || i >= len(keys) {
+			continue
+		}
+		entry, err := decodeRedisEntry(strings.TrimPrefix(keys[i], prefix), v)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// Create stores value under key only if no entry exists yet
+func (s *RedisStore) Create(ns Namespace, key string, value []byte) error {
+	return s.CreateWithTTL(ns, key, value, 0)
+}
+
+// CreateWithTTL is Create for an entry that Redis drops after ttl
+func (s *RedisStore) CreateWithTTL(ns Namespace, key string, value []byte, ttl time.Duration) error {
+	args := append([]any{"SET", s.key(ns, key), encodeRedisValue(value), "NX"}, redisTTLArgs(ttl)...)
+	reply, err := s.do(args...)
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return exists(ns, key)
+	}
+	s.publish(ns)
+	return nil
+}
+
+// Put stores value under key, replacing any existing entry
+func (s *RedisStore) Put(ns Namespace, key string, value []byte) error {
+	return s.PutWithTTL(ns, key, value, 0)
+}
+
+// PutWithTTL is Put for an entry that Redis drops after ttl
+func (s *RedisStore) PutWithTTL(ns Namespace, key string, value []byte, ttl time.Duration) error {
+	args := append([]any{"SET", s.key(ns, key), encodeRedisValue(value)}, redisTTLArgs(ttl)...)
+	if _, err := s.do(args...); err != nil {
+		return err
+	}
+	s.publish(ns)
+	return nil
+}
+
+// CompareAndDelete removes the entry only if it still holds old
+func (s *RedisStore) CompareAndDelete(ns Namespace, key string, old []byte) error {
+	reply, err := s.do("EVAL", redisCompareAndDelete, 1, s.key(ns, key), old)
+	if err != nil {
+		return err
+	}
+	switch reply {
+	case int64(-1):
+		return notFound(ns, key)
+	case int64(0):
+		return ErrConflict
+	}
+	s.publish(ns)
+	return nil
+}
+
+// Delete removes the entry for key
+func (s *RedisStore) Delete(ns Namespace, key string) error {
+	reply, err := s.do("DEL", s.key(ns, key))
+	if err != nil {
+		return err
+	}
+	if reply == int64(0) {
+		return notFound(ns, key)
+	}
+	s.publish(ns)
+	return nil
+}
+
+// Watch notifies of changes to entries in ns made through any RedisStore
+// using the same server and prefix. Entries that expire don't notify.
+func (s *RedisStore) Watch(ns Namespace) (*Watcher, error) {
+	conn, err := dialRedis(s.opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.send("SUBSCRIBE", s.channel(ns)); err != nil {
+		conn.close()
+		return nil, err
+	}
+	if _, err := conn.receive(); err != nil {
+		conn.close()
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		// Ends when the connection is closed
+		for {
+			if _, err := conn.receive(); err != nil {
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return &Watcher{C: ch, close: conn.close}, nil
+}
+
+// Guard runs fn while holding the named guard. A guard is a key holding a
+// random token, so only its holder releases it; one left by a crashed
+// process expires after redisGuardLease.
+func (s *RedisStore) Guard(name string, timeout time.Duration, fn func() error) error {
+	key := s.prefix + ":guards:" + name
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to generate guard token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		reply, err := s.do("SET", key, token, "NX", "PX", redisGuardLease.Milliseconds())
+		if err != nil {
+			return fmt.Errorf("failed to guard %s: %w", name, err)
+		}
+		if reply != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: %s", ErrGuardTimeout, name)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	defer s.do("EVAL", redisReleaseGuard, 1, key, token)
+	return fn()
+}
+
+// Close closes the connection
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.close()
+	s.conn = nil
+	return err
+}
+
+// encodeRedisValue prefixes value with the time it's written
+func encodeRedisValue(value []byte) []byte {
+	header := strconv.FormatInt(time.Now().UnixNano(), 10) + "\n"
+	return append([]byte(header), value...)
+}
+
+func decodeRedisEntry(key string, reply any) (*Entry, error) {
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected value for %s: %v", key, reply)
+	}
+	header, value, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: key, Err: errors.New("missing write time")}
+	}
+	nanos, err := strconv.ParseInt(string(header), 10, 64)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: key, Err: err}
+	}
+	return &Entry{Key: key, Value: value, ModTime: time.Unix(0, nanos)}, nil
+}
+
+func redisTTLArgs(ttl time.Duration) []any {
+	if ttl <= 0 {
+		return nil
+	}
+	// PX rejects zero, and rounding down could expire a lease early
+	ms := (ttl + time.Millisecond - 1) / time.Millisecond
+	return []any{"PX", int64(ms)}
+}
+
+func redisString(reply any) string {
+	switch v := reply.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(reply)
+}
+
+// redisGlobEscape escapes the characters SCAN MATCH treats specially
+func redisGlobEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
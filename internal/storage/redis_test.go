@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+)
+
+// openTestRedis connects to CLAUDE_COORD_TEST_REDIS_URL if set, or else to an
+// in-process stand-in. Each test gets its own key prefix.
+func openTestRedis(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	cfg := config.DefaultConfig()
+	cfg.Settings.RedisURL = os.Getenv("CLAUDE_COORD_TEST_REDIS_URL")
+	cfg.Settings.RedisPrefix = "claude-coord-test:" + t.Name()
+
+	var mr *miniredis.Miniredis
+	if cfg.Settings.RedisURL == "" {
+		mr = miniredis.RunT(t)
+		cfg.Settings.RedisURL = "redis://" + mr.Addr()
+	}
+
+	s, err := OpenBackend(t.TempDir(), cfg, BackendRedis)
+	if err != nil {
+		t.Fatalf("Failed to open redis backend: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, ns := range Namespaces {
+			entries, _ := s.List(ns)
+			for _, e := range entries {
+				s.Delete(ns, e.Key)
+			}
+		}
+		s.Close()
+	})
+	return s.(*RedisStore), mr
+}
+
+func TestRedisStore(t *testing.T) {
+	s, _ := openTestRedis(t)
+	testStore(t, s)
+}
+
+func TestRedisTTL(t *testing.T) {
+	s, mr := openTestRedis(t)
+	if mr == nil {
+		t.Skip("needs the in-process server to fast-forward time")
+	}
+
+	if err := s.CreateWithTTL(Locks, "a", []byte("one"), 2*time.Second); err != nil {
+		t.Fatalf("CreateWithTTL failed: %v", err)
+	}
+	if err := s.PutWithTTL(Locks, "b", []byte("two"), 10*time.Second); err != nil {
+		t.Fatalf("PutWithTTL failed: %v", err)
+	}
+	if ttl := mr.TTL(s.key(Locks, "a")); ttl != 2*time.Second {
+		t.Errorf("Expected a 2s TTL, got %v", ttl)
+	}
+
+	mr.FastForward(3 * time.Second)
+	if _, err := s.Get(Locks, "a"); !os.IsNotExist(err) {
+		t.Errorf("Expected the entry to expire, got %v", err)
+	}
+	entries, err := s.List(Locks)
+	if err != nil || len(entries) != 1 || entries[0].Key != "b" {
+		t.Errorf("Expected only b to be left, got %v %v", entries, err)
+	}
+
+	// The key is free again once expired
+	if err := s.CreateWithTTL(Locks, "a", []byte("three"), 0); err != nil {
+		t.Errorf("Create after expiry failed: %v", err)
+	}
+}
+
+func TestRedisGuard(t *testing.T) {
+	s, _ := openTestRedis(t)
+
+	var mu sync.Mutex
+	inside, most := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Guard("res", 5*time.Second, func() error {
+				mu.Lock()
+				inside++
+				if inside > most {
+					most = inside
+				}
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				inside--
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Guard failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if most != 1 {
+		t.Errorf("Expected one holder at a time, saw %d", most)
+	}
+
+	// A held guard times out others
+	err := s.Guard("res", time.Second, func() error {
+		return s.Guard("res", 50*time.Millisecond, func() error { return nil })
+	})
+	if !errors.Is(err, ErrGuardTimeout) {
+		t.Errorf("Expected ErrGuardTimeout, got %v", err)
+	}
+}
+
+func TestRedisWatch(t *testing.T) {
+	s, _ := openTestRedis(t)
+
+	w, err := s.Watch(Locks)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer w.Close()
+
+	s.Put(Locks, "a", []byte("one"))
+	select {
+	case <-w.C:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a notification after Put")
+	}
+}
+
+func TestRedisURL(t *testing.T) {
+	t.Setenv("TEST_REDIS_PASSWORD", "s3cret")
+	cfg := config.DefaultConfig()
+	cfg.Settings.Backend = BackendRedis
+
+	if _, err := OpenBackend(t.TempDir(), cfg, BackendRedis); err == nil || !strings.Contains(err.Error(), "redis_url") {
+		t.Errorf("Expected an error about the missing redis_url, got %v", err)
+	}
+
+	mr := miniredis.RunT(t)
+	mr.RequireAuth("s3cret")
+	cfg.Settings.RedisURL = "redis://:${TEST_REDIS_PASSWORD}@" + mr.Addr() + "/2"
+	s, err := OpenBackend(t.TempDir(), cfg, BackendRedis)
+	if err != nil {
+		t.Fatalf("Failed to open with password: %v", err)
+	}
+	defer s.Close()
+	if err := s.Put(Agents, "a", []byte("{}")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	mr.Select(2)
+	if !mr.Exists(DefaultRedisPrefix + ":agents:a") {
+		t.Error("Expected the entry in database 2")
+	}
+
+	if _, err := parseRedisURL("http://localhost"); err == nil {
+		t.Error("Expected an error for a non-redis URL")
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisDialTimeout bounds connecting to the Redis server
+const redisDialTimeout = 5 * time.Second
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisConn is a connection speaking RESP, the Redis protocol. Replies are
+// decoded to string (status), []byte (bulk), int64, []any or nil.
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisOptions is a parsed redis:// or rediss:// URL
+type redisOptions struct {
+	addr     string
+	tls      bool
+	username string
+	password string
+	db       int
+}
+
+func parseRedisURL(raw string) (*redisOptions, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid redis URL %q: scheme must be redis or rediss", raw)
+	}
+
+	opts := &redisOptions{addr: u.Host, tls: u.Scheme == "rediss"}
+	if u.Port() == "" {
+		opts.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		opts.username = u.User.Username()
+		opts.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if opts.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return opts, nil
+}
+
+func dialRedis(opts *redisOptions) (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var conn net.Conn
+	var err error
+	if opts.tls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", opts.addr, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", opts.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	if opts.password != "" {
+		args := []any{"AUTH", opts.password}
+		if opts.username != "" {
+			args = []any{"AUTH", opts.username, opts.password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if opts.db != 0 {
+		if _, err := c.do("SELECT", opts.db); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to select database %d: %w", opts.db, err)
+		}
+	}
+	return c, nil
+}
+
+// do sends a command and reads its reply
+func (c *redisConn) do(args ...any) (any, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+func (c *redisConn) send(args ...any) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var s string
+		switch v := arg.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case int:
+			s = strconv.Itoa(v)
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		default:
+			return fmt.Errorf("unsupported argument type %T", arg)
+		}
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(s), s)
+	}
+	_, err := io.WriteString(c.conn, b.String())
+	return err
+}
+
+func (c *redisConn) receive() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply from redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			// An error inside an array is part of the reply, not a failure
+			item, err := c.receive()
+			var redisErr redisError
+			if err != nil && !errors.As(err, &redisErr) {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply from redis: %q", line)
+}
+
+func (c *redisConn) close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,276 @@
+//go:build cgo
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// busyTimeout is how long a write waits for another process's transaction
+const busyTimeout = 5 * time.Second
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS entries (
+	ns         TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	value      BLOB NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (ns, key)
+);
+CREATE TABLE IF NOT EXISTS history (
+	id    INTEGER PRIMARY KEY AUTOINCREMENT,
+	ns    TEXT NOT NULL,
+	key   TEXT NOT NULL,
+	op    TEXT NOT NULL,
+	value BLOB,
+	at    INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS history_key ON history (ns, key, id);
+`
+
+// historyNamespaces are the namespaces whose changes are recorded. Agent
+// heartbeats would drown out everything else.
+var historyNamespaces = map[Namespace]bool{Locks: true}
+
+// querier is what SQLiteStore needs from a database or a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SQLiteStore keeps entries in a SQLite database, recording the history of
+// every lock. Multi-entry changes can be made atomically with Atomically.
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// openSQLite opens, creating if needed, the database in coordDir
+func openSQLite(coordDir string) (Store, error) {
+	if err := os.MkdirAll(coordDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directories: %w", err)
+	}
+	path := filepath.Join(coordDir, SQLiteFile)
+
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=%d&_journal_mode=WAL&_txlock=immediate",
+		path, busyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	return &SQLiteStore{db: db, path: path}, nil
+}
+
+// Get returns the entry for key
+func (s *SQLiteStore) Get(ns Namespace, key string) (*Entry, error) {
+	return sqlGet(s.db, ns, key)
+}
+
+// List returns every entry in ns, sorted by key
+func (s *SQLiteStore) List(ns Namespace) ([]Entry, error) {
+	return sqlList(s.db, ns)
+}
+
+// Create stores value under key only if no entry exists yet
+func (s *SQLiteStore) Create(ns Namespace, key string, value []byte) error {
+	return s.Atomically(func(tx Store) error { return tx.Create(ns, key, value) })
+}
+
+// Put stores value under key, replacing any existing entry
+func (s *SQLiteStore) Put(ns Namespace, key string, value []byte) error {
+	return s.Atomically(func(tx Store) error { return tx.Put(ns, key, value) })
+}
+
+// CompareAndDelete removes the entry only if it still holds old
+func (s *SQLiteStore) CompareAndDelete(ns Namespace, key string, old []byte) error {
+	return s.Atomically(func(tx Store) error { return tx.CompareAndDelete(ns, key, old) })
+}
+
+// Delete removes the entry for key
+func (s *SQLiteStore) Delete(ns Namespace, key string) error {
+	return s.Atomically(func(tx Store) error { return tx.Delete(ns, key) })
+}
+
+// Watch notifies of changes to the database. Changes in any namespace wake
+// the watcher.
+func (s *SQLiteStore) Watch(ns Namespace) (*Watcher, error) {
+	// Committed writes land in the write-ahead log first
+	base := filepath.Base(s.path)
+	return watchDir(filepath.Dir(s.path), func(name string) bool {
+		return strings.HasPrefix(filepath.Base(name), base)
+	})
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Atomically runs fn in a transaction. Transactions take the database's
+// write lock up front, so they're serialized across processes.
+func (s *SQLiteStore) Atomically(fn func(Store) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(&sqliteTx{tx: tx, path: s.path}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// History returns every recorded change to key, oldest first
+func (s *SQLiteStore) History(ns Namespace, key string) ([]Change, error) {
+	rows, err := s.db.Query(`SELECT op, value, at FROM history WHERE ns = ? AND key = ? ORDER BY id`, string(ns), key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		c := Change{Namespace: ns, Key: key}
+		var at int64
+		if err := rows.Scan(&c.Op, &c.Value, &at); err != nil {
+			return nil, err
+		}
+		c.At = time.Unix(0, at).UTC()
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// sqliteTx is a Store making its changes in a transaction
+type sqliteTx struct {
+	tx   *sql.Tx
+	path string
+}
+
+// Atomically runs fn in the transaction already under way
+func (t *sqliteTx) Atomically(fn func(Store) error) error {
+	return fn(t)
+}
+
+func (t *sqliteTx) Get(ns Namespace, key string) (*Entry, error) {
+	return sqlGet(t.tx, ns, key)
+}
+
+func (t *sqliteTx) List(ns Namespace) ([]Entry, error) {
+	return sqlList(t.tx, ns)
+}
+
+func (t *sqliteTx) Create(ns Namespace, key string, value []byte) error {
+	res, err := t.tx.Exec(`INSERT INTO entries (ns, key, value, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (ns, key) DO NOTHING`, string(ns), key, value, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return exists(ns, key)
+	}
+	return t.record(ns, key, "create", value)
+}
+
+func (t *sqliteTx) Put(ns Namespace, key string, value []byte) error {
+	_, err := t.tx.Exec(`INSERT INTO entries (ns, key, value, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (ns, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		string(ns), key, value, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	return t.record(ns, key, "put", value)
+}
+
+func (t *sqliteTx) CompareAndDelete(ns Namespace, key string, old []byte) error {
+	current, err := t.Get(ns, key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current.Value, old) {
+		return ErrConflict
+	}
+	return t.Delete(ns, key)
+}
+
+func (t *sqliteTx) Delete(ns Namespace, key string) error {
+	res, err := t.tx.Exec(`DELETE FROM entries WHERE ns = ? AND key = ?`, string(ns), key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return notFound(ns, key)
+	}
+	return t.record(ns, key, "delete", nil)
+}
+
+func (t *sqliteTx) Watch(ns Namespace) (*Watcher, error) {
+	return nil, errors.New("cannot watch inside a transaction")
+}
+
+func (t *sqliteTx) Close() error {
+	return nil
+}
+
+// record adds a change to the history of namespaces that keep one
+func (t *sqliteTx) record(ns Namespace, key, op string, value []byte) error {
+	if !historyNamespaces[ns] {
+		return nil
+	}
+	_, err := t.tx.Exec(`INSERT INTO history (ns, key, op, value, at) VALUES (?, ?, ?, ?, ?)`,
+		string(ns), key, op, value, time.Now().UnixNano())
+	return err
+}
+
+func sqlGet(q querier, ns Namespace, key string) (*Entry, error) {
+	entry := Entry{Key: key}
+	var updated int64
+	err := q.QueryRowContext(context.Background(), `SELECT value, updated_at FROM entries WHERE ns = ? AND key = ?`,
+		string(ns), key).Scan(&entry.Value, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound(ns, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry.ModTime = time.Unix(0, updated)
+	return &entry, nil
+}
+
+func sqlList(q querier, ns Namespace) ([]Entry, error) {
+	rows, err := q.QueryContext(context.Background(), `SELECT key, value, updated_at FROM entries WHERE ns = ? ORDER BY key`, string(ns))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var updated int64
+		if err := rows.Scan(&entry.Key, &entry.Value, &updated); err != nil {
+			return nil, err
+		}
+		entry.ModTime = time.Unix(0, updated)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,10 @@
+//go:build !cgo
+
+package storage
+
+import "errors"
+
+// openSQLite fails: the SQLite driver needs cgo
+func openSQLite(coordDir string) (Store, error) {
+	return nil, errors.New("the sqlite backend needs a build with cgo (CGO_ENABLED=1)")
+}
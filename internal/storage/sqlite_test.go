@@ -0,0 +1,107 @@
+//go:build cgo
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestSQLite(t *testing.T) Store {
+	s, err := OpenBackend(filepath.Join(t.TempDir(), ".claude-coord"), nil, BackendSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, openTestSQLite(t))
+}
+
+func TestSQLiteTransactions(t *testing.T) {
+	s := openTestSQLite(t)
+	tx := s.(Transactional)
+
+	err := tx.Atomically(func(s Store) error {
+		s.Create(Locks, "a", []byte("a"))
+		s.Create(Locks, "b", []byte("b"))
+		return errors.New("changed my mind")
+	})
+	if err == nil {
+		t.Fatal("Expected the transaction's error")
+	}
+	if entries, _ := s.List(Locks); len(entries) != 0 {
+		t.Fatalf("Rolled back changes are visible: %+v", entries)
+	}
+
+	if err := tx.Atomically(func(s Store) error {
+		if err := s.Create(Locks, "a", []byte("a")); err != nil {
+			return err
+		}
+		return s.Create(Locks, "b", []byte("b"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := s.List(Locks); len(entries) != 2 {
+		t.Fatalf("Expected 2 committed locks, got %+v", entries)
+	}
+}
+
+func TestSQLiteHistory(t *testing.T) {
+	s := openTestSQLite(t)
+	s.Create(Locks, "a", []byte("one"))
+	s.Put(Locks, "a", []byte("two"))
+	s.Delete(Locks, "a")
+	s.Put(Agents, "agent-1", []byte("{}"))
+
+	changes, err := s.(Historian).History(Locks, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []string
+	for _, c := range changes {
+		ops = append(ops, c.Op)
+	}
+	if len(ops) != 3 || ops[0] != "create" || ops[1] != "put" || ops[2] != "delete" {
+		t.Errorf("Unexpected history: %v", ops)
+	}
+	if string(changes[1].Value) != "two" {
+		t.Errorf("Expected history to keep values, got %q", changes[1].Value)
+	}
+
+	// Heartbeats aren't worth keeping
+	if changes, _ := s.(Historian).History(Agents, "agent-1"); len(changes) != 0 {
+		t.Errorf("Expected no agent history, got %d changes", len(changes))
+	}
+}
+
+func TestCopy(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	os.MkdirAll(filepath.Join(coordDir, "locks"), 0755)
+	os.MkdirAll(filepath.Join(coordDir, "agents"), 0755)
+	files := NewFileStore(coordDir)
+	files.Put(Locks, "a", []byte("a"))
+	files.Put(Fences, "a", []byte("3"))
+	files.Put(Agents, "agent-1", []byte("{}"))
+
+	db := openTestSQLite(t)
+	db.Put(Locks, "leftover", []byte("old"))
+
+	copied, err := Copy(db, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied[Locks]) != 1 || len(copied[Fences]) != 1 || len(copied[Agents]) != 1 {
+		t.Errorf("Unexpected copy: %+v", copied)
+	}
+	if entries, _ := db.List(Locks); len(entries) != 1 || entries[0].Key != "a" {
+		t.Errorf("Expected only the copied lock, got %+v", entries)
+	}
+	if entry, err := db.Get(Fences, "a"); err != nil || string(entry.Value) != "3" {
+		t.Errorf("Fence not copied: %v %v", entry, err)
+	}
+}
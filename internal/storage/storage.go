@@ -0,0 +1,232 @@
+// Package storage abstracts where lock and agent state is kept. The file
+// backend keeps one file per entry in the coord dir, as claude-coord always
+// has; the SQLite backend keeps everything in one database, and the Redis
+// backend keeps it on a server shared by several hosts.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+)
+
+// Namespace separates the kinds of entries a store holds
+type Namespace string
+
+const (
+	// Locks holds one entry per locked resource
+	Locks Namespace = "locks"
+	// Fences holds the last fencing token issued for each resource
+	Fences Namespace = "fences"
+	// Agents holds one entry per registered agent
+	Agents Namespace = "agents"
+)
+
+// Namespaces lists every namespace, e.g. for copying a store
+var Namespaces = []Namespace{Locks, Fences, Agents}
+
+// Backends
+const (
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+	BackendRedis  = "redis"
+)
+
+// SQLiteFile is the SQLite backend's database in the coord dir
+const SQLiteFile = "state.db"
+
+// ErrConflict is returned when a compare-and-delete finds the entry changed
+var ErrConflict = errors.New("entry was modified concurrently")
+
+// Entry is a stored value. ModTime is when it was last written, which
+// heartbeats rely on.
+type Entry struct {
+	Key     string
+	Value   []byte
+	ModTime time.Time
+}
+
+// Store keeps entries by namespace and key. Missing entries are reported with
+// errors satisfying os.IsNotExist, and creating an existing one with errors
+// satisfying os.IsExist, whatever the backend.
+type Store interface {
+	// Get returns the entry for key
+	Get(ns Namespace, key string) (*Entry, error)
+
+	// List returns every entry in ns, sorted by key
+	List(ns Namespace) ([]Entry, error)
+
+	// Create stores value under key only if no entry exists yet
+	Create(ns Namespace, key string, value []byte) error
+
+	// Put stores value under key, replacing any existing entry
+	Put(ns Namespace, key string, value []byte) error
+
+	// CompareAndDelete removes the entry only if it still holds old,
+	// failing with ErrConflict otherwise
+	CompareAndDelete(ns Namespace, key string, old []byte) error
+
+	// Delete removes the entry for key
+	Delete(ns Namespace, key string) error
+
+	// Watch notifies of changes to entries in ns
+	Watch(ns Namespace) (*Watcher, error)
+
+	// Close releases the store
+	Close() error
+}
+
+// Transactional is implemented by stores that can apply several changes
+// atomically. Changes made through the Store passed to fn are committed
+// together if fn returns nil, and discarded otherwise.
+type Transactional interface {
+	Atomically(fn func(Store) error) error
+}
+
+// Change is one entry in a store's history
+type Change struct {
+	Namespace Namespace `json:"namespace"`
+	Key       string    `json:"key"`
+	Op        string    `json:"op"`
+	Value     []byte    `json:"value,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Historian is implemented by stores that record every change to an entry
+type Historian interface {
+	History(ns Namespace, key string) ([]Change, error)
+}
+
+// Expiring is implemented by stores that can drop an entry on their own
+// once it's no longer needed, e.g. a lock whose lease ran out on a host
+// that's gone. A ttl of zero means the entry never expires.
+type Expiring interface {
+	CreateWithTTL(ns Namespace, key string, value []byte, ttl time.Duration) error
+	PutWithTTL(ns Namespace, key string, value []byte, ttl time.Duration) error
+}
+
+// ErrGuardTimeout is returned when a guard stays taken for too long
+var ErrGuardTimeout = errors.New("timed out waiting for guard")
+
+// Guarded is implemented by stores shared between hosts, where a guard file
+// in the coord dir can't serialize updates. Guard runs fn while holding the
+// named guard, waiting up to timeout for it.
+type Guarded interface {
+	Guard(name string, timeout time.Duration, fn func() error) error
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   = map[string]Store{}
+)
+
+// Open returns the store configured for coordDir. Stores are shared by
+// everything in the process using the same coord dir, so callers must not
+// close them.
+func Open(coordDir string, cfg *config.Config) (Store, error) {
+	if coordDir == "" {
+		coordDir = config.DefaultCoordDir
+	}
+	backend := BackendFile
+	if cfg != nil && cfg.Settings.Backend != "" {
+		backend = cfg.Settings.Backend
+	}
+	if backend == BackendFile {
+		// Nothing to share
+		return NewFileStore(coordDir), nil
+	}
+
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	id := backend + ":" + coordDir
+	if s, ok := shared[id]; ok {
+		return s, nil
+	}
+	s, err := OpenBackend(coordDir, cfg, backend)
+	if err != nil {
+		return nil, err
+	}
+	shared[id] = s
+	return s, nil
+}
+
+// OpenBackend opens a new store of the given backend for coordDir, taking
+// any connection settings from cfg. The caller must close it.
+func OpenBackend(coordDir string, cfg *config.Config, backend string) (Store, error) {
+	switch backend {
+	case "", BackendFile:
+		return NewFileStore(coordDir), nil
+	case BackendSQLite:
+		return openSQLite(coordDir)
+	case BackendRedis:
+		return openRedis(cfg)
+	}
+	return nil, fmt.Errorf("unknown storage backend: %s", backend)
+}
+
+// AgentKey returns the key an agent's entry is stored under
+func AgentKey(agentID string) string {
+	return EncodeKey(agentID)
+}
+
+// LegacyAgentKey is the lossy flattening earlier releases stored agents
+// under. It's only used to find their entries; several IDs share each key.
+func LegacyAgentKey(agentID string) string {
+	safe := strings.ReplaceAll(agentID, "/", "-")
+	return strings.ReplaceAll(safe, "\\", "-")
+}
+
+// notFound reports a missing entry the way os functions do
+func notFound(ns Namespace, key string) error {
+	return &fs.PathError{Op: "get", Path: string(ns) + "/" + key, Err: fs.ErrNotExist}
+}
+
+// exists reports an entry that is already taken the way os functions do
+func exists(ns Namespace, key string) error {
+	return &fs.PathError{Op: "create", Path: string(ns) + "/" + key, Err: fs.ErrExist}
+}
+
+// Copy replaces every entry in dst with the entries of src, atomically if
+// dst supports transactions. It returns what was copied, by namespace.
+func Copy(dst, src Store) (map[Namespace][]Entry, error) {
+	copied := make(map[Namespace][]Entry)
+	for _, ns := range Namespaces {
+		entries, err := src.List(ns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", ns, err)
+		}
+		copied[ns] = entries
+	}
+
+	replace := func(dst Store) error {
+		for _, ns := range Namespaces {
+			// Leftovers from an earlier migration would resurrect old locks
+			existing, err := dst.List(ns)
+			if err != nil {
+				return fmt.Errorf("failed to list %s: %w", ns, err)
+			}
+			for _, e := range existing {
+				if err := dst.Delete(ns, e.Key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return fmt.Errorf("failed to remove %s/%s: %w", ns, e.Key, err)
+				}
+			}
+			for _, e := range copied[ns] {
+				if err := dst.Put(ns, e.Key, e.Value); err != nil {
+					return fmt.Errorf("failed to copy %s/%s: %w", ns, e.Key, err)
+				}
+			}
+		}
+		return nil
+	}
+
+	if tx, ok := dst.(Transactional); ok {
+		return copied, tx.Atomically(replace)
+	}
+	return copied, replace(dst)
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testStore checks the behaviour every backend must share
+func testStore(t *testing.T, s Store) {
+	if _, err := s.Get(Locks, "a"); !os.IsNotExist(err) {
+		t.Fatalf("Expected not exist for missing entry, got %v", err)
+	}
+	if err := s.Delete(Locks, "a"); !os.IsNotExist(err) {
+		t.Errorf("Expected not exist deleting missing entry, got %v", err)
+	}
+
+	if err := s.Create(Locks, "a", []byte("one")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := s.Create(Locks, "a", []byte("two")); !os.IsExist(err) {
+		t.Fatalf("Expected exists creating a taken key, got %v", err)
+	}
+	entry, err := s.Get(Locks, "a")
+	if err != nil || string(entry.Value) != "one" {
+		t.Fatalf("Expected 'one', got %v %v", entry, err)
+	}
+	if time.Since(entry.ModTime) > time.Minute {
+		t.Errorf("Unexpected mod time %v", entry.ModTime)
+	}
+
+	if err := s.Put(Locks, "a", []byte("two")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	s.Put(Locks, "b", []byte("b"))
+	s.Put(Fences, "a", []byte("1"))
+	s.Put(Agents, "agent-1", []byte("{}"))
+
+	// Namespaces don't see each other's entries
+	entries, err := s.List(Locks)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Key != "a" || string(entries[0].Value) != "two" || entries[1].Key != "b" {
+		t.Errorf("Unexpected locks: %+v", entries)
+	}
+	if entries, _ := s.List(Agents); len(entries) != 1 {
+		t.Errorf("Expected 1 agent, got %+v", entries)
+	}
+
+	if err := s.CompareAndDelete(Locks, "a", []byte("one")); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected conflict deleting a changed entry, got %v", err)
+	}
+	if entry, err := s.Get(Locks, "a"); err != nil || string(entry.Value) != "two" {
+		t.Errorf("Failed compare-and-delete lost the entry: %v %v", entry, err)
+	}
+	if err := s.CompareAndDelete(Locks, "a", []byte("two")); err != nil {
+		t.Errorf("Compare-and-delete failed: %v", err)
+	}
+	if _, err := s.Get(Locks, "a"); !os.IsNotExist(err) {
+		t.Errorf("Expected entry gone, got %v", err)
+	}
+
+	watcher, err := s.Watch(Locks)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer watcher.Close()
+	if err := s.Delete(Locks, "b"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-watcher.C:
+	case <-time.After(2 * time.Second):
+		t.Error("No notification after delete")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	for _, dir := range []string{"locks", "agents"} {
+		os.MkdirAll(filepath.Join(coordDir, dir), 0755)
+	}
+	s := NewFileStore(coordDir)
+	testStore(t, s)
+
+	// Agent IDs are escaped in file names, so they can't collide
+	if AgentKey("team/agent") == AgentKey("team-agent") || AgentKey("Team") == AgentKey("team") {
+		t.Error("Expected distinct agent IDs to have distinct keys")
+	}
+	s.Put(Agents, AgentKey("team/agent"), []byte("{}"))
+	info, err := os.Stat(filepath.Join(coordDir, "agents", "team%2Fagent.agent"))
+	if err != nil {
+		t.Fatalf("Agent file not where expected: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0644 {
+		t.Errorf("Expected entries readable by others, got %v", info.Mode().Perm())
+	}
+
+	// Without hard links, entries are still created exclusively
+	link = func(oldname, newname string) error {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.EPERM}
+	}
+	defer func() { link = os.Link }()
+	if err := s.Create(Locks, "nolink", []byte("a")); err != nil {
+		t.Fatalf("Create without hard links failed: %v", err)
+	}
+	if err := s.Create(Locks, "nolink", []byte("b")); !os.IsExist(err) {
+		t.Errorf("Expected an os.IsExist error, got %v", err)
+	}
+	if e, err := s.Get(Locks, "nolink"); err != nil || string(e.Value) != "a" {
+		t.Errorf("Expected the first value kept, got %v %v", e, err)
+	}
+}
+
+func TestOpenUnknownBackend(t *testing.T) {
+	if _, err := OpenBackend(t.TempDir(), nil, "floppy"); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
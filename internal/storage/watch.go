@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher delivers change notifications from a store
+type Watcher struct {
+	// C receives a value after something changed. Changes that happen before
+	// the last one was received are coalesced.
+	C <-chan struct{}
+
+	close func() error
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.close()
+}
+
+// watchDir notifies of changes to files in dir for which match returns true
+func watchDir(dir string, match func(name string) bool) (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	ch := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// Touching an entry doesn't change it
+				if event.Op == fsnotify.Chmod || !match(event.Name) {
+					continue
+				}
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return &Watcher{C: ch, close: func() error {
+		close(done)
+		return watcher.Close()
+	}}, nil
+}
@@ -0,0 +1,65 @@
+package coord_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/pkg/coord"
+)
+
+// This file pins down the exported API as downstream code uses it. If a
+// change stops it compiling, that change breaks callers: add to the API
+// instead, and only add to this file.
+var (
+	_ func(coord.Options) (*coord.Client, error) = coord.Open
+
+	_ func(*coord.Client) string                                                                    = (*coord.Client).Dir
+	_ func(*coord.Client) string                                                                    = (*coord.Client).AgentID
+	_ func(*coord.Client, context.Context) error                                                    = (*coord.Client).Register
+	_ func(*coord.Client, context.Context) ([]coord.Agent, error)                                   = (*coord.Client).Agents
+	_ func(*coord.Client, context.Context, string) error                                            = (*coord.Client).SetTask
+	_ func(*coord.Client) error                                                                     = (*coord.Client).Close
+	_ func(*coord.Client, context.Context, []string, coord.LockOptions) error                       = (*coord.Client).Lock
+	_ func(*coord.Client, context.Context, ...string) error                                         = (*coord.Client).Unlock
+	_ func(*coord.Client, context.Context, ...string) error                                         = (*coord.Client).ForceUnlock
+	_ func(*coord.Client, context.Context) error                                                    = (*coord.Client).UnlockAll
+	_ func(*coord.Client, context.Context, string, time.Duration) error                             = (*coord.Client).Renew
+	_ func(*coord.Client, context.Context, string) (*coord.Lock, error)                             = (*coord.Client).Read
+	_ func(*coord.Client, context.Context) ([]coord.Lock, error)                                    = (*coord.Client).Locks
+	_ func(*coord.Client, context.Context, *coord.Lock) ([]coord.Lease, error)                      = (*coord.Client).Leases
+	_ func(*coord.Client, context.Context, []string, coord.CheckOptions) ([]coord.FileCheck, error) = (*coord.Client).Check
+	_ func(*coord.Client, context.Context, string, coord.WaitOptions) error                         = (*coord.Client).Wait
+	_ func(*coord.Client, context.Context, string, uint64) error                                    = (*coord.Client).Verify
+
+	_ = coord.Options{Dir: "", AgentID: "", AgentName: "", Server: "", Token: ""}
+	_ = coord.LockOptions{Mode: coord.Exclusive, Operation: "", TTL: time.Minute, Wait: true}
+	_ = coord.CheckOptions{Acquire: true, Operation: ""}
+	_ = coord.WaitOptions{Mode: coord.Shared, Acquire: true, Operation: "", TTL: time.Minute, Progress: func(coord.WaitStatus) {}}
+
+	_ error = coord.ErrLocked
+	_ error = coord.ErrNotLocked
+	_ error = coord.ErrNotOwner
+	_ error = coord.ErrStale
+	_ error = coord.ErrTimeout
+	_ error = coord.ErrDeadlock
+	_ error = coord.ErrInvalidPattern
+	_ error = &coord.Error{Code: "", Message: ""}
+	_ error = &coord.LockedError{Resource: "", Lock: (*coord.Lock)(nil), Queued: (*coord.Ticket)(nil)}
+)
+
+// The fields callers read from results
+func useResults(l coord.Lock, lease coord.Lease, fc coord.FileCheck, ws coord.WaitStatus, a coord.Agent) {
+	var h coord.Holder = l.Holder
+	_, _, _, _ = l.Resource, l.Mode, l.Files, l.Holders
+	_, _, _, _, _, _ = h.AgentID, h.AgentName, h.Operation, h.AcquiredAt, h.TTLSeconds, h.Token
+	_, _ = h.Holds, h.HoldCount()
+	_, _ = l.HeldBy(""), l.AllHolders()
+	_, _, _, _ = lease.AgentID, lease.ExpiresAt, lease.RemainingSeconds, lease.Stale
+	_, _, _, _, _, _, _ = fc.File, fc.Protected, fc.Resource, fc.Blocker, fc.Acquired, fc.Token, fc.Error
+	_ = fc.IsBlocked()
+	_, _, _, _ = ws.Holder, ws.Ahead, ws.Position, ws.Err
+	_, _, _, _, _ = a.ID, a.Name, a.LastHeartbeat, a.CurrentTask, a.LocksHeld
+	_, _, _ = a.TaskStartedAt, a.TaskAuto, a.TaskHistory
+}
+
+var _ = useResults
@@ -0,0 +1,249 @@
+// Package coord is the supported Go API for claude-coord. Go tools, such as
+// a migration runner or a codegen step, can use it to lock resources, check
+// files and wait their turn alongside Claude Code agents and the CLI,
+// without shelling out to the binary.
+//
+// A Client acts as one agent. It finds the coord dir the way the CLI does
+// and works on it directly, or goes through the coordination server when one
+// is configured. Errors can be told apart with errors.Is against ErrLocked,
+// ErrNotOwner and the other Err values, and errors.As with a *LockedError
+// says who holds a lock.
+//
+// Every call takes a context. Wait, and Lock with LockOptions.Wait, block
+// until the context is done; the other calls only check it before they
+// start, since they take no longer than a few file or network round trips.
+package coord
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/agent"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/daemon"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// Types shared with the CLI, so values mean the same as in its JSON output
+type (
+	Lock        = lock.Lock
+	Holder      = lock.Holder
+	Lease       = lock.Lease
+	Mode        = lock.Mode
+	Ticket      = lock.Ticket
+	FileCheck   = lock.FileCheck
+	WaitStatus  = lock.WaitStatus
+	Agent       = agent.Agent
+	Error       = lock.Error
+	LockedError = lock.LockedError
+)
+
+// Lock modes
+const (
+	Exclusive = lock.Exclusive
+	Shared    = lock.Shared
+)
+
+// Errors calls fail with, for use with errors.Is. See the lock package's
+// errors of the same names.
+var (
+	ErrLocked         = lock.ErrLocked
+	ErrNotLocked      = lock.ErrNotLocked
+	ErrNotOwner       = lock.ErrNotOwner
+	ErrStale          = lock.ErrStale
+	ErrTimeout        = lock.ErrTimeout
+	ErrDeadlock       = lock.ErrDeadlock
+	ErrInvalidPattern = lock.ErrInvalidPattern
+)
+
+// Options configures Open. The zero value works like running the CLI in the
+// current directory.
+type Options struct {
+	// Dir is the coord dir. Empty finds it as the CLI does: the shared
+	// claude-coord dir of the git repo, or .claude-coord.
+	Dir string
+
+	// AgentID identifies the agent. Empty resolves it as
+	// `claude-coord whoami` does.
+	AgentID string
+
+	// AgentName is shown to other agents alongside the ID
+	AgentName string
+
+	// Server is a coordination server URL (see `claude-coord serve`).
+	// Empty uses settings.server from the config, if set.
+	Server string
+
+	// Token authenticates to the server. Empty uses CLAUDE_COORD_TOKEN.
+	Token string
+}
+
+// Client coordinates with other agents as one agent. It's safe for
+// concurrent use.
+type Client struct {
+	dir       string
+	cfg       *config.Config
+	agentID   string
+	agentName string
+
+	lockMgr  *lock.Manager
+	agentMgr *agent.Manager
+
+	// remote is set when a coordination server keeps the locks
+	remote *daemon.Client
+
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+// Open returns a client for the coord dir opts describe. A missing config
+// is treated as the defaults, as the CLI does.
+func Open(opts Options) (*Client, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = config.FindCoordDir()
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+		cfg = config.DefaultConfig()
+	}
+
+	c := &Client{
+		dir:       dir,
+		cfg:       cfg,
+		agentID:   agent.ResolveIdentity(dir, opts.AgentID).ID,
+		agentName: opts.AgentName,
+		lockMgr:   lock.NewManager(dir, cfg),
+		agentMgr:  agent.NewManager(dir, cfg),
+	}
+
+	server := opts.Server
+	if server == "" {
+		server = cfg.Settings.Server
+	}
+	if server != "" {
+		token := opts.Token
+		if token == "" {
+			token = os.Getenv(daemon.TokenEnv)
+		}
+		if c.remote, err = daemon.DialHTTP(server, token); err != nil {
+			return nil, fmt.Errorf("failed to reach server %s: %w", server, err)
+		}
+	}
+
+	return c, nil
+}
+
+// Dir returns the coord dir the client uses
+func (c *Client) Dir() string {
+	return c.dir
+}
+
+// AgentID returns the ID of the agent the client acts as
+func (c *Client) AgentID() string {
+	return c.agentID
+}
+
+// Register records the agent so it shows up in status, and keeps sending
+// heartbeats until Close. Every heartbeat also renews the agent's locks, so
+// they don't expire while the client is in use.
+func (c *Client) Register(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return nil
+	}
+
+	var err error
+	if c.remote != nil {
+		err = c.remote.Register(c.agentID, c.agentName)
+	} else {
+		err = c.agentMgr.Register(c.agentID, c.agentName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	interval := time.Duration(c.cfg.Settings.HeartbeatInterval) * time.Second
+	if interval <= 0 {
+		interval = config.DefaultHeartbeat * time.Second
+	}
+	c.stop = make(chan struct{})
+	go c.heartbeat(interval, c.stop)
+	return nil
+}
+
+func (c *Client) heartbeat(interval time.Duration, stop <-chan struct{}) {
+	if c.remote == nil {
+		c.agentMgr.RunHeartbeat(c.agentID, interval, stop, agent.RenewLocks(c.lockMgr.RenewAll))
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.remote.Heartbeat(c.agentID, nil, true)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Agents returns every registered agent
+func (c *Client) Agents(ctx context.Context) ([]Agent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.remote != nil {
+		status, err := c.remote.Status()
+		if err != nil {
+			return nil, err
+		}
+		return status.Agents, nil
+	}
+	return c.agentMgr.List()
+}
+
+// SetTask sets the task status shows for the agent, registering it if
+// needed. An empty task clears it; while none is set, status shows one made
+// from the agent's locks.
+func (c *Client) SetTask(ctx context.Context, task string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.remote != nil {
+		return c.remote.SetTask(c.agentID, task)
+	}
+	return c.agentMgr.UpdateTask(c.agentID, task)
+}
+
+// Close stops the heartbeats started by Register and deregisters the
+// agent. Locks still held are kept until they expire; release them first
+// with UnlockAll if they're no longer needed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop == nil {
+		return nil
+	}
+	close(c.stop)
+	c.stop = nil
+
+	if c.remote != nil {
+		return c.remote.Deregister(c.agentID)
+	}
+	return c.agentMgr.Deregister(c.agentID)
+}
@@ -0,0 +1,182 @@
+package coord_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/config"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/daemon"
+	"github.com/LoomLabs-Venture-Studio/claude-coord/pkg/coord"
+)
+
+// newCoordDir initializes a fresh coord dir that protects db/**/*
+func newCoordDir(t *testing.T) string {
+	coordDir := filepath.Join(t.TempDir(), ".claude-coord")
+	cfg := config.DefaultConfig()
+	cfg.Protected = []config.ProtectedPath{{Pattern: "db/**/*"}}
+	if err := cfg.Save(coordDir); err != nil {
+		t.Fatal(err)
+	}
+	return coordDir
+}
+
+func open(t *testing.T, opts coord.Options) *coord.Client {
+	c, err := coord.Open(opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient(t *testing.T) {
+	coordDir := newCoordDir(t)
+	testClient(t, coord.Options{Dir: coordDir})
+}
+
+func TestClientServer(t *testing.T) {
+	coordDir := newCoordDir(t)
+	cfg, err := config.Load(coordDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(daemon.NewServer(coordDir, cfg).Handler("s3cret"))
+	t.Cleanup(server.Close)
+
+	// Clients keep nothing in their own coord dir
+	testClient(t, coord.Options{Dir: newCoordDir(t), Server: server.URL, Token: "s3cret"})
+
+	if _, err := coord.Open(coord.Options{Dir: coordDir, Server: server.URL, Token: "wrong"}); err == nil {
+		t.Error("Expected the wrong token to be refused")
+	}
+}
+
+func testClient(t *testing.T, opts coord.Options) {
+	ctx := context.Background()
+
+	opts.AgentID, opts.AgentName = "runner", "Migration runner"
+	runner := open(t, opts)
+	opts.AgentID, opts.AgentName = "codegen", ""
+	codegen := open(t, opts)
+
+	if runner.AgentID() != "runner" {
+		t.Errorf("Expected agent ID runner, got %q", runner.AgentID())
+	}
+	if err := runner.Register(ctx); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if agents, err := codegen.Agents(ctx); err != nil || len(agents) != 1 || agents[0].Name != "Migration runner" {
+		t.Errorf("Expected the runner registered, got %+v %v", agents, err)
+	}
+
+	if err := runner.Lock(ctx, []string{"db/**/*"}, coord.LockOptions{Operation: "migrate", TTL: time.Minute}); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if agents, _ := codegen.Agents(ctx); len(agents) != 1 || agents[0].CurrentTask != "migrate (db/**/*)" || !agents[0].TaskAuto {
+		t.Errorf("Expected the runner's task made from its lock, got %+v", agents)
+	}
+	if err := runner.SetTask(ctx, "Adding OAuth"); err != nil {
+		t.Fatalf("SetTask failed: %v", err)
+	}
+	if agents, _ := codegen.Agents(ctx); len(agents) != 1 || agents[0].CurrentTask != "Adding OAuth" || agents[0].TaskAuto {
+		t.Errorf("Expected the runner's task set, got %+v", agents)
+	}
+	l, err := codegen.Read(ctx, "db/**/*")
+	if err != nil || l.AgentID != "runner" || l.TTLSeconds != 60 {
+		t.Fatalf("Expected runner's lock for 60s, got %+v %v", l, err)
+	}
+	if leases, err := codegen.Leases(ctx, l); err != nil || len(leases) != 1 || leases[0].Stale {
+		t.Errorf("Expected a live lease, got %+v %v", leases, err)
+	}
+	if err := codegen.Verify(ctx, "db/**/*", l.Token); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+	if err := codegen.Verify(ctx, "db/**/*", l.Token+1); !errors.Is(err, coord.ErrStale) {
+		t.Errorf("Expected ErrStale for a wrong token, got %v", err)
+	}
+
+	// Typed errors say what went wrong
+	err = codegen.Lock(ctx, []string{"db/schema/*"}, coord.LockOptions{})
+	var locked *coord.LockedError
+	if !errors.Is(err, coord.ErrLocked) || !errors.As(err, &locked) || locked.Lock.AgentID != "runner" {
+		t.Errorf("Expected a LockedError naming runner, got %v", err)
+	}
+	if err := codegen.Unlock(ctx, "db/**/*"); !errors.Is(err, coord.ErrNotOwner) {
+		t.Errorf("Expected ErrNotOwner, got %v", err)
+	}
+	if _, err := codegen.Read(ctx, "go.mod"); !errors.Is(err, coord.ErrNotLocked) {
+		t.Errorf("Expected ErrNotLocked, got %v", err)
+	}
+	if err := codegen.Lock(ctx, []string{"db/[x"}, coord.LockOptions{}); !errors.Is(err, coord.ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern, got %v", err)
+	}
+
+	checks, err := codegen.Check(ctx, []string{"db/users.sql", "main.go"}, coord.CheckOptions{})
+	if err != nil || len(checks) != 1 || checks[0].Blocker == nil || checks[0].Blocker.AgentID != "runner" {
+		t.Errorf("Expected db/users.sql blocked by runner, got %+v %v", checks, err)
+	}
+
+	// Waits time out, are cancelled, or get the lock once it's released
+	short, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if err := codegen.Wait(short, "db/**/*", coord.WaitOptions{}); !errors.Is(err, coord.ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := codegen.Wait(canceled, "db/**/*", coord.WaitOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if err := codegen.Lock(canceled, []string{"go.mod"}, coord.LockOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop Lock, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- codegen.Wait(ctx, "db/**/*", coord.WaitOptions{Acquire: true, Operation: "codegen"})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := runner.UnlockAll(ctx); err != nil {
+		t.Fatalf("UnlockAll failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Wait didn't return after release")
+	}
+	if locks, err := runner.Locks(ctx); err != nil || len(locks) != 1 || locks[0].AgentID != "codegen" {
+		t.Errorf("Expected codegen to hold the lock, got %+v %v", locks, err)
+	}
+
+	// Nested steps take holds of their own
+	if err := codegen.Lock(ctx, []string{"db/**/*"}, coord.LockOptions{Operation: "nested"}); err != nil {
+		t.Fatalf("Re-locking failed: %v", err)
+	}
+	if err := codegen.Unlock(ctx, "db/**/*"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if l, err := runner.Read(ctx, "db/**/*"); err != nil || l.AgentID != "codegen" || l.HoldCount() != 1 {
+		t.Errorf("Expected codegen's outer hold kept, got %+v %v", l, err)
+	}
+	codegen.Lock(ctx, []string{"db/**/*"}, coord.LockOptions{})
+	if err := codegen.ForceUnlock(ctx, "db/**/*"); err != nil {
+		t.Fatalf("ForceUnlock failed: %v", err)
+	}
+	if _, err := runner.Read(ctx, "db/**/*"); !errors.Is(err, coord.ErrNotLocked) {
+		t.Errorf("Expected ForceUnlock to drop every hold, got %v", err)
+	}
+
+	if err := runner.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if agents, _ := codegen.Agents(ctx); len(agents) != 0 {
+		t.Errorf("Expected Close to deregister, got %+v", agents)
+	}
+}
@@ -0,0 +1,273 @@
+package coord
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/LoomLabs-Venture-Studio/claude-coord/internal/lock"
+)
+
+// LockOptions configures Lock
+type LockOptions struct {
+	// Mode is Exclusive (the default) or Shared
+	Mode Mode
+
+	// Operation tells other agents what the lock is for
+	Operation string
+
+	// TTL is how long the lock lasts without being renewed. Zero uses the
+	// configured default.
+	TTL time.Duration
+
+	// Wait blocks while other agents hold the resources, until the locks
+	// are granted or ctx is done, instead of failing with ErrLocked.
+	// Agents queued by Wait with Acquire go first.
+	Wait bool
+}
+
+// Lock takes locks on resources for the agent, all or none of them. If
+// another agent holds a conflicting lock, the error matches ErrLocked and
+// is a *LockedError naming it. With opts.Wait, a timeout matches
+// ErrTimeout.
+//
+// Locks are reentrant: locking a resource the agent already holds, say from
+// a nested step, takes another hold that needs its own Unlock.
+func (c *Client) Lock(ctx context.Context, resources []string, opts LockOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mode := modeOrDefault(opts.Mode)
+	switch {
+	case opts.Wait && c.remote != nil:
+		return c.remote.LockContext(ctx, resources, mode, c.agentID, c.agentName, opts.Operation, ttlSeconds(opts.TTL))
+	case opts.Wait:
+		return c.lockMgr.AcquireContext(ctx, resources, mode, c.agentID, c.agentName, opts.Operation, ttlSeconds(opts.TTL))
+	case c.remote != nil:
+		return c.remote.Lock(resources, mode, c.agentID, c.agentName, opts.Operation, ttlSeconds(opts.TTL))
+	}
+	return c.lockMgr.AcquireAll(resources, mode, c.agentID, c.agentName, opts.Operation, ttlSeconds(opts.TTL))
+}
+
+// Unlock releases one of the agent's holds on each of resources. Resources
+// that aren't locked are skipped; ones held by other agents fail with
+// ErrNotOwner.
+func (c *Client) Unlock(ctx context.Context, resources ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.remote != nil {
+		return c.remote.Unlock(resources, c.agentID, false)
+	}
+	for _, r := range resources {
+		if err := c.lockMgr.Release(r, c.agentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForceUnlock drops every hold the agent has on resources, so they're
+// unlocked however many times they were locked
+func (c *Client) ForceUnlock(ctx context.Context, resources ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.remote != nil {
+		return c.remote.ForceUnlock(resources, c.agentID)
+	}
+	for _, r := range resources {
+		if err := c.lockMgr.ForceRelease(r, c.agentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnlockAll releases every lock the agent holds, however many holds it has
+func (c *Client) UnlockAll(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.remote != nil {
+		return c.remote.Unlock(nil, c.agentID, true)
+	}
+	return c.lockMgr.ReleaseAll(c.agentID)
+}
+
+// Renew starts a new lease on the agent's lock on resource. A ttl of zero
+// keeps the lock's current TTL.
+func (c *Client) Renew(ctx context.Context, resource string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.remote != nil {
+		return c.remote.Renew([]string{resource}, c.agentID, ttlSeconds(ttl), false)
+	}
+	return c.lockMgr.Renew(resource, c.agentID, ttlSeconds(ttl))
+}
+
+// Read returns the lock on resource, or an error matching ErrNotLocked
+func (c *Client) Read(ctx context.Context, resource string) (*Lock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.remote != nil {
+		locks, err := c.remote.Read([]string{resource})
+		if err != nil {
+			return nil, err
+		}
+		if len(locks) == 0 {
+			return nil, notLocked(resource)
+		}
+		return &locks[0], nil
+	}
+
+	l, err := c.lockMgr.Read(resource)
+	if os.IsNotExist(err) {
+		return nil, notLocked(resource)
+	}
+	return l, err
+}
+
+// Locks returns every current lock, including stale ones nobody has
+// cleaned up yet
+func (c *Client) Locks(ctx context.Context) ([]Lock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.remote != nil {
+		status, err := c.remote.Status()
+		if err != nil {
+			return nil, err
+		}
+		return status.Locks, nil
+	}
+	return c.lockMgr.List()
+}
+
+// Leases reports how long each holder of l has left, and which have gone
+// stale
+func (c *Client) Leases(ctx context.Context, l *Lock) ([]Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.remote != nil {
+		// Only the server can tell whose heartbeats have stopped
+		status, err := c.remote.Status()
+		if err != nil {
+			return nil, err
+		}
+		return status.Leases[l.Resource], nil
+	}
+	return c.lockMgr.Leases(l), nil
+}
+
+// CheckOptions configures Check
+type CheckOptions struct {
+	// Acquire locks protected files nobody holds, as the pre-tool-use hook
+	// does before an edit
+	Acquire bool
+
+	// Operation describes locks taken with Acquire
+	Operation string
+}
+
+// Check reports, for each of files that matches a protected pattern or
+// logical resource, whether another agent holds it. Files that aren't
+// protected are left out.
+func (c *Client) Check(ctx context.Context, files []string, opts CheckOptions) ([]FileCheck, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.remote != nil {
+		return c.remote.Check(files, opts.Acquire, c.agentID, c.agentName, opts.Operation)
+	}
+
+	var results []FileCheck
+	for _, f := range files {
+		result, err := c.lockMgr.CheckFile(f, opts.Acquire, c.agentID, c.agentName, opts.Operation)
+		if err != nil {
+			return results, err
+		}
+		if result.Protected {
+			results = append(results, *result)
+		}
+	}
+	return results, nil
+}
+
+// WaitOptions configures Wait
+type WaitOptions struct {
+	// Mode is the kind of lock waited for: Exclusive (the default) or
+	// Shared
+	Mode Mode
+
+	// Acquire queues for the resource and takes the lock when the agent's
+	// turn comes, instead of just waiting for it to become available
+	Acquire bool
+
+	// Operation and TTL describe the lock taken with Acquire, as for Lock
+	Operation string
+	TTL       time.Duration
+
+	// Progress, if set, is called whenever what the agent is waiting for
+	// changes
+	Progress func(WaitStatus)
+}
+
+// Wait blocks until resource is available to the agent, or with
+// opts.Acquire, until the agent has locked it. It returns ctx's error when
+// ctx is cancelled, and an error matching ErrTimeout when its deadline
+// passes. A wait that would deadlock fails at once with ErrDeadlock.
+func (c *Client) Wait(ctx context.Context, resource string, opts WaitOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wait := c.lockMgr.Wait
+	if c.remote != nil {
+		wait = c.remote.Wait
+	}
+	return wait(ctx, resource, c.agentID, lock.WaitOptions{
+		Mode:      modeOrDefault(opts.Mode),
+		Acquire:   opts.Acquire,
+		AgentName: c.agentName,
+		Operation: opts.Operation,
+		TTL:       ttlSeconds(opts.TTL),
+		Progress:  opts.Progress,
+	})
+}
+
+// Verify checks that token is a current fencing token for resource. Work
+// guarded by the lock should stop if it returns an error, which matches
+// ErrStale.
+func (c *Client) Verify(ctx context.Context, resource string, token uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.remote != nil {
+		return c.remote.Verify(resource, token)
+	}
+	return c.lockMgr.Verify(resource, token)
+}
+
+func modeOrDefault(mode Mode) Mode {
+	if mode == "" {
+		return Exclusive
+	}
+	return mode
+}
+
+func notLocked(resource string) error {
+	return &Error{Code: lock.CodeNotLocked, Message: fmt.Sprintf("resource '%s' is not locked", resource)}
+}
+
+// ttlSeconds converts a TTL to whole seconds, rounding up so a short TTL
+// doesn't become the default
+func ttlSeconds(ttl time.Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	return int((ttl + time.Second - 1) / time.Second)
+}